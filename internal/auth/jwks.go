@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// jsonWebKey holds the members of a JWK needed to rebuild RSA and EC public keys
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS returns the signing keys of a JSON Web Key Set by key ID. Keys of other
+// types or meant for encryption are skipped.
+func parseJWKS(raw []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		var err error
+		switch jwk.Kty {
+		case "RSA":
+			key, err = jwk.rsaPublicKey()
+		case "EC":
+			key, err = jwk.ecPublicKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWK %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS has no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeBigInt(k.E)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("unsupported RSA exponent")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (k jsonWebKey) ecPublicKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	var validator ecdh.Curve
+	switch k.Crv {
+	case "P-256":
+		curve, validator = elliptic.P256(), ecdh.P256()
+	case "P-384":
+		curve, validator = elliptic.P384(), ecdh.P384()
+	case "P-521":
+		curve, validator = elliptic.P521(), ecdh.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+	x, err := decodeBigInt(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeBigInt(k.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reject points that are not on the curve by parsing their uncompressed encoding
+	size := (curve.Params().BitSize + 7) / 8
+	if len(x.Bytes()) > size || len(y.Bytes()) > size {
+		return nil, fmt.Errorf("coordinates too large for curve %s", k.Crv)
+	}
+	point := make([]byte, 1+2*size)
+	point[0] = 4
+	x.FillBytes(point[1 : 1+size])
+	y.FillBytes(point[1+size:])
+	if _, err = validator.NewPublicKey(point); err != nil {
+		return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// decodeBigInt decodes a base64url encoded big-endian integer
+func decodeBigInt(encoded string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(raw) == 0 {
+		return nil, fmt.Errorf("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"urlshortener/internal/domain"
+)
+
+// signingMethods lists the asymmetric algorithms accepted in tokens. Symmetric algorithms
+// and "none" are rejected so a public JWKS key can never be used as an HMAC secret.
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// minRefreshGap limits how often an unknown key ID can trigger a JWKS reload
+const minRefreshGap = time.Minute
+
+// leeway tolerates small clock differences with the identity provider
+const leeway = 30 * time.Second
+
+// ErrInvalidToken is returned for tokens that fail signature or claim validation
+var ErrInvalidToken = errors.New("invalid bearer token")
+
+// JWTConfig describes where the signing keys come from and which tokens are accepted
+type JWTConfig struct {
+	JWKSSource  string // Path of a JWKS file, or an http(s) URL to fetch it from
+	Issuer      string // Required "iss" claim
+	Audience    string // Required entry of the "aud" claim
+	RolesClaim  string // Claim holding the roles; dots select nested objects, e.g. "realm_access.roles"
+	TenantClaim string // Claim holding the tenant ID; tokens without it belong to the default tenant
+}
+
+// Identity is the caller described by a verified token
+type Identity struct {
+	Subject string
+	Roles   []string
+	Tenant  string // Empty when the token names no tenant
+}
+
+// JWTVerifier validates bearer tokens against a JWKS that is reloaded periodically
+type JWTVerifier struct {
+	config     JWTConfig
+	httpClient *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	lastRefresh time.Time // Latest reload attempt, successful or not
+}
+
+// NewJWTVerifier creates a verifier and loads the JWKS once, so bad configuration fails at startup
+func NewJWTVerifier(config JWTConfig) (*JWTVerifier, error) {
+	if config.Issuer == "" || config.Audience == "" {
+		return nil, errors.New("JWT issuer and audience are required")
+	}
+	if config.RolesClaim == "" {
+		config.RolesClaim = "roles"
+	}
+	if config.TenantClaim == "" {
+		config.TenantClaim = "tenant"
+	}
+
+	verifier := &JWTVerifier{config: config, httpClient: &http.Client{Timeout: 10 * time.Second}}
+	if err := verifier.Refresh(); err != nil {
+		return nil, err
+	}
+	return verifier, nil
+}
+
+// Refresh reloads the JWKS; on failure the previous keys stay in use
+func (v *JWTVerifier) Refresh() error {
+	v.mu.Lock()
+	v.lastRefresh = time.Now()
+	v.mu.Unlock()
+
+	raw, err := v.readJWKS()
+	if err != nil {
+		return fmt.Errorf("failed to load JWKS: %w", err)
+	}
+	keys, err := parseJWKS(raw)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.mu.Unlock()
+	return nil
+}
+
+// StartRefresh periodically reloads the JWKS to pick up rotated keys
+func (v *JWTVerifier) StartRefresh(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := v.Refresh(); err != nil {
+				log.Printf("Failed to refresh JWKS: %v", err)
+			}
+		}
+	}()
+}
+
+// Verify checks the signature, issuer, audience and lifetime of a token and returns its identity
+func (v *JWTVerifier) Verify(tokenString string) (Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, v.keyFor,
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(v.config.Issuer),
+		jwt.WithAudience(v.config.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return Identity{}, fmt.Errorf("%w: missing sub claim", ErrInvalidToken)
+	}
+	tenant, _ := claims[v.config.TenantClaim].(string)
+	if tenant != "" && !domain.IsValidTenant(tenant) {
+		return Identity{}, fmt.Errorf("%w: malformed %s claim", ErrInvalidToken, v.config.TenantClaim)
+	}
+	return Identity{Subject: subject, Roles: rolesFrom(claims, v.config.RolesClaim), Tenant: tenant}, nil
+}
+
+// keyFor returns the JWKS key named by the token's kid header, reloading the JWKS once
+// in a while when the key is unknown in case it was just rotated
+func (v *JWTVerifier) keyFor(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := v.lookup(kid); ok {
+		return key, nil
+	}
+
+	// Claim the reload under the lock so that, even while the JWKS endpoint is failing,
+	// unknown key IDs cause at most one fetch per minRefreshGap
+	v.mu.Lock()
+	stale := time.Since(v.lastRefresh) > minRefreshGap
+	if stale {
+		v.lastRefresh = time.Now()
+	}
+	v.mu.Unlock()
+	if stale {
+		if err := v.Refresh(); err != nil {
+			log.Printf("Failed to refresh JWKS: %v", err)
+		}
+		if key, ok := v.lookup(kid); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookup finds a key by ID; tokens without kid are accepted when the JWKS holds a single key
+func (v *JWTVerifier) lookup(kid string) (crypto.PublicKey, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+// readJWKS reads the JWKS from its file or URL
+func (v *JWTVerifier) readJWKS() ([]byte, error) {
+	source := v.config.JWKSSource
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// rolesFrom reads the roles claim, given either as a list of strings or as a
+// space-separated string like the OAuth "scope" claim
+func rolesFrom(claims jwt.MapClaims, path string) []string {
+	var value interface{} = map[string]interface{}(claims)
+	for _, part := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[part]
+	}
+
+	switch roles := value.(type) {
+	case string:
+		return strings.Fields(roles)
+	case []interface{}:
+		result := make([]string, 0, len(roles))
+		for _, role := range roles {
+			if name, ok := role.(string); ok {
+				result = append(result, name)
+			}
+		}
+		return result
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"errors"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v8"
 	"github.com/reactivex/rxgo/v2"
 	"time"
@@ -15,23 +16,56 @@ var (
 
 // InitRedis initializes the Redis client
 func InitRedis(address, password string, db int) {
+	if err := ConnectRedis(address, password, db); err != nil {
+		panic("Failed to connect to Redis: " + err.Error())
+	}
+}
+
+// ConnectRedis initializes the Redis client and reports whether the server answers
+func ConnectRedis(address, password string, db int) error {
 	rdb = redis.NewClient(&redis.Options{
 		Addr:     address,
 		Password: password, // Leave empty if no authentication is used
 		DB:       db,       // Database number
 	})
 
-	// Optional: Test the Redis connection to ensure it's working
-	_, err := rdb.Ping(ctx).Result()
+	// Test the Redis connection to ensure it's working
+	return rdb.Ping(ctx).Err()
+}
+
+// embeddedClockStep is how often the clock of the embedded Redis is advanced
+const embeddedClockStep = time.Second
+
+// InitEmbeddedRedis serves the cache from a Redis running inside the process, for a single
+// replica without a Redis server. Nothing is shared with other replicas or survives a
+// restart.
+func InitEmbeddedRedis() error {
+	server, err := miniredis.Run()
 	if err != nil {
-		panic("Failed to connect to Redis: " + err.Error())
+		return err
 	}
+	rdb = redis.NewClient(&redis.Options{Addr: server.Addr()})
+
+	// The embedded server only expires keys when its clock is advanced
+	go func() {
+		ticker := time.NewTicker(embeddedClockStep)
+		defer ticker.Stop()
+		last := time.Now()
+		for now := range ticker.C {
+			server.FastForward(now.Sub(last))
+			last = now
+		}
+	}()
+	return nil
 }
 
-// SetURL stores a URL in the Redis cache reactively
-func SetURL(shortID string, originalURL string) rxgo.Observable {
+// DefaultURLTTL is how long a URL stays cached when the link itself never expires
+const DefaultURLTTL = 24 * time.Hour
+
+// SetURL stores a URL in the Redis cache reactively for the given time to live
+func SetURL(shortID string, originalURL string, ttl time.Duration) rxgo.Observable {
 	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
-		err := rdb.Set(ctx, shortID, originalURL, 24*time.Hour).Err()
+		err := rdb.Set(ctx, shortID, originalURL, ttl).Err()
 		if err != nil {
 			ch <- rxgo.Error(err)
 		} else {
@@ -72,6 +106,29 @@ func IncrementURLCounter(key string) error {
 	return err
 }
 
+// claimClickScript increments a click counter only while it is below the limit in ARGV[1].
+// It returns the new count, or -1 when the limit had already been reached.
+var claimClickScript = redis.NewScript(`
+local used = tonumber(redis.call('GET', KEYS[1]) or '0')
+if used >= tonumber(ARGV[1]) then
+	return -1
+end
+return redis.call('INCR', KEYS[1])
+`)
+
+// ClaimClick atomically consumes one click of a limited link. It returns the number of
+// clicks used including this one, and false when no clicks were left.
+func ClaimClick(key string, limit int64) (int64, bool, error) {
+	used, err := claimClickScript.Run(ctx, rdb, []string{key}, limit).Int64()
+	if err != nil {
+		return 0, false, err
+	}
+	if used < 0 {
+		return limit, false, nil
+	}
+	return used, true, nil
+}
+
 // SetLastAccess sets the timestamp of the last access for a specific short URL in Redis
 func SetLastAccess(key string, timestamp string) error {
 	return rdb.Set(ctx, key, timestamp, 0).Err()
@@ -86,3 +143,126 @@ func GetLastAccess(key string) (string, error) {
 	}
 	return lastAccess, err
 }
+
+// NextSequence atomically increments a named counter in Redis and returns its new value
+func NextSequence(key string) (int64, error) {
+	return rdb.Incr(ctx, key).Result()
+}
+
+// DeleteKeys removes several keys from Redis at once; missing keys are ignored
+func DeleteKeys(keys ...string) error {
+	return rdb.Del(ctx, keys...).Err()
+}
+
+// ScanKeys calls fn for every key matching the glob pattern, stopping at the first error
+func ScanKeys(pattern string, fn func(key string) error) error {
+	iter := rdb.Scan(ctx, 0, pattern, 0).Iterator()
+	for iter.Next(ctx) {
+		if err := fn(iter.Val()); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+// RenameKey moves a key to a new name unless that name is already taken, returning whether
+// the key was moved
+func RenameKey(from, to string) (bool, error) {
+	return rdb.RenameNX(ctx, from, to).Result()
+}
+
+// AddToSet adds a member to a Redis set
+func AddToSet(key, member string) error {
+	return rdb.SAdd(ctx, key, member).Err()
+}
+
+// PopFromSet removes and returns up to count random members of a Redis set
+func PopFromSet(key string, count int64) ([]string, error) {
+	members, err := rdb.SPopN(ctx, key, count).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return members, err
+}
+
+// IncrementWithTTL increments a counter and (re)sets its time to live, returning the new value
+func IncrementWithTTL(key string, ttl time.Duration) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// GetCounter returns the value of a counter and how long until it expires; missing
+// counters are 0
+func GetCounter(key string) (int64, time.Duration, error) {
+	var get *redis.StringCmd
+	var ttl *redis.DurationCmd
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		ttl = pipe.PTTL(ctx, key)
+		return nil
+	})
+	if errors.Is(err, redis.Nil) {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, err
+	}
+	value, err := get.Int64()
+	return value, ttl.Val(), err
+}
+
+// DecrementCounter decrements a counter in Redis
+func DecrementCounter(key string) error {
+	return rdb.Decr(ctx, key).Err()
+}
+
+// rateLimitScript implements the generic cell rate algorithm, a token bucket stored as the
+// theoretical arrival time (TAT) of the next request in milliseconds. ARGV[1] is the number
+// of requests allowed per period, ARGV[2] the period in milliseconds. The clock of Redis is
+// used so every replica agrees on the time. It returns {allowed, remaining, retry after ms,
+// reset after ms}.
+var rateLimitScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local interval = period / limit
+local clock = redis.call('TIME')
+local now = tonumber(clock[1]) * 1000 + math.floor(tonumber(clock[2]) / 1000)
+local tat = math.max(tonumber(redis.call('GET', KEYS[1]) or now), now)
+local allowAt = tat + interval - period
+if now < allowAt then
+	return {0, 0, math.ceil(allowAt - now), math.ceil(tat - now)}
+end
+local newTat = tat + interval
+redis.call('SET', KEYS[1], tostring(newTat), 'PX', math.ceil(newTat - now))
+return {1, math.floor((now - newTat + period) / interval), 0, math.ceil(newTat - now)}
+`)
+
+// RateLimitResult is the outcome of consuming one request from a rate limit bucket
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int64         // Requests left in the bucket after this one
+	RetryAfter time.Duration // How long to wait before retrying a rejected request
+	ResetAfter time.Duration // How long until the bucket is full again
+}
+
+// ConsumeRateLimit takes one request from the bucket at key, which allows limit requests
+// per period across every replica
+func ConsumeRateLimit(key string, limit int64, period time.Duration) (RateLimitResult, error) {
+	values, err := rateLimitScript.Run(ctx, rdb, []string{key}, limit, period.Milliseconds()).Int64Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	return RateLimitResult{
+		Allowed:    values[0] == 1,
+		Remaining:  values[1],
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
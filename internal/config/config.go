@@ -4,19 +4,67 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"time"
 	"urlshortener/internal/models"
 )
 
 // LoadConfig loads configuration from environment variables or default values
 func LoadConfig() *models.Config {
 	config := &models.Config{
-		Port:            getEnv("PORT", "8080"),
-		MongoURI:        getEnv("MONGO_URI", "mongodb://mongo:27017"), // Change localhost to mongo
-		MongoDBName:     getEnv("MONGO_DB_NAME", "urlshortener"),
-		MongoCollection: getEnv("MONGO_COLLECTION", "urls"),
-		RedisAddress:    getEnv("REDIS_ADDRESS", "redis:6379"),
-		RedisPassword:   getEnv("REDIS_PASSWORD", ""), // No password by default
-		RedisDB:         getEnvAsInt("REDIS_DB", 0),
+		Port:                getEnv("PORT", "8080"),
+		BaseURL:             getEnv("BASE_URL", "http://localhost:8080"),             // Scheme, host and optional path prefix of short links
+		BaseURLFromRequest:  getEnvAsBool("BASE_URL_FROM_REQUEST", false),            // Derive links from Host/X-Forwarded-* headers
+		StorageDriver:       getEnv("STORAGE_DRIVER", "mongo"),                       // mongo, memory, sqlite or postgres
+		ExpirySweepInterval: getEnvAsDuration("EXPIRY_SWEEP_INTERVAL", time.Minute),  // How often expired (memory/SQL) and trashed links are purged
+		TrashRetention:      getEnvAsDuration("TRASH_RETENTION", 30*24*time.Hour),    // How long deleted links can be restored
+		ClickSyncInterval:   getEnvAsDuration("CLICK_SYNC_INTERVAL", 30*time.Second), // How often Redis click counters are copied to storage
+		SQLDSN:              getEnv("SQL_DSN", "urlshortener.db"),                    // SQLite file, ":memory:" or a postgres:// URL
+		MongoURI:            getEnv("MONGO_URI", "mongodb://mongo:27017"),            // Change localhost to mongo
+		MongoDBName:         getEnv("MONGO_DB_NAME", "urlshortener"),
+		MongoCollection:     getEnv("MONGO_COLLECTION", "urls"),
+		APIKeyCollection:    getEnv("MONGO_API_KEY_COLLECTION", "api_keys"),
+		ReportCollection:    getEnv("MONGO_REPORT_COLLECTION", "reports"),
+		BanCollection:       getEnv("MONGO_BANNED_DOMAIN_COLLECTION", "banned_domains"),
+		RedisAddress:        getEnv("REDIS_ADDRESS", "redis:6379"),
+		RedisPassword:       getEnv("REDIS_PASSWORD", ""), // No password by default
+		RedisDB:             getEnvAsInt("REDIS_DB", 0),
+		IDStrategy:          getEnv("ID_STRATEGY", "hash"), // hash, random or counter
+		IDLength:            getEnvAsInt("ID_LENGTH", 6),
+		IDAlphabet:          getEnv("ID_ALPHABET", "base62"),    // base62 or base58
+		AuthEnabled:         getEnvAsBool("AUTH_ENABLED", true), // Require API keys on every route except redirects
+		AdminAPIKey:         getEnv("ADMIN_API_KEY", ""),        // Bootstrap admin key used to issue the first keys
+		JWKSSource:          getEnv("JWT_JWKS", ""),             // JWKS file or URL; empty disables JWT bearer tokens
+		JWKSRefreshInterval: getEnvAsDuration("JWKS_REFRESH_INTERVAL", 15*time.Minute),
+		JWTIssuer:           getEnv("JWT_ISSUER", ""),
+		JWTAudience:         getEnv("JWT_AUDIENCE", ""),
+		JWTRolesClaim:       getEnv("JWT_ROLES_CLAIM", "roles"), // Claim listing the granted scopes, e.g. realm_access.roles
+		JWTTenantClaim:      getEnv("JWT_TENANT_CLAIM", "tenant"),
+		TenantMaxLinks:      getEnvAsInt("TENANT_MAX_LINKS", 0),       // Live links per tenant, 0 for unlimited
+		TenantDailyLinks:    getEnvAsInt("TENANT_MAX_DAILY_LINKS", 0), // Links created per tenant and UTC day, 0 for unlimited
+		TenantQuotas:        getEnv("TENANT_QUOTAS", ""),              // JSON overrides per tenant, e.g. {"marketing":{"max_links":1000}}
+		RateLimitEnabled:    getEnvAsBool("RATE_LIMIT_ENABLED", true),
+		RateLimitShorten:    getEnvAsRateLimit("RATE_LIMIT_SHORTEN", "60/1m"), // Requests per client, "0" for unlimited
+		RateLimitRedirect:   getEnvAsRateLimit("RATE_LIMIT_REDIRECT", "1200/1m"),
+		RateLimitStats:      getEnvAsRateLimit("RATE_LIMIT_STATS", "120/1m"),
+		RateLimitReport:     getEnvAsRateLimit("RATE_LIMIT_REPORT", "10/1h"),
+		TrustedProxies:      getEnv("TRUSTED_PROXIES", ""),               // Comma-separated proxies whose X-Forwarded-For is believed
+		DestinationSchemes:  getEnv("DESTINATION_SCHEMES", "http,https"), // Comma-separated schemes original URLs may use
+		DestinationMaxLen:   getEnvAsInt("DESTINATION_MAX_LENGTH", 2048),
+		SortQuery:           getEnvAsBool("DESTINATION_SORT_QUERY", false),     // Sort query parameters when canonicalizing
+		StripFragment:       getEnvAsBool("DESTINATION_STRIP_FRAGMENT", false), // Drop #fragments when canonicalizing
+		DomainBlocklist:     getEnv("DOMAIN_BLOCKLIST", ""),                    // Comma-separated domains that cannot be shortened
+		DomainAllowlist:     getEnv("DOMAIN_ALLOWLIST", ""),                    // Comma-separated domains; when set, the only ones that can be shortened
+		ThreatListFile:      getEnv("THREAT_LIST_FILE", ""),                    // File of SHA-256 hash prefixes of phishing and malware URLs
+		ThreatListReload:    getEnvAsDuration("THREAT_LIST_RELOAD_INTERVAL", time.Minute),
+		ShortDomains:        getEnv("SHORT_DOMAINS", ""),              // Comma-separated hosts serving our links besides the BASE_URL one
+		SelfLinks:           getEnv("SELF_LINKS", "resolve"),          // resolve or reject destinations that are our own links
+		RejectShorteners:    getEnvAsBool("REJECT_SHORTENERS", false), // Reject links of known third-party shorteners
+		BanSyncInterval:     getEnvAsDuration("BANNED_DOMAIN_SYNC_INTERVAL", time.Minute),
+		WarnRawIPs:          getEnvAsBool("WARN_RAW_IPS", false),           // Show a warning page before redirecting to IP addresses
+		WarnTLDs:            getEnv("WARN_TLDS", ""),                       // Comma-separated top-level domains that get a warning page
+		WarnGenerated:       getEnvAsBool("WARN_GENERATED_DOMAINS", false), // Show a warning page for domains that look newly registered
+		PasswordPerLink:     getEnvAsRateLimit("PASSWORD_FAILURES_PER_LINK", "20/15m"),
+		PasswordPerIP:       getEnvAsRateLimit("PASSWORD_FAILURES_PER_IP", "10/15m"),
 	}
 
 	log.Println("Configuration loaded successfully")
@@ -45,3 +93,46 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return value
 }
+
+// getEnvAsBool retrieves an environment variable as a boolean or returns a default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		log.Printf("Invalid value for %s; using default: %t", key, defaultValue)
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsDuration retrieves an environment variable as a duration (e.g. "30s", "5m") or returns a default value
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := time.ParseDuration(valueStr)
+	if err != nil {
+		log.Printf("Invalid value for %s; using default: %s", key, defaultValue)
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsRateLimit retrieves an environment variable as a rate limit (e.g. "60/1m") or returns a default value
+func getEnvAsRateLimit(key string, defaultValue string) models.RateLimit {
+	fallback, _ := models.ParseRateLimit(defaultValue)
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return fallback
+	}
+	value, err := models.ParseRateLimit(valueStr)
+	if err != nil {
+		log.Printf("Invalid value for %s; using default: %s", key, defaultValue)
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// API key scopes. ScopeAdmin implies every other scope.
+const (
+	ScopeCreate    = "create"     // Shorten URLs
+	ScopeManage    = "manage"     // List, inspect, update, toggle, delete and restore URLs
+	ScopeReadStats = "read-stats" // Read URL and system statistics
+	ScopeModerate  = "moderate"   // Review abuse reports and act on reported links
+	ScopeAdmin     = "admin"      // Issue and revoke API keys
+)
+
+// APIKey is an API credential. Only the SHA-256 hash of the secret token is stored.
+type APIKey struct {
+	ID        string     `json:"id" bson:"id"`                                     // Public identifier used to revoke the key
+	Name      string     `json:"name" bson:"name"`                                 // Human readable label
+	Prefix    string     `json:"prefix" bson:"prefix"`                             // First characters of the token, to recognise it
+	Hash      string     `json:"-" bson:"key_hash"`                                // Hex SHA-256 of the token
+	Tenant    string     `json:"tenant" bson:"tenant"`                             // Workspace whose links the key acts on
+	Scopes    []string   `json:"scopes" bson:"scopes"`                             // Operations the key may perform
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`                     // Moment the key was issued
+	CreatedBy string     `json:"created_by,omitempty" bson:"created_by,omitempty"` // Identity that issued the key
+	RevokedAt *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"` // Moment the key was revoked, nil while it is active
+}
+
+// HasScope reports whether the key grants scope, either directly or through ScopeAdmin
+func (k APIKey) HasScope(scope string) bool {
+	return GrantsScope(k.Scopes, scope)
+}
+
+// GrantsScope reports whether scopes include scope, either directly or through ScopeAdmin.
+// JWT roles are checked the same way, with role names equal to scope names.
+func GrantsScope(scopes []string, scope string) bool {
+	return slices.Contains(scopes, scope) || slices.Contains(scopes, ScopeAdmin)
+}
+
+// IsValidScope reports whether scope is one of the known API key scopes
+func IsValidScope(scope string) bool {
+	switch scope {
+	case ScopeCreate, ScopeManage, ScopeReadStats, ScopeModerate, ScopeAdmin:
+		return true
+	}
+	return false
+}
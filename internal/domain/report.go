@@ -0,0 +1,63 @@
+package domain
+
+import "time"
+
+// Reasons a link can be reported for
+const (
+	ReportPhishing = "phishing"
+	ReportMalware  = "malware"
+	ReportSpam     = "spam"
+	ReportOther    = "other"
+)
+
+// Report statuses. Reports stay open until a moderator acts on the link.
+const (
+	ReportOpen         = "open"
+	ReportDismissed    = "dismissed"
+	ReportDisabled     = "disabled"
+	ReportWarned       = "warned"
+	ReportDomainBanned = "domain_banned"
+	ReportPurged       = "purged" // The link was permanently deleted
+)
+
+// IsValidReportReason reports whether reason is one of the known report reasons
+func IsValidReportReason(reason string) bool {
+	switch reason {
+	case ReportPhishing, ReportMalware, ReportSpam, ReportOther:
+		return true
+	}
+	return false
+}
+
+// Report is an abuse report filed against a link by a member of the public
+type Report struct {
+	ID            string     `json:"id" bson:"id"`
+	ShortID       string     `json:"short_id" bson:"short_id"`                                 // Reported link
+	Tenant        string     `json:"tenant" bson:"tenant"`                                     // Tenant of the reported link, whose moderators handle it
+	Reason        string     `json:"reason" bson:"reason"`                                     // phishing, malware, spam or other
+	Details       string     `json:"details,omitempty" bson:"details,omitempty"`               // Free text from the reporter
+	ReporterIP    string     `json:"reporter_ip" bson:"reporter_ip"`                           // Address the report came from
+	ReporterAgent string     `json:"reporter_agent,omitempty" bson:"reporter_agent,omitempty"` // User-Agent of the reporter
+	ReporterEmail string     `json:"reporter_email,omitempty" bson:"reporter_email,omitempty"` // Optional contact address
+	CreatedAt     time.Time  `json:"created_at" bson:"created_at"`
+	Status        string     `json:"status" bson:"status"`                           // open until a moderator acts, then the action taken
+	ClosedAt      *time.Time `json:"closed_at,omitempty" bson:"closed_at,omitempty"` // Moment a moderator acted, nil while open
+	ClosedBy      string     `json:"closed_by,omitempty" bson:"closed_by,omitempty"` // Moderator that acted
+}
+
+// ReportedURL summarizes the open reports of a link in the moderation queue
+type ReportedURL struct {
+	ShortID        string    `json:"short_id" bson:"_id"`
+	ReportCount    int64     `json:"report_count" bson:"report_count"`
+	Reasons        []string  `json:"reasons" bson:"reasons"` // Distinct reasons given, sorted
+	LastReportedAt time.Time `json:"last_reported_at" bson:"last_reported_at"`
+	URL            *URL      `json:"url,omitempty" bson:"-"` // The reported link, when it still exists
+}
+
+// BannedDomain is a destination domain moderators banned; neither it nor its subdomains
+// can be shortened, and links to them are quarantined
+type BannedDomain struct {
+	Domain   string    `json:"domain" bson:"domain"`
+	BannedAt time.Time `json:"banned_at" bson:"banned_at"`
+	BannedBy string    `json:"banned_by,omitempty" bson:"banned_by,omitempty"`
+}
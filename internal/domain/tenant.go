@@ -0,0 +1,21 @@
+package domain
+
+import "regexp"
+
+// DefaultTenant owns the links and API keys stored before workspaces existed, and those
+// of callers that do not name a tenant. Its admins manage the API keys of every tenant.
+const DefaultTenant = "default"
+
+// tenantPattern restricts tenant IDs to values that are safe inside Redis keys
+var tenantPattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// IsValidTenant reports whether tenant is a well-formed tenant ID
+func IsValidTenant(tenant string) bool {
+	return tenantPattern.MatchString(tenant)
+}
+
+// TenantQuota limits what a tenant may create. Zero means unlimited.
+type TenantQuota struct {
+	MaxLinks          int64 `json:"max_links"`           // Live (not trashed) links at any time
+	MaxDailyCreations int64 `json:"max_daily_creations"` // Links created per UTC day
+}
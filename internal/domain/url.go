@@ -1,9 +1,50 @@
 package domain
 
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
 // URL represents the structure of a shortened URL in the system
 type URL struct {
-	ID          string `json:"id" bson:"id"`                     // Unique identifier for the shortened URL
-	OriginalURL string `json:"original_url" bson:"original_url"` // The full original URL
-	ShortURL    string `json:"short_url" bson:"short_url"`       // The generated shortened URL
-	Enabled     bool   `json:"enabled" bson:"enabled"`           // URL status (enabled or disabled)
+	ID           string     `json:"id" bson:"id"`                                     // Unique identifier for the shortened URL
+	OriginalURL  string     `json:"original_url" bson:"original_url"`                 // The full original URL
+	ShortURL     string     `json:"short_url" bson:"short_url"`                       // The generated shortened URL
+	Enabled      bool       `json:"enabled" bson:"enabled"`                           // URL status (enabled or disabled)
+	Quarantine   string     `json:"quarantine,omitempty" bson:"quarantine,omitempty"` // Why the safety checks disabled the link, empty unless quarantined
+	Warning      string     `json:"warning,omitempty" bson:"warning,omitempty"`       // Why redirects show a warning page first, empty for direct redirects
+	PasswordHash string     `json:"-" bson:"password_hash,omitempty"`                 // Salted bcrypt hash of the password asked before redirecting, empty for public links
+	ExpiresAt    *time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"` // Moment the link stops redirecting, nil if it never expires
+	MaxClicks    int64      `json:"max_clicks,omitempty" bson:"max_clicks,omitempty"` // Number of redirects allowed, 0 for unlimited
+	DeletedAt    *time.Time `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"` // Moment the link was moved to the trash, nil if it is live
+	CreatedAt    time.Time  `json:"created_at" bson:"created_at"`                     // Moment the link was created
+	Domain       string     `json:"domain" bson:"domain"`                             // Lowercase host of OriginalURL, kept for filtering
+	Tenant       string     `json:"tenant" bson:"tenant"`                             // Workspace the link belongs to
+	Owner        string     `json:"owner,omitempty" bson:"owner,omitempty"`           // Identity that owns the link, empty when unknown
+	Tags         []string   `json:"tags,omitempty" bson:"tags,omitempty"`             // Free-form labels used to group links
+	ClickCount   int64      `json:"click_count" bson:"click_count"`                   // Redirects counted so far, synced periodically from Redis
+	UpdatedAt    time.Time  `json:"updated_at" bson:"updated_at"`                     // Moment of the latest change
+	CreatedBy    string     `json:"created_by,omitempty" bson:"created_by,omitempty"` // Identity that created the link, empty when unknown
+	UpdatedBy    string     `json:"updated_by,omitempty" bson:"updated_by,omitempty"` // Identity behind the latest change, empty when unknown
+}
+
+// IsExpired reports whether the link has reached its expiration time
+func (u URL) IsExpired(now time.Time) bool {
+	return u.ExpiresAt != nil && !now.Before(*u.ExpiresAt)
+}
+
+// HasPassword reports whether a password is asked before redirecting
+func (u URL) HasPassword() bool {
+	return u.PasswordHash != ""
+}
+
+// DestinationHost returns the lowercase host name of a destination URL without its
+// port, or an empty string when it cannot be parsed
+func DestinationHost(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
 }
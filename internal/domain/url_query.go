@@ -0,0 +1,45 @@
+package domain
+
+import "time"
+
+// Sort fields accepted by URLQuery
+const (
+	SortByCreatedAt  = "created_at"
+	SortByClickCount = "click_count"
+)
+
+// URLQuery describes a page of live (not trashed) URLs of a tenant to list. Empty filters
+// match everything.
+type URLQuery struct {
+	Tenant      string     // Tenant owning the links; always applied
+	Enabled     *bool      // Only enabled or only disabled links
+	CreatedFrom *time.Time // Created at or after this moment
+	CreatedTo   *time.Time // Created before this moment
+	Domain      string     // Exact destination host, see DestinationHost
+	Owner       string     // Exact owner
+	Tag         string     // Links carrying this tag
+	Search      string     // Case-insensitive substring of the destination
+	SortBy      string     // SortByCreatedAt or SortByClickCount
+	Descending  bool       // Sort from newest or most clicked
+	Limit       int        // Maximum number of URLs in the page
+	After       *URLCursor // Position of the last URL of the previous page, nil for the first page
+}
+
+// URLCursor is the keyset position of a URL within a sorted listing
+type URLCursor struct {
+	CreatedAt  time.Time `json:"created_at,omitempty"`
+	ClickCount int64     `json:"click_count,omitempty"`
+	ID         string    `json:"id"`
+}
+
+// URLPage is one page of a URL listing
+type URLPage struct {
+	URLs    []URL // URLs in sort order
+	Total   int64 // Number of URLs matching the filters across all pages
+	HasMore bool  // Whether another page follows
+}
+
+// CursorOf returns the keyset position of url
+func CursorOf(url URL) URLCursor {
+	return URLCursor{CreatedAt: url.CreatedAt, ClickCount: url.ClickCount, ID: url.ID}
+}
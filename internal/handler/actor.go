@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/service"
+)
+
+// ActorKey is the gin context key under which authentication stores the caller identity
+const ActorKey = "actor"
+
+// AnonymousActor is recorded for requests that carry no identity
+const AnonymousActor = "anonymous"
+
+// requestActor returns the identity of the caller to record on the URLs it changes
+func requestActor(c *gin.Context) string {
+	if actor := c.GetString(ActorKey); actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
+
+// requestTenant returns the tenant of the caller, whose links the request may act on
+func requestTenant(c *gin.Context) string {
+	if tenant := c.GetString(TenantKey); tenant != "" {
+		return tenant
+	}
+	return domain.DefaultTenant
+}
+
+// requestIsAdmin reports whether the caller may act on every URL of its tenant: it was granted the admin
+// scope, or authentication is disabled and no scopes were checked at all
+func requestIsAdmin(c *gin.Context) bool {
+	scopes, authenticated := c.Get(ScopesKey)
+	if !authenticated {
+		return true
+	}
+	return domain.GrantsScope(scopes.([]string), domain.ScopeAdmin)
+}
+
+// authorizeURL aborts the request with 403 unless the caller owns the URL id or is an admin,
+// and with 404 when the URL belongs to another tenant
+func authorizeURL(c *gin.Context, id string) bool {
+	if err := service.AuthorizeURL(id, requestTenant(c), requestActor(c), requestIsAdmin(c)); err != nil {
+		respondError(c, err, http.StatusInternalServerError, "Failed to check URL ownership")
+		c.Abort()
+		return false
+	}
+	return true
+}
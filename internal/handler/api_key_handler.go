@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/reactivex/rxgo/v2"
+	"net/http"
+	"urlshortener/internal/request"
+	"urlshortener/internal/service"
+)
+
+type APIKeyHandler struct{}
+
+// NewAPIKeyHandler creates a new instance of APIKeyHandler
+func NewAPIKeyHandler() *APIKeyHandler {
+	return &APIKeyHandler{}
+}
+
+func (h *APIKeyHandler) IssueAPIKeyHandler(c *gin.Context) {
+	var req request.IssueAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body must include a name and scopes"})
+		return
+	}
+	actor := requestActor(c)
+
+	key, token, err := service.IssueAPIKey(req, requestTenant(c), actor)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError, "Failed to issue API key")
+		return
+	}
+
+	// The token is only ever shown in this response
+	c.JSON(http.StatusCreated, gin.H{"api_key": key, "token": token})
+}
+
+func (h *APIKeyHandler) ListAPIKeysHandler(c *gin.Context) {
+	// ?tenant= lists the keys of another tenant, for admins of the default tenant
+	keys, err := service.ListAPIKeys(requestTenant(c), c.Query("tenant"))
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError, "Failed to list API keys")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+func (h *APIKeyHandler) RevokeAPIKeyHandler(c *gin.Context) {
+	tenant := requestTenant(c)
+	observable := rxgo.Just(c.Param("id"))().
+		Map(func(_ context.Context, item interface{}) (interface{}, error) {
+			// Calls the service to revoke the key
+			key, err := service.RevokeAPIKey(item.(string), tenant)
+			return key, err
+		})
+	result := <-observable.Observe()
+	if result.E != nil {
+		respondError(c, result.E, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+	c.JSON(http.StatusOK, result.V)
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"regexp"
+	"strings"
+)
+
+var (
+	// forwardedHostPattern accepts a host name or address with an optional port
+	forwardedHostPattern = regexp.MustCompile(`^[A-Za-z0-9.\-\[\]:]+$`)
+	// forwardedPrefixPattern accepts a plain path prefix such as /links
+	forwardedPrefixPattern = regexp.MustCompile(`^(/[A-Za-z0-9._~\-]+)*/?$`)
+)
+
+// requestBaseURL derives the public base URL from the Host and the X-Forwarded-Proto,
+// X-Forwarded-Host and X-Forwarded-Prefix headers set by the reverse proxy. It returns
+// an empty string, meaning the configured base URL, when the request does not come from a
+// trusted proxy or the headers look malformed.
+func requestBaseURL(c *gin.Context) string {
+	if !fromTrustedProxy(c) {
+		return ""
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := firstHeaderValue(c, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+
+	host := c.Request.Host
+	if forwardedHost := firstHeaderValue(c, "X-Forwarded-Host"); forwardedHost != "" {
+		host = forwardedHost
+	}
+	if !forwardedHostPattern.MatchString(host) {
+		return ""
+	}
+
+	prefix := firstHeaderValue(c, "X-Forwarded-Prefix")
+	if !forwardedPrefixPattern.MatchString(prefix) {
+		return ""
+	}
+
+	return scheme + "://" + host + strings.TrimRight(prefix, "/")
+}
+
+// firstHeaderValue returns the first entry of a possibly comma separated header added by proxies
+func firstHeaderValue(c *gin.Context, name string) string {
+	value, _, _ := strings.Cut(c.GetHeader(name), ",")
+	return strings.TrimSpace(value)
+}
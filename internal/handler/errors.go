@@ -0,0 +1,18 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"urlshortener/internal/models"
+)
+
+// respondError writes an APIError with its own status code, or the fallback status
+// and message for any other error
+func respondError(c *gin.Context, err error, fallbackCode int, fallbackMessage string) {
+	var apiErr *models.APIError
+	if errors.As(err, &apiErr) {
+		c.JSON(apiErr.Code, gin.H{"error": apiErr.Message})
+		return
+	}
+	c.JSON(fallbackCode, gin.H{"error": fallbackMessage})
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"html/template"
+	"log"
+	"net/http"
+)
+
+// interstitialTemplate is the warning page shown before following a flagged link. Continuing
+// posts the form back to the link, so plain GETs, such as link previews, never redirect.
+var interstitialTemplate = template.Must(template.New("interstitial").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Check this link before you continue</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 40rem; margin: 4rem auto; padding: 0 1rem; color: #222; }
+.destination { word-break: break-all; padding: .75rem; background: #f4f4f4; border-radius: .25rem; }
+button { font-size: 1rem; padding: .5rem 1.25rem; margin-top: 1rem; cursor: pointer; }
+</style>
+</head>
+<body>
+<h1>Check this link before you continue</h1>
+<p>Why you are seeing this page: {{.Warning}}.</p>
+<p>It leads to:</p>
+<p class="destination"><code>{{.Destination}}</code></p>
+<p>Only continue if you trust this destination.</p>
+<form method="post">
+<input type="hidden" name="continue" value="1">
+<button type="submit">Continue to the destination</button>
+</form>
+</body>
+</html>
+`))
+
+// renderInterstitial writes the warning page for a link leading to destination
+func renderInterstitial(c *gin.Context, destination, warning string) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Status(http.StatusOK)
+	data := struct{ Destination, Warning string }{destination, warning}
+	if err := interstitialTemplate.Execute(c.Writer, data); err != nil {
+		log.Printf("Error rendering warning page: %v", err)
+	}
+}
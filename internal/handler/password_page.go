@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"urlshortener/internal/service"
+)
+
+// passwordHeader carries the password of a protected link for API clients
+const passwordHeader = "X-Link-Password"
+
+// passwordTemplate is the form shown before following a password-protected link. It posts
+// the password back to the link, which also confirms any warning shown on the page.
+var passwordTemplate = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>This link is protected</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 40rem; margin: 4rem auto; padding: 0 1rem; color: #222; }
+.error { color: #b00020; }
+input, button { font-size: 1rem; padding: .5rem; margin-top: 1rem; }
+button { padding: .5rem 1.25rem; cursor: pointer; }
+</style>
+</head>
+<body>
+<h1>This link is protected</h1>
+<p>Enter the password you were given to continue.</p>
+{{if .Warning}}<p>Check this link before you continue: {{.Warning}}.</p>
+{{end}}{{if .Error}}<p class="error">{{.Error}}</p>
+{{end}}<form method="post">
+<input type="password" name="password" autocomplete="off" autofocus required>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// renderPasswordPage writes the password form with the given status and error message, if any
+func renderPasswordPage(c *gin.Context, status int, message, warning string) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Status(status)
+	data := struct{ Error, Warning string }{message, warning}
+	if err := passwordTemplate.Execute(c.Writer, data); err != nil {
+		log.Printf("Error rendering password page: %v", err)
+	}
+}
+
+// linkPassword returns the password a client gives for a link and whether it came in the
+// header, as API clients do, rather than from the password form
+func linkPassword(c *gin.Context) (string, bool) {
+	if password := c.GetHeader(passwordHeader); password != "" {
+		return password, true
+	}
+	if c.Request.Method == http.MethodPost {
+		return c.PostForm("password"), false
+	}
+	return "", false
+}
+
+// respondPasswordError answers a redirect refused for lack of the right password: browsers
+// get the password form again, API clients a JSON error
+func respondPasswordError(c *gin.Context, err *service.PasswordError, fromHeader bool) {
+	var throttled *service.ThrottledError
+	if errors.As(err, &throttled) {
+		seconds := int(math.Ceil(throttled.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(max(seconds, 1)))
+	}
+
+	if fromHeader || c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) != gin.MIMEHTML {
+		respondError(c, err, http.StatusUnauthorized, "Password required")
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrPasswordRequired):
+		renderPasswordPage(c, http.StatusUnauthorized, "", err.Warning)
+	case throttled != nil:
+		renderPasswordPage(c, throttled.Code, throttled.Message, err.Warning)
+	default:
+		renderPasswordPage(c, http.StatusUnauthorized, "Wrong password, try again.", err.Warning)
+	}
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net"
+	"strings"
+)
+
+// trustedProxies holds the networks set by TrustProxies
+var trustedProxies []*net.IPNet
+
+// TrustProxies sets the comma-separated proxies whose X-Forwarded-For header gives the client
+// IP address. With none, the header is ignored and the address of the connection is used,
+// so clients cannot pick the IP address they are limited by. The other X-Forwarded-* headers
+// are only honoured from these proxies too.
+func TrustProxies(router *gin.Engine, proxies string) error {
+	var trusted []string
+	for _, proxy := range strings.Split(proxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trusted = append(trusted, proxy)
+		}
+	}
+	if err := router.SetTrustedProxies(trusted); err != nil {
+		return err
+	}
+
+	networks := make([]*net.IPNet, 0, len(trusted))
+	for _, proxy := range trusted {
+		// SetTrustedProxies has already validated every entry
+		if !strings.Contains(proxy, "/") {
+			if strings.Contains(proxy, ":") {
+				proxy += "/128"
+			} else {
+				proxy += "/32"
+			}
+		}
+		_, network, _ := net.ParseCIDR(proxy)
+		networks = append(networks, network)
+	}
+	trustedProxies = networks
+	return nil
+}
+
+// fromTrustedProxy reports whether the request was received from one of the trusted proxies
+func fromTrustedProxy(c *gin.Context) bool {
+	return isTrustedProxy(c.RemoteIP())
+}
+
+// throttledClientIP returns the client IP address to throttle the caller by, or an empty
+// string when it is the address of a trusted proxy: the proxy did not say who it forwards
+// for, and throttling its address would lock every client out at once.
+func throttledClientIP(c *gin.Context) string {
+	clientIP := c.ClientIP()
+	if isTrustedProxy(clientIP) {
+		return ""
+	}
+	return clientIP
+}
+
+// isTrustedProxy reports whether address belongs to one of the trusted proxies
+func isTrustedProxy(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"urlshortener/internal/request"
+	"urlshortener/internal/service"
+)
+
+type ReportHandler struct{}
+
+// NewReportHandler creates a new instance of ReportHandler
+func NewReportHandler() *ReportHandler {
+	return &ReportHandler{}
+}
+
+// ReportURLHandler files a public abuse report against a link
+func (h *ReportHandler) ReportURLHandler(c *gin.Context) {
+	var req request.ReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body must include a reason"})
+		return
+	}
+
+	report, err := service.ReportURL(c.Param("id"), req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError, "Failed to save report")
+		return
+	}
+	// Reporter metadata is kept for moderators only
+	c.JSON(http.StatusCreated, gin.H{"id": report.ID, "short_id": report.ShortID, "status": report.Status})
+}
+
+// ListReportedURLsHandler returns the moderation queue, most reported links first
+func (h *ReportHandler) ListReportedURLsHandler(c *gin.Context) {
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = parsed
+	}
+
+	// ?tenant= lists the queue of another tenant, for moderators of the default tenant
+	reportedURLs, err := service.ListReportedURLs(requestTenant(c), c.Query("tenant"), limit)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError, "Failed to list reported URLs")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reported_urls": reportedURLs})
+}
+
+// ModerateURLHandler dismisses the reports of a link, disables it or bans its destination domain
+func (h *ReportHandler) ModerateURLHandler(c *gin.Context) {
+	var req request.ModerationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body must include an action"})
+		return
+	}
+
+	result, err := service.ModerateURL(c.Param("id"), req.Action, requestTenant(c), requestActor(c))
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError, "Failed to moderate URL")
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ListBannedDomainsHandler returns every banned destination domain
+func (h *ReportHandler) ListBannedDomainsHandler(c *gin.Context) {
+	bans, err := service.ListBannedDomains()
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError, "Failed to list banned domains")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"banned_domains": bans})
+}
+
+// UnbanDomainHandler lifts the ban on a destination domain
+func (h *ReportHandler) UnbanDomainHandler(c *gin.Context) {
+	if err := service.UnbanDomain(c.Param("domain"), requestTenant(c)); err != nil {
+		respondError(c, err, http.StatusInternalServerError, "Failed to lift domain ban")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
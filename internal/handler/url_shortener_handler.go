@@ -5,15 +5,20 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/reactivex/rxgo/v2"
+	"log"
 	"net/http"
+	"strings"
+	"urlshortener/internal/domain"
 	"urlshortener/internal/interfaces"
-	"urlshortener/internal/models"
 	"urlshortener/internal/request"
 	"urlshortener/internal/service"
 )
 
 type URLShortenerHandler struct {
 	StatService interfaces.URLStatService
+	// BaseURLFromRequest builds short links from the request Host and X-Forwarded-* headers
+	// of trusted proxies instead of the configured base URL
+	BaseURLFromRequest bool
 }
 
 // NewURLShortenerHandler creates a new instance of URLShortenerService
@@ -28,21 +33,23 @@ func (s *URLShortenerHandler) ShortenURLHandler(c *gin.Context) {
 		return
 	}
 
-	observable := rxgo.Just(req.OriginalURL)().
+	// Leave the base URL empty to use the configured one
+	baseURL := ""
+	if s.BaseURLFromRequest {
+		baseURL = requestBaseURL(c)
+	}
+	tenant, actor := requestTenant(c), requestActor(c)
+
+	observable := rxgo.Just(req)().
 		Map(func(_ context.Context, item interface{}) (interface{}, error) {
 			// Calls the URL shortening service
-			shortURL, err := service.CreateShortURL(item.(string))
+			shortURL, err := service.CreateShortURL(item.(request.ShortenRequest), baseURL, tenant, actor)
 			return shortURL, err
 		})
 
 	result := <-observable.Observe()
 	if result.E != nil {
-		var apiErr *models.APIError
-		if errors.As(result.E, &apiErr) {
-			c.JSON(apiErr.Code, gin.H{"error": apiErr.Message})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to shorten URL"})
-		}
+		respondError(c, result.E, http.StatusInternalServerError, "Failed to shorten URL")
 		return
 	}
 
@@ -52,42 +59,238 @@ func (s *URLShortenerHandler) ShortenURLHandler(c *gin.Context) {
 func (s *URLShortenerHandler) RedirectURLHandler(c *gin.Context) {
 	id := c.Param("id")
 
+	// A trailing '+' previews the link instead of following it
+	if shortID, ok := strings.CutSuffix(id, "+"); ok {
+		s.respondURLDetails(c, shortID, true)
+		return
+	}
+
+	s.redirect(c, id, false)
+}
+
+// ContinueRedirectHandler follows a link once its warning page was confirmed
+func (s *URLShortenerHandler) ContinueRedirectHandler(c *gin.Context) {
+	s.redirect(c, c.Param("id"), true)
+}
+
+// redirect sends the client to the destination of the link id or, when the link has a
+// warning that was not confirmed yet or a password that was not given, shows the warning
+// page or the password form instead
+func (s *URLShortenerHandler) redirect(c *gin.Context, id string, confirmed bool) {
+	password, fromHeader := linkPassword(c)
+	access := service.RedirectAccess{Confirmed: confirmed, Password: password, ClientIP: throttledClientIP(c)}
 	observable := rxgo.Just(id)().
 		Map(func(_ context.Context, item interface{}) (interface{}, error) {
 			// Calls the service to resolve the original URL
-			originalURL, err := service.ResolveURL(item.(string))
-			return originalURL, err
+			redirect, err := service.ResolveRedirect(item.(string), access)
+			return redirect, err
 		})
 
 	result := <-observable.Observe()
+	var passwordErr *service.PasswordError
+	if errors.As(result.E, &passwordErr) {
+		respondPasswordError(c, passwordErr, fromHeader)
+		return
+	}
 	if result.E != nil || result.V == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		respondError(c, result.E, http.StatusNotFound, "URL not found")
 		return
 	}
+	redirect := result.V.(service.Redirect)
 
-	// Logs the access in statistics
-	recordObservable := URLStatService.RecordAccess(id)
+	if redirect.Warning != "" && !confirmed {
+		if recordResult := <-URLStatService.RecordInterstitial(redirect.Tenant, id, false).Observe(); recordResult.E != nil {
+			log.Printf("Error recording warning page view of %s: %v", id, recordResult.E)
+		}
+		renderInterstitial(c, redirect.OriginalURL, redirect.Warning)
+		return
+	}
+
+	// Logs the access in statistics, counting clicks through the warning page apart
+	recordObservable := URLStatService.RecordAccess(redirect.Tenant, id)
 	recordResult := <-recordObservable.Observe()
 	if recordResult.E != nil {
 		// You can add logs here if desired
 	}
+	if redirect.Warning != "" {
+		if recordResult := <-URLStatService.RecordInterstitial(redirect.Tenant, id, true).Observe(); recordResult.E != nil {
+			log.Printf("Error recording warning page click of %s: %v", id, recordResult.E)
+		}
+		// 303 turns the confirming POST into a GET of the destination
+		c.Redirect(http.StatusSeeOther, redirect.OriginalURL)
+		return
+	}
 
 	// Redirects to the original URL
-	c.Redirect(http.StatusFound, result.V.(string))
+	c.Redirect(http.StatusFound, redirect.OriginalURL)
 }
 
-func (s *URLShortenerHandler) ToggleURLStateHandler(c *gin.Context) {
+func (s *URLShortenerHandler) SetURLStateHandler(c *gin.Context) {
 	id := c.Param("id")
-	observable := rxgo.Just(id)().
+	tenant, actor := requestTenant(c), requestActor(c)
+	var req request.URLStateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": `Request body must be {"enabled": true|false}`})
+		return
+	}
+	if !authorizeURL(c, id) {
+		return
+	}
+
+	observable := rxgo.Just(*req.Enabled)().
+		Map(func(_ context.Context, item interface{}) (interface{}, error) {
+			// Calls the service to set the URL state
+			previous, err := service.SetURLState(id, item.(bool), tenant, actor)
+			return previous, err
+		})
+	result := <-observable.Observe()
+	if result.E != nil {
+		respondError(c, result.E, http.StatusInternalServerError, "Failed to update URL state")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":               id,
+		"enabled":          *req.Enabled,
+		"previous_enabled": result.V,
+	})
+}
+
+func (s *URLShortenerHandler) UpdateURLHandler(c *gin.Context) {
+	id := c.Param("id")
+	tenant, actor := requestTenant(c), requestActor(c)
+	var req request.UpdateURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	if !authorizeURL(c, id) {
+		return
+	}
+
+	observable := rxgo.Just(req)().
 		Map(func(_ context.Context, item interface{}) (interface{}, error) {
-			// Calls the service to toggle the URL state
-			updated, err := service.ToggleURLState(item.(string))
+			// Calls the service to apply the changes and refresh the cache
+			updated, err := service.UpdateShortURL(id, item.(request.UpdateURLRequest), tenant, actor)
 			return updated, err
 		})
 	result := <-observable.Observe()
 	if result.E != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update URL state"})
+		respondError(c, result.E, http.StatusInternalServerError, "Failed to update URL")
+		return
+	}
+	c.JSON(http.StatusOK, result.V)
+}
+
+func (s *URLShortenerHandler) DeleteURLHandler(c *gin.Context) {
+	id := c.Param("id")
+	tenant, actor := requestTenant(c), requestActor(c)
+	if !authorizeURL(c, id) {
+		return
+	}
+
+	// ?permanent=true purges the URL right away instead of moving it to the trash
+	if c.Query("permanent") == "true" {
+		if err := service.PurgeURL(id, tenant, actor); err != nil {
+			respondError(c, err, http.StatusInternalServerError, "Failed to delete URL")
+			return
+		}
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	observable := rxgo.Just(id)().
+		Map(func(_ context.Context, item interface{}) (interface{}, error) {
+			// Calls the service to move the URL to the trash
+			trashed, err := service.TrashURL(item.(string), tenant, actor)
+			return trashed, err
+		})
+	result := <-observable.Observe()
+	if result.E != nil {
+		respondError(c, result.E, http.StatusInternalServerError, "Failed to delete URL")
+		return
+	}
+	trashed := result.V.(domain.URL)
+	c.JSON(http.StatusOK, gin.H{
+		"url":         trashed,
+		"purge_after": trashed.DeletedAt.Add(service.TrashRetention),
+	})
+}
+
+func (s *URLShortenerHandler) RestoreURLHandler(c *gin.Context) {
+	id := c.Param("id")
+	tenant, actor := requestTenant(c), requestActor(c)
+	if !authorizeURL(c, id) {
+		return
+	}
+	observable := rxgo.Just(id)().
+		Map(func(_ context.Context, item interface{}) (interface{}, error) {
+			// Calls the service to take the URL out of the trash
+			restored, err := service.RestoreURL(item.(string), tenant, actor)
+			return restored, err
+		})
+	result := <-observable.Observe()
+	if result.E != nil {
+		respondError(c, result.E, http.StatusInternalServerError, "Failed to restore URL")
+		return
+	}
+	c.JSON(http.StatusOK, result.V)
+}
+
+func (s *URLShortenerHandler) ListURLsHandler(c *gin.Context) {
+	var req request.ListURLsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters"})
+		return
+	}
+
+	// Only admins see everyone's links
+	if !requestIsAdmin(c) {
+		actor := requestActor(c)
+		if req.Owner != "" && req.Owner != actor {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can list URLs of other owners"})
+			return
+		}
+		req.Owner = actor
+	}
+
+	page, nextCursor, err := service.ListURLs(req, requestTenant(c))
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError, "Failed to list URLs")
+		return
+	}
+	response := gin.H{"urls": page.URLs, "total": page.Total}
+	if nextCursor != "" {
+		response["next_cursor"] = nextCursor
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (s *URLShortenerHandler) GetURLHandler(c *gin.Context) {
+	id := c.Param("id")
+	if !authorizeURL(c, id) {
+		return
+	}
+
+	// Management lookups also see links in the trash
+	s.respondURLDetails(c, id, false)
+}
+
+// respondURLDetails writes the record and statistics of a URL without counting a click.
+// Public previews hide trashed and password-protected links; management lookups see them.
+func (s *URLShortenerHandler) respondURLDetails(c *gin.Context, id string, preview bool) {
+	tenant := requestTenant(c)
+	observable := rxgo.Just(id)().
+		Map(func(_ context.Context, item interface{}) (interface{}, error) {
+			// Calls the service to load the URL and its statistics
+			if preview {
+				return service.PreviewURL(item.(string))
+			}
+			return service.GetURLDetails(item.(string), tenant, true)
+		})
+	result := <-observable.Observe()
+	if result.E != nil {
+		respondError(c, result.E, http.StatusInternalServerError, "Failed to get URL")
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"success": result.V})
+	c.JSON(http.StatusOK, result.V)
 }
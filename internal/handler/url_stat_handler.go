@@ -16,7 +16,10 @@ func NewURLStatHandler() *URLStatHandler {
 
 func (s *URLStatHandler) GetURLStats(c *gin.Context) {
 	shortID := c.Param("id")
-	statsObservable := URLStatService.GetURLStats(shortID)
+	if !authorizeURL(c, shortID) {
+		return
+	}
+	statsObservable := URLStatService.GetURLStats(requestTenant(c), shortID)
 	result := <-statsObservable.Observe()
 	if result.E != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get URL stats"})
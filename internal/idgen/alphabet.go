@@ -0,0 +1,25 @@
+package idgen
+
+const (
+	// Base62Alphabet contains digits and ASCII letters in both cases
+	Base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// Base58Alphabet drops the easily confused 0, O, I and l from Base62Alphabet
+	Base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+)
+
+// Encode converts a non-negative number to its representation in the given alphabet
+func Encode(n uint64, alphabet string) string {
+	base := uint64(len(alphabet))
+	if n == 0 {
+		return alphabet[:1]
+	}
+
+	var buf [64]byte
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = alphabet[n%base]
+		n /= base
+	}
+	return string(buf[i:])
+}
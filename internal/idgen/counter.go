@@ -0,0 +1,25 @@
+package idgen
+
+import (
+	"urlshortener/internal/cache"
+)
+
+// CounterGenerator encodes a Redis INCR sequence shared by every replica
+type CounterGenerator struct {
+	Key      string
+	Alphabet string
+}
+
+// NewCounterGenerator creates a sequential generator backed by the given Redis key
+func NewCounterGenerator(key, alphabet string) *CounterGenerator {
+	return &CounterGenerator{Key: key, Alphabet: alphabet}
+}
+
+// Generate returns the next value of the sequence; a retry simply takes the next one
+func (g *CounterGenerator) Generate(_ string, _ int) (string, error) {
+	n, err := cache.NextSequence(g.Key)
+	if err != nil {
+		return "", err
+	}
+	return Encode(uint64(n), g.Alphabet), nil
+}
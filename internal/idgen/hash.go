@@ -0,0 +1,33 @@
+package idgen
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+)
+
+// HashGenerator derives IDs deterministically from an MD5 digest of the original URL
+type HashGenerator struct {
+	Length int
+}
+
+// NewHashGenerator creates a hash based generator producing IDs of the given length
+func NewHashGenerator(length int) *HashGenerator {
+	return &HashGenerator{Length: length}
+}
+
+// Generate takes the first characters of the hash. The first attempt is deterministic;
+// each retry salts the hash and makes the ID one character longer.
+func (g *HashGenerator) Generate(originalURL string, attempt int) (string, error) {
+	if attempt == 0 {
+		return hashFunction(originalURL)[:g.Length], nil
+	}
+	return hashFunction(fmt.Sprintf("%s#%d", originalURL, attempt))[:g.Length+attempt], nil
+}
+
+// hashFunction generates an MD5 hash of the original URL and converts it to a string
+func hashFunction(originalURL string) string {
+	hashMd5 := md5.New()
+	hashMd5.Write([]byte(originalURL))
+	return hex.EncodeToString(hashMd5.Sum(nil))
+}
@@ -0,0 +1,49 @@
+package idgen
+
+import (
+	"fmt"
+	"urlshortener/internal/interfaces"
+)
+
+// Strategy names accepted by New
+const (
+	StrategyHash    = "hash"
+	StrategyRandom  = "random"
+	StrategyCounter = "counter"
+)
+
+// counterKey is the Redis key holding the sequence used by the counter strategy
+const counterKey = "short_id:sequence"
+
+// New builds the IDGenerator for a strategy. length applies to the hash and random
+// strategies; alphabet ("base62" or "base58") to the random and counter ones.
+func New(strategy string, length int, alphabet string) (interfaces.IDGenerator, error) {
+	var symbols string
+	switch alphabet {
+	case "base62", "":
+		symbols = Base62Alphabet
+	case "base58":
+		symbols = Base58Alphabet
+	default:
+		return nil, fmt.Errorf("unknown ID alphabet %q", alphabet)
+	}
+
+	if length <= 0 {
+		return nil, fmt.Errorf("invalid ID length %d", length)
+	}
+
+	switch strategy {
+	case StrategyHash, "":
+		// The hex digest has 32 characters; leave room for the longer IDs used on retries
+		if length > 24 {
+			return nil, fmt.Errorf("hash IDs cannot be longer than 24 characters")
+		}
+		return NewHashGenerator(length), nil
+	case StrategyRandom:
+		return NewRandomGenerator(length, symbols), nil
+	case StrategyCounter:
+		return NewCounterGenerator(counterKey, symbols), nil
+	default:
+		return nil, fmt.Errorf("unknown ID strategy %q", strategy)
+	}
+}
@@ -0,0 +1,31 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// RandomGenerator draws IDs from a cryptographically secure random source
+type RandomGenerator struct {
+	Length   int
+	Alphabet string
+}
+
+// NewRandomGenerator creates a random generator producing IDs of the given length and alphabet
+func NewRandomGenerator(length int, alphabet string) *RandomGenerator {
+	return &RandomGenerator{Length: length, Alphabet: alphabet}
+}
+
+// Generate returns a fresh random ID; the original URL and attempt are not used
+func (g *RandomGenerator) Generate(_ string, _ int) (string, error) {
+	max := big.NewInt(int64(len(g.Alphabet)))
+	id := make([]byte, g.Length)
+	for i := range id {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		id[i] = g.Alphabet[n.Int64()]
+	}
+	return string(id), nil
+}
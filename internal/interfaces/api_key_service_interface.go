@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"github.com/reactivex/rxgo/v2"
+	"time"
+	"urlshortener/internal/domain"
+)
+
+// APIKeyServiceInterface defines the operations for managing API keys in the database
+type APIKeyServiceInterface interface {
+	SaveAPIKey(key domain.APIKey) rxgo.Observable
+	GetAPIKey(id string) rxgo.Observable
+	FindAPIKeyByHash(hash string) rxgo.Observable
+	ListAPIKeys(tenant string) rxgo.Observable
+	RevokeAPIKey(id string, revokedAt time.Time) rxgo.Observable
+}
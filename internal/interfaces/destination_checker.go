@@ -0,0 +1,7 @@
+package interfaces
+
+// DestinationChecker decides whether a destination is safe to shorten and redirect to
+type DestinationChecker interface {
+	// Check reports whether rawURL must be rejected, and the reason shown to the caller
+	Check(rawURL string) (reason string, blocked bool)
+}
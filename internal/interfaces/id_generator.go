@@ -0,0 +1,7 @@
+package interfaces
+
+// IDGenerator defines how short IDs are minted for new URLs
+type IDGenerator interface {
+	// Generate returns a short ID for originalURL; attempt counts the IDs that already collided
+	Generate(originalURL string, attempt int) (string, error)
+}
@@ -0,0 +1,19 @@
+package interfaces
+
+import (
+	"github.com/reactivex/rxgo/v2"
+	"time"
+	"urlshortener/internal/domain"
+)
+
+// ReportServiceInterface defines the operations for storing abuse reports and banned domains
+type ReportServiceInterface interface {
+	SaveReport(report domain.Report) rxgo.Observable
+	// ListReportedURLs emits up to limit []domain.ReportedURL of tenant with open reports, most reported first
+	ListReportedURLs(tenant string, limit int) rxgo.Observable
+	// CloseReports gives the open reports of a link the status of the action taken and emits how many as int64
+	CloseReports(shortID, status, closedBy string, closedAt time.Time) rxgo.Observable
+	SaveBannedDomain(ban domain.BannedDomain) rxgo.Observable
+	ListBannedDomains() rxgo.Observable
+	DeleteBannedDomain(domainName string) rxgo.Observable
+}
@@ -2,15 +2,22 @@ package interfaces
 
 import (
 	"github.com/reactivex/rxgo/v2"
-	"go.mongodb.org/mongo-driver/mongo"
+	"time"
 	"urlshortener/internal/domain"
 )
 
-// URLServiceInterface defines the operations for managing URLs in the database
+// URLServiceInterface defines the operations for managing URLs in the database. Every
+// operation is scoped to one tenant, UpdateURL to the tenant of the URL, except two:
+// GetURLTenant routes public requests, which only know the short ID, and FindDeletedURLs
+// finds the trash of every tenant to purge it.
 type URLServiceInterface interface {
-	InitDatabase(client *mongo.Client, dbName, collectionName string) URLCollectionInterface
 	SaveURL(url domain.URL) rxgo.Observable
-	GetURL(shortID string) rxgo.Observable
+	GetURL(tenant, shortID string) rxgo.Observable
+	GetURLTenant(shortID string) rxgo.Observable
 	UpdateURL(url domain.URL) rxgo.Observable
-	FindURLByOriginal(originalURL string) rxgo.Observable
+	FindURLByOriginal(originalURL, tenant, owner string) rxgo.Observable
+	DeleteURL(tenant, shortID string) rxgo.Observable
+	FindDeletedURLs(before time.Time) rxgo.Observable
+	ListURLs(query domain.URLQuery) rxgo.Observable
+	UpdateClickCount(tenant, shortID string, count int64) rxgo.Observable
 }
@@ -8,5 +8,10 @@ import (
 type URLShortenerServiceInterface interface {
 	ShortenURLHandler(c *gin.Context)
 	RedirectURLHandler(c *gin.Context)
-	ToggleURLStateHandler(c *gin.Context)
+	SetURLStateHandler(c *gin.Context)
+	UpdateURLHandler(c *gin.Context)
+	DeleteURLHandler(c *gin.Context)
+	RestoreURLHandler(c *gin.Context)
+	ListURLsHandler(c *gin.Context)
+	GetURLHandler(c *gin.Context)
 }
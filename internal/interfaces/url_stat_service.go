@@ -5,6 +5,7 @@ import (
 )
 
 type URLStatService interface {
-	GetURLStats(shortID string) rxgo.Observable
-	RecordAccess(shortID string) rxgo.Observable
+	GetURLStats(tenant, shortID string) rxgo.Observable
+	RecordAccess(tenant, shortID string) rxgo.Observable
+	RecordInterstitial(tenant, shortID string, continued bool) rxgo.Observable
 }
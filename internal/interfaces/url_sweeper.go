@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"github.com/reactivex/rxgo/v2"
+	"time"
+)
+
+// URLSweeper is implemented by repositories that cannot expire URLs on their own
+type URLSweeper interface {
+	// DeleteExpiredURLs removes URLs whose expiration time is not after now and emits them as []domain.URL
+	DeleteExpiredURLs(now time.Time) rxgo.Observable
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit is a request budget: at most Limit requests per Period, with bursts of up to
+// Limit requests. The zero value disables limiting.
+type RateLimit struct {
+	Limit  int64
+	Period time.Duration
+}
+
+// ParseRateLimit parses a budget written as "<requests>/<period>", e.g. "60/1m". An empty
+// string or "0" disables limiting.
+func ParseRateLimit(value string) (RateLimit, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "0" {
+		return RateLimit{}, nil
+	}
+
+	countStr, periodStr, found := strings.Cut(value, "/")
+	count, err := strconv.ParseInt(countStr, 10, 64)
+	if !found || err != nil || count <= 0 {
+		return RateLimit{}, fmt.Errorf("rate limit %q must look like 60/1m", value)
+	}
+	period, err := time.ParseDuration(periodStr)
+	if err != nil || period < time.Second {
+		return RateLimit{}, fmt.Errorf("rate limit %q needs a period of at least 1s", value)
+	}
+	return RateLimit{Limit: count, Period: period}, nil
+}
+
+// Enabled reports whether the budget limits anything
+func (r RateLimit) Enabled() bool {
+	return r.Limit > 0
+}
+
+// String formats the budget like ParseRateLimit expects it
+func (r RateLimit) String() string {
+	if !r.Enabled() {
+		return "0"
+	}
+	return fmt.Sprintf("%d/%s", r.Limit, r.Period)
+}
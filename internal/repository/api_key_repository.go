@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/reactivex/rxgo/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/interfaces"
+)
+
+// APIKeyServiceImpl implements APIKeyServiceInterface on top of a MongoDB collection.
+// URLCollectionInterface only describes generic collection methods, so it is reused here.
+type APIKeyServiceImpl struct {
+	KeyCollection interfaces.URLCollectionInterface
+}
+
+// InitDatabase assigns the API key collection, ensures the unique indexes on the key ID
+// and on the token hash and the tenant listing index exist, and backfills the tenant
+func (s *APIKeyServiceImpl) InitDatabase(client *mongo.Client, dbName, collectionName string) error {
+	collection := client.Database(dbName).Collection(collectionName)
+	s.KeyCollection = collection
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("id_unique"),
+		},
+		{
+			Keys:    bson.D{{Key: "key_hash", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("key_hash_unique"),
+		},
+		{
+			Keys:    bson.D{{Key: "tenant", Value: 1}, {Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("tenant_created_at"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Keys issued before workspaces existed belong to the default tenant
+	_, err = collection.UpdateMany(ctx, bson.M{"tenant": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"tenant": domain.DefaultTenant}})
+	return err
+}
+
+// SaveAPIKey saves an API key to the database reactively
+func (s *APIKeyServiceImpl) SaveAPIKey(key domain.APIKey) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, err := s.KeyCollection.InsertOne(ctx, key); err != nil {
+			ch <- rxgo.Error(errors.New("failed to save API key"))
+		} else {
+			ch <- rxgo.Of(key)
+		}
+	}})
+}
+
+// GetAPIKey retrieves an API key from the database by its ID reactively
+func (s *APIKeyServiceImpl) GetAPIKey(id string) rxgo.Observable {
+	return s.findOne(bson.M{"id": id})
+}
+
+// FindAPIKeyByHash retrieves an API key from the database by the hash of its token reactively
+func (s *APIKeyServiceImpl) FindAPIKeyByHash(hash string) rxgo.Observable {
+	return s.findOne(bson.M{"key_hash": hash})
+}
+
+// ListAPIKeys retrieves the API keys of a tenant from the database, oldest first, reactively
+func (s *APIKeyServiceImpl) ListAPIKeys(tenant string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+		cursor, err := s.KeyCollection.Find(ctx, bson.M{"tenant": tenant}, opts)
+		if err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		keys := []domain.APIKey{}
+		if err = cursor.All(ctx, &keys); err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		ch <- rxgo.Of(keys)
+	}})
+}
+
+// RevokeAPIKey marks an API key as revoked reactively; revoking it again keeps the
+// original revocation time
+func (s *APIKeyServiceImpl) RevokeAPIKey(id string, revokedAt time.Time) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		filter := bson.M{"id": id}
+		update := bson.M{"$min": bson.M{"revoked_at": revokedAt}}
+		result, err := s.KeyCollection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			ch <- rxgo.Error(errors.New("failed to revoke API key"))
+		} else if result != nil && result.MatchedCount == 0 {
+			ch <- rxgo.Error(ErrAPIKeyNotFound)
+		} else {
+			ch <- rxgo.Of(id)
+		}
+	}})
+}
+
+// findOne retrieves the single API key matching filter reactively
+func (s *APIKeyServiceImpl) findOne(filter bson.M) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var key domain.APIKey
+		err := s.KeyCollection.FindOne(ctx, filter).Decode(&key)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ch <- rxgo.Error(ErrAPIKeyNotFound)
+		} else if err != nil {
+			ch <- rxgo.Error(err)
+		} else {
+			ch <- rxgo.Of(key)
+		}
+	}})
+}
@@ -0,0 +1,14 @@
+package repository
+
+import "errors"
+
+var (
+	// ErrURLNotFound is returned when no URL matches the requested lookup
+	ErrURLNotFound = errors.New("URL not found")
+	// ErrDuplicateURL is returned when a URL with the same short ID is already stored
+	ErrDuplicateURL = errors.New("URL already exists")
+	// ErrAPIKeyNotFound is returned when no API key matches the requested lookup
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrBannedDomainNotFound is returned when lifting a ban on a domain that is not banned
+	ErrBannedDomainNotFound = errors.New("banned domain not found")
+)
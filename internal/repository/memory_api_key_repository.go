@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"github.com/reactivex/rxgo/v2"
+	"sort"
+	"sync"
+	"time"
+	"urlshortener/internal/domain"
+)
+
+// MemoryAPIKeyServiceImpl implements APIKeyServiceInterface on top of an in-process map
+type MemoryAPIKeyServiceImpl struct {
+	mu   sync.RWMutex
+	byID map[string]domain.APIKey
+}
+
+// NewMemoryAPIKeyService creates an empty in-memory API key store
+func NewMemoryAPIKeyService() *MemoryAPIKeyServiceImpl {
+	return &MemoryAPIKeyServiceImpl{byID: make(map[string]domain.APIKey)}
+}
+
+// SaveAPIKey stores an API key in memory reactively
+func (s *MemoryAPIKeyServiceImpl) SaveAPIKey(key domain.APIKey) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.byID[key.ID] = key
+		ch <- rxgo.Of(key)
+	}})
+}
+
+// GetAPIKey retrieves an API key from memory by its ID reactively
+func (s *MemoryAPIKeyServiceImpl) GetAPIKey(id string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		key, exists := s.byID[id]
+		if !exists {
+			ch <- rxgo.Error(ErrAPIKeyNotFound)
+			return
+		}
+		ch <- rxgo.Of(key)
+	}})
+}
+
+// FindAPIKeyByHash retrieves an API key from memory by the hash of its token reactively
+func (s *MemoryAPIKeyServiceImpl) FindAPIKeyByHash(hash string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		for _, key := range s.byID {
+			if key.Hash == hash {
+				ch <- rxgo.Of(key)
+				return
+			}
+		}
+		ch <- rxgo.Error(ErrAPIKeyNotFound)
+	}})
+}
+
+// ListAPIKeys retrieves the API keys of a tenant from memory, oldest first, reactively
+func (s *MemoryAPIKeyServiceImpl) ListAPIKeys(tenant string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		keys := []domain.APIKey{}
+		for _, key := range s.byID {
+			if key.Tenant == tenant {
+				keys = append(keys, key)
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		})
+		ch <- rxgo.Of(keys)
+	}})
+}
+
+// RevokeAPIKey marks an API key as revoked in memory reactively; revoking it again keeps
+// the original revocation time
+func (s *MemoryAPIKeyServiceImpl) RevokeAPIKey(id string, revokedAt time.Time) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		key, exists := s.byID[id]
+		if !exists {
+			ch <- rxgo.Error(ErrAPIKeyNotFound)
+			return
+		}
+		if key.RevokedAt == nil {
+			key.RevokedAt = &revokedAt
+			s.byID[id] = key
+		}
+		ch <- rxgo.Of(id)
+	}})
+}
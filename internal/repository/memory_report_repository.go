@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"github.com/reactivex/rxgo/v2"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+	"urlshortener/internal/domain"
+)
+
+// MemoryReportServiceImpl implements ReportServiceInterface on top of in-process maps
+type MemoryReportServiceImpl struct {
+	mu      sync.RWMutex
+	reports []domain.Report
+	banned  map[string]domain.BannedDomain
+}
+
+// NewMemoryReportService creates an empty in-memory report store
+func NewMemoryReportService() *MemoryReportServiceImpl {
+	return &MemoryReportServiceImpl{banned: make(map[string]domain.BannedDomain)}
+}
+
+// SaveReport stores an abuse report in memory reactively
+func (s *MemoryReportServiceImpl) SaveReport(report domain.Report) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.reports = append(s.reports, report)
+		ch <- rxgo.Of(report)
+	}})
+}
+
+// ListReportedURLs summarizes the open reports of a tenant per link, most reported first, reactively
+func (s *MemoryReportServiceImpl) ListReportedURLs(tenant string, limit int) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		byID := map[string]*domain.ReportedURL{}
+		for _, report := range s.reports {
+			if report.Tenant != tenant || report.Status != domain.ReportOpen {
+				continue
+			}
+			reported, exists := byID[report.ShortID]
+			if !exists {
+				reported = &domain.ReportedURL{ShortID: report.ShortID}
+				byID[report.ShortID] = reported
+			}
+			reported.ReportCount++
+			if report.CreatedAt.After(reported.LastReportedAt) {
+				reported.LastReportedAt = report.CreatedAt
+			}
+			if !slices.Contains(reported.Reasons, report.Reason) {
+				reported.Reasons = append(reported.Reasons, report.Reason)
+			}
+		}
+
+		reportedURLs := make([]domain.ReportedURL, 0, len(byID))
+		for _, reported := range byID {
+			sort.Strings(reported.Reasons)
+			reportedURLs = append(reportedURLs, *reported)
+		}
+		sortReportedURLs(reportedURLs)
+		if len(reportedURLs) > limit {
+			reportedURLs = reportedURLs[:limit]
+		}
+		ch <- rxgo.Of(reportedURLs)
+	}})
+}
+
+// CloseReports closes the open reports of a link in memory reactively
+func (s *MemoryReportServiceImpl) CloseReports(shortID, status, closedBy string, closedAt time.Time) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		var closed int64
+		for i, report := range s.reports {
+			if report.ShortID != shortID || report.Status != domain.ReportOpen {
+				continue
+			}
+			report.Status, report.ClosedAt, report.ClosedBy = status, &closedAt, closedBy
+			s.reports[i] = report
+			closed++
+		}
+		ch <- rxgo.Of(closed)
+	}})
+}
+
+// SaveBannedDomain bans a domain in memory reactively; banning it again keeps the original ban
+func (s *MemoryReportServiceImpl) SaveBannedDomain(ban domain.BannedDomain) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if existing, exists := s.banned[ban.Domain]; exists {
+			ch <- rxgo.Of(existing)
+			return
+		}
+		s.banned[ban.Domain] = ban
+		ch <- rxgo.Of(ban)
+	}})
+}
+
+// ListBannedDomains retrieves the banned domains from memory, sorted by name, reactively
+func (s *MemoryReportServiceImpl) ListBannedDomains() rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		bans := make([]domain.BannedDomain, 0, len(s.banned))
+		for _, ban := range s.banned {
+			bans = append(bans, ban)
+		}
+		sort.Slice(bans, func(i, j int) bool {
+			return bans[i].Domain < bans[j].Domain
+		})
+		ch <- rxgo.Of(bans)
+	}})
+}
+
+// DeleteBannedDomain lifts the ban on a domain in memory reactively
+func (s *MemoryReportServiceImpl) DeleteBannedDomain(domainName string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if _, exists := s.banned[domainName]; !exists {
+			ch <- rxgo.Error(ErrBannedDomainNotFound)
+			return
+		}
+		delete(s.banned, domainName)
+		ch <- rxgo.Of(domainName)
+	}})
+}
+
+// sortReportedURLs orders the moderation queue: most reports first, then most recently reported
+func sortReportedURLs(reportedURLs []domain.ReportedURL) {
+	sort.Slice(reportedURLs, func(i, j int) bool {
+		if reportedURLs[i].ReportCount != reportedURLs[j].ReportCount {
+			return reportedURLs[i].ReportCount > reportedURLs[j].ReportCount
+		}
+		if !reportedURLs[i].LastReportedAt.Equal(reportedURLs[j].LastReportedAt) {
+			return reportedURLs[i].LastReportedAt.After(reportedURLs[j].LastReportedAt)
+		}
+		return reportedURLs[i].ShortID < reportedURLs[j].ShortID
+	})
+}
@@ -0,0 +1,275 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"github.com/reactivex/rxgo/v2"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"urlshortener/internal/domain"
+)
+
+// MemoryURLServiceImpl implements URLServiceInterface on top of in-process maps.
+// It is meant for local runs and tests where no database is available.
+type MemoryURLServiceImpl struct {
+	mu         sync.RWMutex
+	byID       map[string]domain.URL
+	byOriginal map[string]string // Keyed by originalKey
+}
+
+// NewMemoryURLService creates an empty in-memory URL store
+func NewMemoryURLService() *MemoryURLServiceImpl {
+	return &MemoryURLServiceImpl{
+		byID:       make(map[string]domain.URL),
+		byOriginal: make(map[string]string),
+	}
+}
+
+// SaveURL stores a URL in memory reactively
+func (s *MemoryURLServiceImpl) SaveURL(url domain.URL) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if _, exists := s.byID[url.ID]; exists {
+			ch <- rxgo.Error(ErrDuplicateURL)
+			return
+		}
+		s.byID[url.ID] = url
+		// Keep the first stored ID for an original URL, like FindOne does in MongoDB
+		if _, exists := s.byOriginal[originalKey(url)]; !exists {
+			s.byOriginal[originalKey(url)] = url.ID
+		}
+		ch <- rxgo.Of(url)
+	}})
+}
+
+// GetURL retrieves a URL of the tenant from memory by its ID reactively
+func (s *MemoryURLServiceImpl) GetURL(tenant, shortID string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		url, exists := s.byID[shortID]
+		if !exists || url.Tenant != tenant {
+			ch <- rxgo.Error(ErrURLNotFound)
+			return
+		}
+		ch <- rxgo.Of(url)
+	}})
+}
+
+// GetURLTenant retrieves the tenant a short ID belongs to from memory reactively
+func (s *MemoryURLServiceImpl) GetURLTenant(shortID string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		url, exists := s.byID[shortID]
+		if !exists {
+			ch <- rxgo.Error(ErrURLNotFound)
+			return
+		}
+		ch <- rxgo.Of(url.Tenant)
+	}})
+}
+
+// UpdateURL updates the mutable fields of a URL in memory reactively
+func (s *MemoryURLServiceImpl) UpdateURL(url domain.URL) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		stored, exists := s.byID[url.ID]
+		if !exists || stored.Tenant != url.Tenant {
+			// MongoDB's UpdateOne does not fail when nothing matches, so neither do we
+			ch <- rxgo.Of(url)
+			return
+		}
+
+		if stored.OriginalURL != url.OriginalURL {
+			if s.byOriginal[originalKey(stored)] == stored.ID {
+				delete(s.byOriginal, originalKey(stored))
+			}
+			if _, taken := s.byOriginal[originalKey(url)]; !taken {
+				s.byOriginal[originalKey(url)] = url.ID
+			}
+		}
+		stored.Enabled = url.Enabled
+		stored.Quarantine = url.Quarantine
+		stored.Warning = url.Warning
+		stored.PasswordHash = url.PasswordHash
+		stored.OriginalURL = url.OriginalURL
+		stored.ExpiresAt = url.ExpiresAt
+		stored.MaxClicks = url.MaxClicks
+		stored.DeletedAt = url.DeletedAt
+		stored.Domain = url.Domain
+		stored.Tags = url.Tags
+		stored.UpdatedAt = url.UpdatedAt
+		stored.UpdatedBy = url.UpdatedBy
+		s.byID[url.ID] = stored
+		ch <- rxgo.Of(url)
+	}})
+}
+
+// FindURLByOriginal searches for a URL of the tenant and owner by its original URL in memory reactively
+func (s *MemoryURLServiceImpl) FindURLByOriginal(originalURL, tenant, owner string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		shortID, exists := s.byOriginal[originalKey(domain.URL{OriginalURL: originalURL, Tenant: tenant, Owner: owner})]
+		if !exists {
+			ch <- rxgo.Error(ErrURLNotFound) // Returns error if not found
+			return
+		}
+		ch <- rxgo.Of(s.byID[shortID])
+	}})
+}
+
+// DeleteExpiredURLs removes URLs whose expiration time has passed and emits them reactively
+func (s *MemoryURLServiceImpl) DeleteExpiredURLs(now time.Time) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		deleted := []domain.URL{}
+		for id, url := range s.byID {
+			if !url.IsExpired(now) {
+				continue
+			}
+			delete(s.byID, id)
+			if s.byOriginal[originalKey(url)] == id {
+				delete(s.byOriginal, originalKey(url))
+			}
+			deleted = append(deleted, url)
+		}
+		ch <- rxgo.Of(deleted)
+	}})
+}
+
+// DeleteURL permanently removes a URL of the tenant from memory reactively
+func (s *MemoryURLServiceImpl) DeleteURL(tenant, shortID string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		url, exists := s.byID[shortID]
+		if !exists || url.Tenant != tenant {
+			ch <- rxgo.Error(ErrURLNotFound)
+			return
+		}
+		delete(s.byID, shortID)
+		if s.byOriginal[originalKey(url)] == shortID {
+			delete(s.byOriginal, originalKey(url))
+		}
+		ch <- rxgo.Of(shortID)
+	}})
+}
+
+// FindDeletedURLs retrieves the URLs moved to the trash no later than before reactively
+func (s *MemoryURLServiceImpl) FindDeletedURLs(before time.Time) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		urls := []domain.URL{}
+		for _, url := range s.byID {
+			if url.DeletedAt != nil && !url.DeletedAt.After(before) {
+				urls = append(urls, url)
+			}
+		}
+		ch <- rxgo.Of(urls)
+	}})
+}
+
+// ListURLs retrieves a filtered and sorted page of live URLs from memory reactively
+func (s *MemoryURLServiceImpl) ListURLs(query domain.URLQuery) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		matched := []domain.URL{}
+		for _, url := range s.byID {
+			if matchesQuery(url, query) {
+				matched = append(matched, url)
+			}
+		}
+		sort.Slice(matched, func(i, j int) bool {
+			return compareCursors(domain.CursorOf(matched[i]), domain.CursorOf(matched[j]), query) < 0
+		})
+
+		page := domain.URLPage{Total: int64(len(matched)), URLs: []domain.URL{}}
+		for _, url := range matched {
+			if query.After != nil && compareCursors(domain.CursorOf(url), *query.After, query) <= 0 {
+				continue
+			}
+			if len(page.URLs) == query.Limit {
+				page.HasMore = true
+				break
+			}
+			page.URLs = append(page.URLs, url)
+		}
+		ch <- rxgo.Of(page)
+	}})
+}
+
+// UpdateClickCount raises the stored click count of a URL of the tenant in memory reactively
+func (s *MemoryURLServiceImpl) UpdateClickCount(tenant, shortID string, count int64) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if url, exists := s.byID[shortID]; exists && url.Tenant == tenant && url.ClickCount < count {
+			url.ClickCount = count
+			s.byID[shortID] = url
+		}
+		ch <- rxgo.Of(shortID)
+	}})
+}
+
+// matchesQuery reports whether a live URL passes every filter of the query
+func matchesQuery(url domain.URL, query domain.URLQuery) bool {
+	switch {
+	case url.DeletedAt != nil || url.Tenant != query.Tenant:
+		return false
+	case query.Enabled != nil && url.Enabled != *query.Enabled:
+		return false
+	case query.CreatedFrom != nil && url.CreatedAt.Before(*query.CreatedFrom):
+		return false
+	case query.CreatedTo != nil && !url.CreatedAt.Before(*query.CreatedTo):
+		return false
+	case query.Domain != "" && url.Domain != query.Domain:
+		return false
+	case query.Owner != "" && url.Owner != query.Owner:
+		return false
+	case query.Tag != "" && !slices.Contains(url.Tags, query.Tag):
+		return false
+	}
+	return query.Search == "" || strings.Contains(strings.ToLower(url.OriginalURL), strings.ToLower(query.Search))
+}
+
+// compareCursors orders two keyset positions by the sort field of the query, then by ID
+func compareCursors(a, b domain.URLCursor, query domain.URLQuery) int {
+	var result int
+	if query.SortBy == domain.SortByClickCount {
+		result = cmp.Compare(a.ClickCount, b.ClickCount)
+	} else {
+		result = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if result == 0 {
+		result = strings.Compare(a.ID, b.ID)
+	}
+	if query.Descending {
+		return -result
+	}
+	return result
+}
+
+// originalKey indexes a URL by tenant, owner and destination, since each owner gets their own link
+func originalKey(url domain.URL) string {
+	return url.Tenant + "\x00" + url.Owner + "\x00" + url.OriginalURL
+}
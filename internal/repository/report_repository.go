@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/reactivex/rxgo/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+	"time"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/interfaces"
+)
+
+// ReportServiceImpl implements ReportServiceInterface on top of two MongoDB collections,
+// one for abuse reports and one for banned domains
+type ReportServiceImpl struct {
+	ReportCollection interfaces.ReportCollectionInterface
+	BanCollection    interfaces.URLCollectionInterface
+}
+
+// InitDatabase assigns the report and banned domain collections and ensures the
+// moderation queue index and the unique index on banned domains exist
+func (s *ReportServiceImpl) InitDatabase(client *mongo.Client, dbName, reportCollection, banCollection string) error {
+	reports := client.Database(dbName).Collection(reportCollection)
+	bans := client.Database(dbName).Collection(banCollection)
+	s.ReportCollection, s.BanCollection = reports, bans
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := reports.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("id_unique"),
+		},
+		{
+			Keys:    bson.D{{Key: "tenant", Value: 1}, {Key: "status", Value: 1}, {Key: "short_id", Value: 1}},
+			Options: options.Index().SetName("tenant_status_short_id"),
+		},
+		{
+			Keys:    bson.D{{Key: "short_id", Value: 1}, {Key: "status", Value: 1}},
+			Options: options.Index().SetName("short_id_status"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = bans.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "domain", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("domain_unique"),
+	})
+	return err
+}
+
+// SaveReport saves an abuse report to the database reactively
+func (s *ReportServiceImpl) SaveReport(report domain.Report) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, err := s.ReportCollection.InsertOne(ctx, report); err != nil {
+			ch <- rxgo.Error(errors.New("failed to save report"))
+		} else {
+			ch <- rxgo.Of(report)
+		}
+	}})
+}
+
+// ListReportedURLs summarizes the open reports of a tenant per link, most reported first, reactively
+func (s *ReportServiceImpl) ListReportedURLs(tenant string, limit int) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"tenant": tenant, "status": domain.ReportOpen}}},
+			{{Key: "$group", Value: bson.M{
+				"_id":              "$short_id",
+				"report_count":     bson.M{"$sum": 1},
+				"reasons":          bson.M{"$addToSet": "$reason"},
+				"last_reported_at": bson.M{"$max": "$created_at"},
+			}}},
+			{{Key: "$sort", Value: bson.D{{Key: "report_count", Value: -1}, {Key: "last_reported_at", Value: -1}, {Key: "_id", Value: 1}}}},
+			{{Key: "$limit", Value: limit}},
+		}
+		cursor, err := s.ReportCollection.Aggregate(ctx, pipeline)
+		if err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		reportedURLs := []domain.ReportedURL{}
+		if err = cursor.All(ctx, &reportedURLs); err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		for i := range reportedURLs {
+			sort.Strings(reportedURLs[i].Reasons)
+			reportedURLs[i].LastReportedAt = reportedURLs[i].LastReportedAt.UTC()
+		}
+		ch <- rxgo.Of(reportedURLs)
+	}})
+}
+
+// CloseReports closes the open reports of a link reactively
+func (s *ReportServiceImpl) CloseReports(shortID, status, closedBy string, closedAt time.Time) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		filter := bson.M{"short_id": shortID, "status": domain.ReportOpen}
+		update := bson.M{"$set": bson.M{"status": status, "closed_at": closedAt, "closed_by": closedBy}}
+		result, err := s.ReportCollection.UpdateMany(ctx, filter, update)
+		if err != nil {
+			ch <- rxgo.Error(errors.New("failed to close reports"))
+			return
+		}
+		ch <- rxgo.Of(result.ModifiedCount)
+	}})
+}
+
+// SaveBannedDomain bans a domain reactively; banning it again keeps the original ban
+func (s *ReportServiceImpl) SaveBannedDomain(ban domain.BannedDomain) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		filter := bson.M{"domain": ban.Domain}
+		update := bson.M{"$setOnInsert": ban}
+		if _, err := s.BanCollection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true)); err != nil {
+			ch <- rxgo.Error(errors.New("failed to ban domain"))
+			return
+		}
+
+		var stored domain.BannedDomain
+		if err := s.BanCollection.FindOne(ctx, filter).Decode(&stored); err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		stored.BannedAt = stored.BannedAt.UTC()
+		ch <- rxgo.Of(stored)
+	}})
+}
+
+// ListBannedDomains retrieves the banned domains, sorted by name, reactively
+func (s *ReportServiceImpl) ListBannedDomains() rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "domain", Value: 1}})
+		cursor, err := s.BanCollection.Find(ctx, bson.M{}, opts)
+		if err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		bans := []domain.BannedDomain{}
+		if err = cursor.All(ctx, &bans); err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		for i := range bans {
+			bans[i].BannedAt = bans[i].BannedAt.UTC()
+		}
+		ch <- rxgo.Of(bans)
+	}})
+}
+
+// DeleteBannedDomain lifts the ban on a domain reactively
+func (s *ReportServiceImpl) DeleteBannedDomain(domainName string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		result, err := s.BanCollection.DeleteOne(ctx, bson.M{"domain": domainName})
+		if err != nil {
+			ch <- rxgo.Error(errors.New("failed to lift domain ban"))
+		} else if result != nil && result.DeletedCount == 0 {
+			ch <- rxgo.Error(ErrBannedDomainNotFound)
+		} else {
+			ch <- rxgo.Of(domainName)
+		}
+	}})
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/reactivex/rxgo/v2"
+	"strings"
+	"time"
+	"urlshortener/internal/domain"
+)
+
+// apiKeyColumns is the column list matching scanAPIKey
+const apiKeyColumns = "id, name, prefix, key_hash, tenant, scopes, created_at, created_by, revoked_at"
+
+// SQLAPIKeyServiceImpl implements APIKeyServiceInterface on top of database/sql. The
+// api_keys table is created by the migrations applied in SQLURLServiceImpl.InitDatabase.
+type SQLAPIKeyServiceImpl struct {
+	DB      *sql.DB
+	Dialect string
+}
+
+// SaveAPIKey saves an API key to the database reactively
+func (s *SQLAPIKeyServiceImpl) SaveAPIKey(key domain.APIKey) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		query := rebind(s.Dialect, "INSERT INTO api_keys ("+apiKeyColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		_, err := s.DB.ExecContext(ctx, query, key.ID, key.Name, key.Prefix, key.Hash, key.Tenant, strings.Join(key.Scopes, ","),
+			key.CreatedAt.UTC(), key.CreatedBy, nullTime(key.RevokedAt))
+		if err != nil {
+			ch <- rxgo.Error(errors.New("failed to save API key"))
+		} else {
+			ch <- rxgo.Of(key)
+		}
+	}})
+}
+
+// GetAPIKey retrieves an API key from the database by its ID reactively
+func (s *SQLAPIKeyServiceImpl) GetAPIKey(id string) rxgo.Observable {
+	return s.findOne("id", id)
+}
+
+// FindAPIKeyByHash retrieves an API key from the database by the hash of its token reactively
+func (s *SQLAPIKeyServiceImpl) FindAPIKeyByHash(hash string) rxgo.Observable {
+	return s.findOne("key_hash", hash)
+}
+
+// ListAPIKeys retrieves the API keys of a tenant from the database, oldest first, reactively
+func (s *SQLAPIKeyServiceImpl) ListAPIKeys(tenant string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		query := rebind(s.Dialect, "SELECT "+apiKeyColumns+" FROM api_keys WHERE tenant = ? ORDER BY created_at, id")
+		rows, err := s.DB.QueryContext(ctx, query, tenant)
+		if err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		defer rows.Close()
+
+		keys := []domain.APIKey{}
+		for rows.Next() {
+			key, err := scanAPIKey(rows)
+			if err != nil {
+				ch <- rxgo.Error(err)
+				return
+			}
+			keys = append(keys, key)
+		}
+		if err = rows.Err(); err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		ch <- rxgo.Of(keys)
+	}})
+}
+
+// RevokeAPIKey marks an API key as revoked reactively; revoking it again keeps the
+// original revocation time
+func (s *SQLAPIKeyServiceImpl) RevokeAPIKey(id string, revokedAt time.Time) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		query := rebind(s.Dialect, "UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?")
+		result, err := s.DB.ExecContext(ctx, query, revokedAt.UTC(), id)
+		if err != nil {
+			ch <- rxgo.Error(errors.New("failed to revoke API key"))
+			return
+		}
+		if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+			ch <- rxgo.Error(ErrAPIKeyNotFound)
+			return
+		}
+		ch <- rxgo.Of(id)
+	}})
+}
+
+// findOne retrieves the single API key whose column equals value reactively
+func (s *SQLAPIKeyServiceImpl) findOne(column, value string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		row := s.DB.QueryRowContext(ctx, rebind(s.Dialect, "SELECT "+apiKeyColumns+" FROM api_keys WHERE "+column+" = ?"), value)
+		key, err := scanAPIKey(row)
+		if errors.Is(err, sql.ErrNoRows) {
+			ch <- rxgo.Error(ErrAPIKeyNotFound)
+		} else if err != nil {
+			ch <- rxgo.Error(err)
+		} else {
+			ch <- rxgo.Of(key)
+		}
+	}})
+}
+
+// scanAPIKey reads one row selected with apiKeyColumns from a *sql.Row or *sql.Rows
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (domain.APIKey, error) {
+	var key domain.APIKey
+	var scopes string
+	var createdAt, revokedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &key.Tenant, &scopes, &createdAt, &key.CreatedBy, &revokedAt)
+	key.Scopes = strings.Split(scopes, ",")
+	key.CreatedAt = createdAt.Time.UTC()
+	key.RevokedAt = timePtr(revokedAt)
+	return key, err
+}
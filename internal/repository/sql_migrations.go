@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/storage"
+)
+
+// sqlMigration is a schema change applied once, in order of Version. Backfill, when set,
+// runs in the same transaction after the statements to fill new columns of existing rows.
+type sqlMigration struct {
+	Version    int
+	Statements []string
+	Backfill   func(ctx context.Context, tx *sql.Tx, dialect string) error
+}
+
+// sqlMigrations lists every schema version; append new entries, never edit applied ones.
+// Statements use portable SQL understood by both SQLite and PostgreSQL.
+var sqlMigrations = []sqlMigration{
+	{
+		Version: 1,
+		Statements: []string{
+			`CREATE TABLE urls (
+				id           TEXT    NOT NULL PRIMARY KEY,
+				original_url TEXT    NOT NULL,
+				short_url    TEXT    NOT NULL,
+				enabled      BOOLEAN NOT NULL DEFAULT TRUE
+			)`,
+			`CREATE UNIQUE INDEX urls_original_url_idx ON urls (original_url)`,
+		},
+	},
+	{
+		Version: 2,
+		Statements: []string{
+			`ALTER TABLE urls ADD COLUMN expires_at TIMESTAMP NULL`,
+			`CREATE INDEX urls_expires_at_idx ON urls (expires_at)`,
+		},
+	},
+	{
+		Version: 3,
+		Statements: []string{
+			`ALTER TABLE urls ADD COLUMN max_clicks BIGINT NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		Version: 4,
+		Statements: []string{
+			`ALTER TABLE urls ADD COLUMN deleted_at TIMESTAMP NULL`,
+			`CREATE INDEX urls_deleted_at_idx ON urls (deleted_at)`,
+		},
+	},
+	{
+		Version: 5,
+		Statements: []string{
+			`ALTER TABLE urls ADD COLUMN created_at TIMESTAMP NULL`,
+			`ALTER TABLE urls ADD COLUMN domain TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE urls ADD COLUMN owner TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE urls ADD COLUMN tags TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE urls ADD COLUMN click_count BIGINT NOT NULL DEFAULT 0`,
+			`CREATE INDEX urls_created_at_idx ON urls (created_at, id)`,
+			`CREATE INDEX urls_click_count_idx ON urls (click_count, id)`,
+			`CREATE INDEX urls_domain_idx ON urls (domain, created_at)`,
+			`CREATE INDEX urls_owner_idx ON urls (owner, created_at)`,
+		},
+		Backfill: backfillListingColumns,
+	},
+	{
+		// Authors of existing rows are unknown, so created_by and updated_by stay empty
+		Version: 6,
+		Statements: []string{
+			`ALTER TABLE urls ADD COLUMN updated_at TIMESTAMP NULL`,
+			`ALTER TABLE urls ADD COLUMN created_by TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE urls ADD COLUMN updated_by TEXT NOT NULL DEFAULT ''`,
+			`UPDATE urls SET updated_at = created_at WHERE updated_at IS NULL`,
+		},
+	},
+	{
+		Version: 7,
+		Statements: []string{
+			`CREATE TABLE api_keys (
+				id         TEXT      NOT NULL PRIMARY KEY,
+				name       TEXT      NOT NULL,
+				prefix     TEXT      NOT NULL,
+				key_hash   TEXT      NOT NULL,
+				scopes     TEXT      NOT NULL,
+				created_at TIMESTAMP NOT NULL,
+				created_by TEXT      NOT NULL DEFAULT '',
+				revoked_at TIMESTAMP NULL
+			)`,
+			`CREATE UNIQUE INDEX api_keys_key_hash_idx ON api_keys (key_hash)`,
+		},
+	},
+	{
+		// Destinations become unique per owner. Rows whose creator is known become theirs;
+		// older rows stay unowned and only admins manage them.
+		Version: 8,
+		Statements: []string{
+			`UPDATE urls SET owner = created_by WHERE owner = ''`,
+			`DROP INDEX urls_original_url_idx`,
+			`CREATE UNIQUE INDEX urls_owner_original_url_idx ON urls (owner, original_url)`,
+		},
+	},
+	{
+		// Existing links and keys belong to domain.DefaultTenant
+		Version: 9,
+		Statements: []string{
+			`ALTER TABLE urls ADD COLUMN tenant TEXT NOT NULL DEFAULT 'default'`,
+			`DROP INDEX urls_owner_original_url_idx`,
+			`CREATE UNIQUE INDEX urls_tenant_owner_original_url_idx ON urls (tenant, owner, original_url)`,
+			`CREATE INDEX urls_tenant_created_at_idx ON urls (tenant, created_at, id)`,
+			`ALTER TABLE api_keys ADD COLUMN tenant TEXT NOT NULL DEFAULT 'default'`,
+			`CREATE INDEX api_keys_tenant_idx ON api_keys (tenant, created_at)`,
+		},
+	},
+	{
+		Version: 10,
+		Statements: []string{
+			`ALTER TABLE urls ADD COLUMN quarantine TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		Version: 11,
+		Statements: []string{
+			`CREATE TABLE reports (
+				id             TEXT      NOT NULL PRIMARY KEY,
+				short_id       TEXT      NOT NULL,
+				tenant         TEXT      NOT NULL,
+				reason         TEXT      NOT NULL,
+				details        TEXT      NOT NULL DEFAULT '',
+				reporter_ip    TEXT      NOT NULL DEFAULT '',
+				reporter_agent TEXT      NOT NULL DEFAULT '',
+				reporter_email TEXT      NOT NULL DEFAULT '',
+				created_at     TIMESTAMP NOT NULL,
+				status         TEXT      NOT NULL,
+				closed_at      TIMESTAMP NULL,
+				closed_by      TEXT      NOT NULL DEFAULT ''
+			)`,
+			`CREATE INDEX reports_tenant_status_idx ON reports (tenant, status, short_id)`,
+			`CREATE INDEX reports_short_id_status_idx ON reports (short_id, status)`,
+			`CREATE TABLE banned_domains (
+				domain    TEXT      NOT NULL PRIMARY KEY,
+				banned_at TIMESTAMP NOT NULL,
+				banned_by TEXT      NOT NULL DEFAULT ''
+			)`,
+		},
+	},
+	{
+		Version: 12,
+		Statements: []string{
+			`ALTER TABLE urls ADD COLUMN warning TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		Version: 13,
+		Statements: []string{
+			`ALTER TABLE urls ADD COLUMN password_hash TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+}
+
+// backfillListingColumns sets created_at and domain on rows stored before URLs could be
+// listed. Their real creation time is unknown, so the migration time is used.
+func backfillListingColumns(ctx context.Context, tx *sql.Tx, dialect string) error {
+	rows, err := tx.QueryContext(ctx, "SELECT id, original_url FROM urls WHERE created_at IS NULL")
+	if err != nil {
+		return err
+	}
+	destinations := map[string]string{}
+	for rows.Next() {
+		var id, originalURL string
+		if err = rows.Scan(&id, &originalURL); err != nil {
+			rows.Close()
+			return err
+		}
+		destinations[id] = originalURL
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	query := rebind(dialect, "UPDATE urls SET created_at = ?, domain = ? WHERE id = ?")
+	for id, originalURL := range destinations {
+		if _, err = tx.ExecContext(ctx, query, now, domain.DestinationHost(originalURL), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrationLockID is an arbitrary key for the PostgreSQL advisory lock that keeps
+// replicas starting at the same time from applying migrations concurrently
+const migrationLockID = 72730412
+
+// migrate brings the schema up to the latest version inside per-migration transactions
+func migrate(db *sql.DB, dialect string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if dialect == storage.DriverPostgres {
+		if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+	}
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER   NOT NULL PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var current int
+	row := conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations")
+	if err = row.Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for _, m := range sqlMigrations {
+		if m.Version <= current {
+			continue
+		}
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, stmt := range m.Statements {
+			if _, err = tx.ExecContext(ctx, stmt); err != nil {
+				_ = tx.Rollback()
+				return fmt.Errorf("migration %d failed: %w", m.Version, err)
+			}
+		}
+		if m.Backfill != nil {
+			if err = m.Backfill(ctx, tx, dialect); err != nil {
+				_ = tx.Rollback()
+				return fmt.Errorf("migration %d backfill failed: %w", m.Version, err)
+			}
+		}
+		_, err = tx.ExecContext(ctx, rebind(dialect, "INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)"), m.Version, time.Now().UTC())
+		if err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migration %d failed: %w", m.Version, err)
+		}
+		if err = tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d failed: %w", m.Version, err)
+		}
+		log.Printf("Applied SQL migration %d", m.Version)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/reactivex/rxgo/v2"
+	"sort"
+	"time"
+	"urlshortener/internal/domain"
+)
+
+// SQLReportServiceImpl implements ReportServiceInterface on top of database/sql. The
+// reports and banned_domains tables are created by the migrations applied in
+// SQLURLServiceImpl.InitDatabase.
+type SQLReportServiceImpl struct {
+	DB      *sql.DB
+	Dialect string
+}
+
+// SaveReport saves an abuse report to the database reactively
+func (s *SQLReportServiceImpl) SaveReport(report domain.Report) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		query := rebind(s.Dialect, `INSERT INTO reports (id, short_id, tenant, reason, details, reporter_ip, reporter_agent,
+			reporter_email, created_at, status, closed_at, closed_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		_, err := s.DB.ExecContext(ctx, query, report.ID, report.ShortID, report.Tenant, report.Reason, report.Details, report.ReporterIP,
+			report.ReporterAgent, report.ReporterEmail, report.CreatedAt.UTC(), report.Status, nullTime(report.ClosedAt), report.ClosedBy)
+		if err != nil {
+			ch <- rxgo.Error(errors.New("failed to save report"))
+		} else {
+			ch <- rxgo.Of(report)
+		}
+	}})
+}
+
+// ListReportedURLs summarizes the open reports of a tenant per link, most reported first, reactively
+func (s *SQLReportServiceImpl) ListReportedURLs(tenant string, limit int) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// The latest report is found with ORDER BY rather than MAX(created_at), whose type
+		// SQLite does not report, so it could not be scanned as a time
+		query := rebind(s.Dialect, `SELECT short_id, reason, created_at FROM reports WHERE tenant = ? AND status = ?
+			ORDER BY short_id, created_at DESC`)
+		rows, err := s.DB.QueryContext(ctx, query, tenant, domain.ReportOpen)
+		if err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		defer rows.Close()
+
+		reportedURLs := []domain.ReportedURL{}
+		for rows.Next() {
+			var shortID, reason string
+			var createdAt sql.NullTime
+			if err = rows.Scan(&shortID, &reason, &createdAt); err != nil {
+				ch <- rxgo.Error(err)
+				return
+			}
+			last := len(reportedURLs) - 1
+			if last < 0 || reportedURLs[last].ShortID != shortID {
+				reportedURLs = append(reportedURLs, domain.ReportedURL{ShortID: shortID, LastReportedAt: createdAt.Time.UTC()})
+				last++
+			}
+			reportedURLs[last].ReportCount++
+			if !containsReason(reportedURLs[last].Reasons, reason) {
+				reportedURLs[last].Reasons = append(reportedURLs[last].Reasons, reason)
+			}
+		}
+		if err = rows.Err(); err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+
+		for i := range reportedURLs {
+			sort.Strings(reportedURLs[i].Reasons)
+		}
+		sortReportedURLs(reportedURLs)
+		if len(reportedURLs) > limit {
+			reportedURLs = reportedURLs[:limit]
+		}
+		ch <- rxgo.Of(reportedURLs)
+	}})
+}
+
+// CloseReports closes the open reports of a link reactively
+func (s *SQLReportServiceImpl) CloseReports(shortID, status, closedBy string, closedAt time.Time) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		query := rebind(s.Dialect, "UPDATE reports SET status = ?, closed_at = ?, closed_by = ? WHERE short_id = ? AND status = ?")
+		result, err := s.DB.ExecContext(ctx, query, status, closedAt.UTC(), closedBy, shortID, domain.ReportOpen)
+		if err != nil {
+			ch <- rxgo.Error(errors.New("failed to close reports"))
+			return
+		}
+		closed, err := result.RowsAffected()
+		if err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		ch <- rxgo.Of(closed)
+	}})
+}
+
+// SaveBannedDomain bans a domain reactively; banning it again keeps the original ban
+func (s *SQLReportServiceImpl) SaveBannedDomain(ban domain.BannedDomain) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		query := rebind(s.Dialect, "INSERT INTO banned_domains (domain, banned_at, banned_by) VALUES (?, ?, ?) ON CONFLICT (domain) DO NOTHING")
+		if _, err := s.DB.ExecContext(ctx, query, ban.Domain, ban.BannedAt.UTC(), ban.BannedBy); err != nil {
+			ch <- rxgo.Error(errors.New("failed to ban domain"))
+			return
+		}
+
+		row := s.DB.QueryRowContext(ctx, rebind(s.Dialect, "SELECT domain, banned_at, banned_by FROM banned_domains WHERE domain = ?"), ban.Domain)
+		stored, err := scanBannedDomain(row)
+		if err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		ch <- rxgo.Of(stored)
+	}})
+}
+
+// ListBannedDomains retrieves the banned domains, sorted by name, reactively
+func (s *SQLReportServiceImpl) ListBannedDomains() rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		rows, err := s.DB.QueryContext(ctx, "SELECT domain, banned_at, banned_by FROM banned_domains ORDER BY domain")
+		if err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		defer rows.Close()
+
+		bans := []domain.BannedDomain{}
+		for rows.Next() {
+			ban, err := scanBannedDomain(rows)
+			if err != nil {
+				ch <- rxgo.Error(err)
+				return
+			}
+			bans = append(bans, ban)
+		}
+		if err = rows.Err(); err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		ch <- rxgo.Of(bans)
+	}})
+}
+
+// DeleteBannedDomain lifts the ban on a domain reactively
+func (s *SQLReportServiceImpl) DeleteBannedDomain(domainName string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		result, err := s.DB.ExecContext(ctx, rebind(s.Dialect, "DELETE FROM banned_domains WHERE domain = ?"), domainName)
+		if err != nil {
+			ch <- rxgo.Error(errors.New("failed to lift domain ban"))
+			return
+		}
+		if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+			ch <- rxgo.Error(ErrBannedDomainNotFound)
+			return
+		}
+		ch <- rxgo.Of(domainName)
+	}})
+}
+
+// scanBannedDomain reads one banned_domains row from a *sql.Row or *sql.Rows
+func scanBannedDomain(row interface{ Scan(dest ...any) error }) (domain.BannedDomain, error) {
+	var ban domain.BannedDomain
+	var bannedAt sql.NullTime
+	err := row.Scan(&ban.Domain, &bannedAt, &ban.BannedBy)
+	ban.BannedAt = bannedAt.Time.UTC()
+	return ban, err
+}
+
+// containsReason reports whether reasons already lists reason
+func containsReason(reasons []string, reason string) bool {
+	for _, r := range reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,407 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/reactivex/rxgo/v2"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"strconv"
+	"strings"
+	"time"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/storage"
+)
+
+// urlColumns is the column list matching scanURL
+const urlColumns = "id, original_url, short_url, enabled, quarantine, warning, password_hash, expires_at, max_clicks, deleted_at, created_at, domain, tenant, owner, tags, click_count, updated_at, created_by, updated_by"
+
+// SQLURLServiceImpl implements URLServiceInterface on top of database/sql.
+// Dialect is either storage.DriverSQLite or storage.DriverPostgres.
+type SQLURLServiceImpl struct {
+	DB      *sql.DB
+	Dialect string
+}
+
+// InitDatabase assigns the connection pool and applies pending schema migrations
+func (s *SQLURLServiceImpl) InitDatabase(db *sql.DB, dialect string) error {
+	s.DB = db
+	s.Dialect = dialect
+	return migrate(db, dialect)
+}
+
+// SaveURL saves a URL to the database reactively
+func (s *SQLURLServiceImpl) SaveURL(url domain.URL) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		query := s.rebind("INSERT INTO urls (" + urlColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		_, err := s.DB.ExecContext(ctx, query, url.ID, url.OriginalURL, url.ShortURL, url.Enabled, url.Quarantine, url.Warning, url.PasswordHash, nullTime(url.ExpiresAt), url.MaxClicks, nullTime(url.DeletedAt),
+			url.CreatedAt.UTC(), url.Domain, url.Tenant, url.Owner, joinTags(url.Tags), url.ClickCount, url.UpdatedAt.UTC(), url.CreatedBy, url.UpdatedBy)
+		if isUniqueViolation(err) {
+			ch <- rxgo.Error(ErrDuplicateURL)
+		} else if err != nil {
+			ch <- rxgo.Error(errors.New("failed to save URL"))
+		} else {
+			ch <- rxgo.Of(url)
+		}
+	}})
+}
+
+// GetURL retrieves a URL of the tenant from the database by its ID reactively
+func (s *SQLURLServiceImpl) GetURL(tenant, shortID string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		row := s.DB.QueryRowContext(ctx, s.rebind("SELECT "+urlColumns+" FROM urls WHERE id = ? AND tenant = ?"), shortID, tenant)
+		url, err := scanURL(row)
+		if errors.Is(err, sql.ErrNoRows) {
+			ch <- rxgo.Error(ErrURLNotFound)
+		} else if err != nil {
+			ch <- rxgo.Error(err)
+		} else {
+			ch <- rxgo.Of(url)
+		}
+	}})
+}
+
+// GetURLTenant retrieves the tenant a short ID belongs to from the database reactively
+func (s *SQLURLServiceImpl) GetURLTenant(shortID string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var tenant string
+		err := s.DB.QueryRowContext(ctx, s.rebind("SELECT tenant FROM urls WHERE id = ?"), shortID).Scan(&tenant)
+		if errors.Is(err, sql.ErrNoRows) {
+			ch <- rxgo.Error(ErrURLNotFound)
+		} else if err != nil {
+			ch <- rxgo.Error(err)
+		} else {
+			ch <- rxgo.Of(tenant)
+		}
+	}})
+}
+
+// UpdateURL updates the mutable fields of a URL in the database reactively
+func (s *SQLURLServiceImpl) UpdateURL(url domain.URL) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		query := s.rebind("UPDATE urls SET enabled = ?, quarantine = ?, warning = ?, password_hash = ?, original_url = ?, expires_at = ?, max_clicks = ?, deleted_at = ?, domain = ?, tags = ?, updated_at = ?, updated_by = ? WHERE id = ? AND tenant = ?")
+		_, err := s.DB.ExecContext(ctx, query, url.Enabled, url.Quarantine, url.Warning, url.PasswordHash, url.OriginalURL, nullTime(url.ExpiresAt), url.MaxClicks, nullTime(url.DeletedAt),
+			url.Domain, joinTags(url.Tags), url.UpdatedAt.UTC(), url.UpdatedBy, url.ID, url.Tenant)
+		if isUniqueViolation(err) {
+			ch <- rxgo.Error(ErrDuplicateURL)
+		} else if err != nil {
+			ch <- rxgo.Error(errors.New("failed to update URL"))
+		} else {
+			ch <- rxgo.Of(url)
+		}
+	}})
+}
+
+// FindURLByOriginal searches for a URL of the tenant and owner by its original URL in the database reactively
+func (s *SQLURLServiceImpl) FindURLByOriginal(originalURL, tenant, owner string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		row := s.DB.QueryRowContext(ctx, s.rebind("SELECT "+urlColumns+" FROM urls WHERE original_url = ? AND tenant = ? AND owner = ?"), originalURL, tenant, owner)
+		url, err := scanURL(row)
+		if errors.Is(err, sql.ErrNoRows) {
+			ch <- rxgo.Error(ErrURLNotFound) // Returns error if not found
+		} else if err != nil {
+			ch <- rxgo.Error(err)
+		} else {
+			ch <- rxgo.Of(url)
+		}
+	}})
+}
+
+// DeleteExpiredURLs removes URLs whose expiration time has passed and emits them reactively
+func (s *SQLURLServiceImpl) DeleteExpiredURLs(now time.Time) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		rows, err := s.DB.QueryContext(ctx, s.rebind("DELETE FROM urls WHERE expires_at <= ? RETURNING "+urlColumns), now.UTC())
+		if err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		defer rows.Close()
+
+		deleted := []domain.URL{}
+		for rows.Next() {
+			url, err := scanURL(rows)
+			if err != nil {
+				ch <- rxgo.Error(err)
+				return
+			}
+			deleted = append(deleted, url)
+		}
+		if err = rows.Err(); err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		ch <- rxgo.Of(deleted)
+	}})
+}
+
+// DeleteURL permanently removes a URL of the tenant from the database reactively
+func (s *SQLURLServiceImpl) DeleteURL(tenant, shortID string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		result, err := s.DB.ExecContext(ctx, s.rebind("DELETE FROM urls WHERE id = ? AND tenant = ?"), shortID, tenant)
+		if err != nil {
+			ch <- rxgo.Error(errors.New("failed to delete URL"))
+			return
+		}
+		if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+			ch <- rxgo.Error(ErrURLNotFound)
+			return
+		}
+		ch <- rxgo.Of(shortID)
+	}})
+}
+
+// FindDeletedURLs retrieves the URLs moved to the trash no later than before reactively
+func (s *SQLURLServiceImpl) FindDeletedURLs(before time.Time) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		rows, err := s.DB.QueryContext(ctx, s.rebind("SELECT "+urlColumns+" FROM urls WHERE deleted_at <= ?"), before.UTC())
+		if err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		defer rows.Close()
+
+		urls := []domain.URL{}
+		for rows.Next() {
+			url, err := scanURL(rows)
+			if err != nil {
+				ch <- rxgo.Error(err)
+				return
+			}
+			urls = append(urls, url)
+		}
+		if err = rows.Err(); err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		ch <- rxgo.Of(urls)
+	}})
+}
+
+// ListURLs retrieves a filtered and sorted page of live URLs reactively. Pages are
+// delimited with a keyset cursor on the sort column and the short ID.
+func (s *SQLURLServiceImpl) ListURLs(query domain.URLQuery) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		where, args := listConditions(query)
+		var total int64
+		row := s.DB.QueryRowContext(ctx, s.rebind("SELECT COUNT(*) FROM urls WHERE "+strings.Join(where, " AND ")), args...)
+		if err := row.Scan(&total); err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+
+		sortColumn, direction, after := "created_at", "ASC", ">"
+		if query.SortBy == domain.SortByClickCount {
+			sortColumn = "click_count"
+		}
+		if query.Descending {
+			direction, after = "DESC", "<"
+		}
+		if query.After != nil {
+			var value any = query.After.CreatedAt.UTC()
+			if query.SortBy == domain.SortByClickCount {
+				value = query.After.ClickCount
+			}
+			where = append(where, "("+sortColumn+" "+after+" ? OR ("+sortColumn+" = ? AND id "+after+" ?))")
+			args = append(args, value, value, query.After.ID)
+		}
+
+		// One extra row tells whether another page follows
+		statement := "SELECT " + urlColumns + " FROM urls WHERE " + strings.Join(where, " AND ") +
+			" ORDER BY " + sortColumn + " " + direction + ", id " + direction + " LIMIT ?"
+		rows, err := s.DB.QueryContext(ctx, s.rebind(statement), append(args, query.Limit+1)...)
+		if err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		defer rows.Close()
+
+		page := domain.URLPage{URLs: []domain.URL{}, Total: total}
+		for rows.Next() {
+			url, err := scanURL(rows)
+			if err != nil {
+				ch <- rxgo.Error(err)
+				return
+			}
+			if len(page.URLs) == query.Limit {
+				page.HasMore = true
+				break
+			}
+			page.URLs = append(page.URLs, url)
+		}
+		if err = rows.Err(); err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		ch <- rxgo.Of(page)
+	}})
+}
+
+// UpdateClickCount raises the stored click count of a URL of the tenant reactively; it
+// never lowers it
+func (s *SQLURLServiceImpl) UpdateClickCount(tenant, shortID string, count int64) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_, err := s.DB.ExecContext(ctx, s.rebind("UPDATE urls SET click_count = ? WHERE id = ? AND tenant = ? AND click_count < ?"), count, shortID, tenant, count)
+		if err != nil {
+			ch <- rxgo.Error(errors.New("failed to update click count"))
+		} else {
+			ch <- rxgo.Of(shortID)
+		}
+	}})
+}
+
+// listConditions translates the filters of a URL query, except its cursor, into WHERE conditions
+func listConditions(query domain.URLQuery) ([]string, []any) {
+	where := []string{"deleted_at IS NULL", "tenant = ?"}
+	args := []any{query.Tenant}
+	if query.Enabled != nil {
+		where = append(where, "enabled = ?")
+		args = append(args, *query.Enabled)
+	}
+	if query.CreatedFrom != nil {
+		where = append(where, "created_at >= ?")
+		args = append(args, query.CreatedFrom.UTC())
+	}
+	if query.CreatedTo != nil {
+		where = append(where, "created_at < ?")
+		args = append(args, query.CreatedTo.UTC())
+	}
+	if query.Domain != "" {
+		where = append(where, "domain = ?")
+		args = append(args, query.Domain)
+	}
+	if query.Owner != "" {
+		where = append(where, "owner = ?")
+		args = append(args, query.Owner)
+	}
+	if query.Tag != "" {
+		where = append(where, `tags LIKE ? ESCAPE '\'`)
+		args = append(args, likePattern("%,", query.Tag, ",%"))
+	}
+	if query.Search != "" {
+		where = append(where, `LOWER(original_url) LIKE ? ESCAPE '\'`)
+		args = append(args, likePattern("%", strings.ToLower(query.Search), "%"))
+	}
+	return where, args
+}
+
+func (s *SQLURLServiceImpl) rebind(query string) string {
+	return rebind(s.Dialect, query)
+}
+
+// scanURL reads one row selected with urlColumns from a *sql.Row or *sql.Rows
+func scanURL(row interface{ Scan(dest ...any) error }) (domain.URL, error) {
+	var url domain.URL
+	var expiresAt, deletedAt, createdAt, updatedAt sql.NullTime
+	var tags string
+	err := row.Scan(&url.ID, &url.OriginalURL, &url.ShortURL, &url.Enabled, &url.Quarantine, &url.Warning, &url.PasswordHash, &expiresAt, &url.MaxClicks, &deletedAt,
+		&createdAt, &url.Domain, &url.Tenant, &url.Owner, &tags, &url.ClickCount, &updatedAt, &url.CreatedBy, &url.UpdatedBy)
+	url.ExpiresAt = timePtr(expiresAt)
+	url.DeletedAt = timePtr(deletedAt)
+	url.CreatedAt = createdAt.Time.UTC()
+	url.UpdatedAt = updatedAt.Time.UTC()
+	url.Tags = splitTags(tags)
+	return url, err
+}
+
+// joinTags stores tags as ",a,b," so a single tag can be matched with LIKE '%,a,%'
+func joinTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return "," + strings.Join(tags, ",") + ","
+}
+
+// splitTags reverses joinTags
+func splitTags(tags string) []string {
+	tags = strings.Trim(tags, ",")
+	if tags == "" {
+		return nil
+	}
+	return strings.Split(tags, ",")
+}
+
+// likePattern escapes the LIKE wildcards of value and wraps it in prefix and suffix
+func likePattern(prefix, value, suffix string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+	return prefix + escaped + suffix
+}
+
+// timePtr converts a nullable SQL time to an optional UTC time
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	utc := t.Time.UTC()
+	return &utc
+}
+
+// nullTime converts an optional time to a UTC SQL value
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// rebind rewrites "?" placeholders to "$n" for PostgreSQL
+func rebind(dialect, query string) string {
+	if dialect != storage.DriverPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// isUniqueViolation reports whether err was caused by a unique or primary key constraint
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	return false
+}
@@ -3,9 +3,14 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/reactivex/rxgo/v2"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"regexp"
+	"strings"
 	"time"
 	"urlshortener/internal/domain"
 	"urlshortener/internal/interfaces"
@@ -16,10 +21,166 @@ type URLServiceImpl struct {
 	UrlCollection interfaces.URLCollectionInterface
 }
 
-// InitDatabase initializes the MongoDB connection and assigns the collection
-func (s *URLServiceImpl) InitDatabase(client *mongo.Client, dbName, collectionName string) interfaces.URLCollectionInterface {
-	s.UrlCollection = client.Database(dbName).Collection(collectionName)
-	return s.UrlCollection
+// InitDatabase initializes the MongoDB connection, assigns the collection, ensures the
+// unique index on the short ID, the TTL index removing expired URLs and the listing
+// indexes exist, and backfills the fields added since documents were first stored
+func (s *URLServiceImpl) InitDatabase(client *mongo.Client, dbName, collectionName string) error {
+	collection := client.Database(dbName).Collection(collectionName)
+	s.UrlCollection = collection
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := checkDuplicateIDs(ctx, collection); err != nil {
+		return err
+	}
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("id_unique"),
+		},
+		{
+			// Documents without expires_at are never removed by the TTL monitor
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0).SetName("expires_at_ttl"),
+		},
+		{
+			Keys:    bson.D{{Key: "created_at", Value: 1}, {Key: "id", Value: 1}},
+			Options: options.Index().SetName("created_at_id"),
+		},
+		{
+			Keys:    bson.D{{Key: "click_count", Value: 1}, {Key: "id", Value: 1}},
+			Options: options.Index().SetName("click_count_id"),
+		},
+		{
+			Keys:    bson.D{{Key: "domain", Value: 1}, {Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("domain_created_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "owner", Value: 1}, {Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("owner_created_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "tenant", Value: 1}, {Key: "created_at", Value: 1}, {Key: "id", Value: 1}},
+			Options: options.Index().SetName("tenant_created_at_id"),
+		},
+		{
+			Keys:    bson.D{{Key: "tags", Value: 1}},
+			Options: options.Index().SetName("tags"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err = backfillListingFields(ctx, collection); err != nil {
+		return err
+	}
+	if err = backfillUpdatedAt(ctx, collection); err != nil {
+		return err
+	}
+	if err = backfillOwner(ctx, collection); err != nil {
+		return err
+	}
+	return backfillTenant(ctx, collection)
+}
+
+// backfillTenant assigns documents stored before workspaces existed to the default tenant
+func backfillTenant(ctx context.Context, collection *mongo.Collection) error {
+	update := bson.M{"$set": bson.M{"tenant": domain.DefaultTenant}}
+	_, err := collection.UpdateMany(ctx, bson.M{"tenant": bson.M{"$exists": false}}, update)
+	return err
+}
+
+// backfillOwner makes the creator the owner of documents stored before ownership was
+// recorded. Documents without a known creator stay unowned and only admins manage them.
+func backfillOwner(ctx context.Context, collection *mongo.Collection) error {
+	filter := bson.M{"owner": bson.M{"$exists": false}, "created_by": bson.M{"$exists": true, "$ne": ""}}
+	pipeline := mongo.Pipeline{{{Key: "$set", Value: bson.M{"owner": "$created_by"}}}}
+	_, err := collection.UpdateMany(ctx, filter, pipeline)
+	return err
+}
+
+// backfillUpdatedAt sets updated_at to created_at on documents stored before changes were
+// tracked. Their authors are unknown, so created_by and updated_by stay unset.
+func backfillUpdatedAt(ctx context.Context, collection *mongo.Collection) error {
+	pipeline := mongo.Pipeline{{{Key: "$set", Value: bson.M{"updated_at": "$created_at"}}}}
+	_, err := collection.UpdateMany(ctx, bson.M{"updated_at": bson.M{"$exists": false}}, pipeline)
+	return err
+}
+
+// maxReportedDuplicates bounds the short IDs listed when duplicates block the unique index
+const maxReportedDuplicates = 20
+
+// checkDuplicateIDs fails, listing them, when short IDs stored before they were unique
+// would keep the unique index on id from being created. Once the index exists the
+// collection is not scanned again.
+func checkDuplicateIDs(ctx context.Context, collection *mongo.Collection) error {
+	specs, err := collection.Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		if spec.Name == "id_unique" {
+			return nil
+		}
+	}
+
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$id"}, {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}}}}},
+		{{Key: "$match", Value: bson.D{{Key: "count", Value: bson.D{{Key: "$gt", Value: 1}}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: maxReportedDuplicates}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	var duplicates []struct {
+		ID    string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return err
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	listed := make([]string, len(duplicates))
+	for i, duplicate := range duplicates {
+		listed[i] = fmt.Sprintf("%q (%d documents)", duplicate.ID, duplicate.Count)
+	}
+	return fmt.Errorf("cannot create the unique index on id: short IDs %s are stored more than once; "+
+		"delete the extra documents or give them new IDs and restart (at most %d are listed)",
+		strings.Join(listed, ", "), maxReportedDuplicates)
+}
+
+// backfillListingFields sets created_at (taken from the ObjectID), domain and click_count
+// on documents stored before URLs could be listed
+func backfillListingFields(ctx context.Context, collection *mongo.Collection) error {
+	cursor, err := collection.Find(ctx, bson.M{"created_at": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ObjectID    primitive.ObjectID `bson:"_id"`
+			OriginalURL string             `bson:"original_url"`
+		}
+		if err = cursor.Decode(&doc); err != nil {
+			return err
+		}
+		update := bson.M{"$set": bson.M{
+			"created_at":  doc.ObjectID.Timestamp(),
+			"domain":      domain.DestinationHost(doc.OriginalURL),
+			"click_count": int64(0),
+		}}
+		if _, err = collection.UpdateByID(ctx, doc.ObjectID, update); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
 }
 
 // SaveURL saves a URL to the database reactively
@@ -29,7 +190,9 @@ func (s *URLServiceImpl) SaveURL(url domain.URL) rxgo.Observable {
 		defer cancel()
 
 		_, err := s.UrlCollection.InsertOne(ctx, url)
-		if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			ch <- rxgo.Error(ErrDuplicateURL)
+		} else if err != nil {
 			ch <- rxgo.Error(errors.New("failed to save URL"))
 		} else {
 			ch <- rxgo.Of(url)
@@ -37,17 +200,17 @@ func (s *URLServiceImpl) SaveURL(url domain.URL) rxgo.Observable {
 	}})
 }
 
-// GetURL retrieves a URL from the database by its ID reactively
-func (s *URLServiceImpl) GetURL(shortID string) rxgo.Observable {
+// GetURL retrieves a URL of the tenant from the database by its ID reactively
+func (s *URLServiceImpl) GetURL(tenant, shortID string) rxgo.Observable {
 	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
 		var url domain.URL
-		filter := bson.M{"id": shortID}
+		filter := bson.M{"id": shortID, "tenant": tenant}
 		err := s.UrlCollection.FindOne(ctx, filter).Decode(&url)
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			ch <- rxgo.Error(errors.New("URL not found"))
+			ch <- rxgo.Error(ErrURLNotFound)
 		} else if err != nil {
 			ch <- rxgo.Error(err)
 		} else {
@@ -56,16 +219,74 @@ func (s *URLServiceImpl) GetURL(shortID string) rxgo.Observable {
 	}})
 }
 
-// UpdateURL updates the enabled state or original URL in the database reactively
+// GetURLTenant retrieves the tenant a short ID belongs to from the database reactively
+func (s *URLServiceImpl) GetURLTenant(shortID string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var doc struct {
+			Tenant string `bson:"tenant"`
+		}
+		opts := options.FindOne().SetProjection(bson.M{"tenant": 1})
+		err := s.UrlCollection.FindOne(ctx, bson.M{"id": shortID}, opts).Decode(&doc)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ch <- rxgo.Error(ErrURLNotFound)
+		} else if err != nil {
+			ch <- rxgo.Error(err)
+		} else {
+			ch <- rxgo.Of(doc.Tenant)
+		}
+	}})
+}
+
+// UpdateURL updates the mutable fields of a URL in the database reactively
 func (s *URLServiceImpl) UpdateURL(url domain.URL) rxgo.Observable {
 	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		filter := bson.M{"id": url.ID}
-		update := bson.M{"$set": bson.M{"enabled": url.Enabled, "original_url": url.OriginalURL}}
+		filter := bson.M{"id": url.ID, "tenant": url.Tenant}
+		set := bson.M{
+			"enabled":      url.Enabled,
+			"original_url": url.OriginalURL,
+			"max_clicks":   url.MaxClicks,
+			"domain":       url.Domain,
+			"updated_at":   url.UpdatedAt,
+			"updated_by":   url.UpdatedBy,
+		}
+		unset := bson.M{}
+		if len(url.Tags) > 0 {
+			set["tags"] = url.Tags
+		} else {
+			unset["tags"] = ""
+		}
+		if url.Quarantine != "" {
+			set["quarantine"] = url.Quarantine
+		} else {
+			unset["quarantine"] = ""
+		}
+		if url.Warning != "" {
+			set["warning"] = url.Warning
+		} else {
+			unset["warning"] = ""
+		}
+		if url.PasswordHash != "" {
+			set["password_hash"] = url.PasswordHash
+		} else {
+			unset["password_hash"] = ""
+		}
+		// Removing expires_at also takes the document out of the TTL index
+		setOrUnsetTime(set, unset, "expires_at", url.ExpiresAt)
+		setOrUnsetTime(set, unset, "deleted_at", url.DeletedAt)
+		update := bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
 		_, err := s.UrlCollection.UpdateOne(ctx, filter, update)
-		if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			ch <- rxgo.Error(ErrDuplicateURL)
+		} else if err != nil {
 			ch <- rxgo.Error(errors.New("failed to update URL"))
 		} else {
 			ch <- rxgo.Of(url)
@@ -73,14 +294,18 @@ func (s *URLServiceImpl) UpdateURL(url domain.URL) rxgo.Observable {
 	}})
 }
 
-// FindURLByOriginal searches for a URL by its original URL in the database reactively
-func (s *URLServiceImpl) FindURLByOriginal(originalURL string) rxgo.Observable {
+// FindURLByOriginal searches for a URL of the tenant and owner by its original URL in the database reactively
+func (s *URLServiceImpl) FindURLByOriginal(originalURL, tenant, owner string) rxgo.Observable {
 	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
 		var url domain.URL
-		filter := bson.M{"original_url": originalURL}
+		filter := bson.M{"original_url": originalURL, "tenant": tenant, "owner": owner}
+		if owner == "" {
+			// Documents without an owner omit the field
+			filter["owner"] = bson.M{"$in": bson.A{nil, ""}}
+		}
 		err := s.UrlCollection.FindOne(ctx, filter).Decode(&url)
 		if err != nil {
 			ch <- rxgo.Error(err) // Returns error if not found
@@ -89,3 +314,150 @@ func (s *URLServiceImpl) FindURLByOriginal(originalURL string) rxgo.Observable {
 		}
 	}})
 }
+
+// DeleteURL permanently removes a URL of the tenant from the database reactively
+func (s *URLServiceImpl) DeleteURL(tenant, shortID string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		result, err := s.UrlCollection.DeleteOne(ctx, bson.M{"id": shortID, "tenant": tenant})
+		if err != nil {
+			ch <- rxgo.Error(errors.New("failed to delete URL"))
+		} else if result.DeletedCount == 0 {
+			ch <- rxgo.Error(ErrURLNotFound)
+		} else {
+			ch <- rxgo.Of(shortID)
+		}
+	}})
+}
+
+// FindDeletedURLs retrieves the URLs moved to the trash no later than before reactively
+func (s *URLServiceImpl) FindDeletedURLs(before time.Time) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		cursor, err := s.UrlCollection.Find(ctx, bson.M{"deleted_at": bson.M{"$lte": before}})
+		if err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		urls := []domain.URL{}
+		if err = cursor.All(ctx, &urls); err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		ch <- rxgo.Of(urls)
+	}})
+}
+
+// ListURLs retrieves a filtered and sorted page of live URLs reactively. Pages are
+// delimited with a keyset cursor on the sort field and the short ID.
+func (s *URLServiceImpl) ListURLs(query domain.URLQuery) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		filter := listFilter(query)
+		total, err := s.UrlCollection.CountDocuments(ctx, filter)
+		if err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+
+		sortField, direction, after := query.SortBy, 1, "$gt"
+		if sortField != domain.SortByClickCount {
+			sortField = domain.SortByCreatedAt
+		}
+		if query.Descending {
+			direction, after = -1, "$lt"
+		}
+		if query.After != nil {
+			var value interface{} = query.After.CreatedAt
+			if sortField == domain.SortByClickCount {
+				value = query.After.ClickCount
+			}
+			filter = bson.M{"$and": bson.A{filter, bson.M{"$or": bson.A{
+				bson.M{sortField: bson.M{after: value}},
+				bson.M{sortField: value, "id": bson.M{after: query.After.ID}},
+			}}}}
+		}
+
+		opts := options.Find().
+			SetSort(bson.D{{Key: sortField, Value: direction}, {Key: "id", Value: direction}}).
+			SetLimit(int64(query.Limit) + 1) // One extra document tells whether another page follows
+		cursor, err := s.UrlCollection.Find(ctx, filter, opts)
+		if err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		urls := []domain.URL{}
+		if err = cursor.All(ctx, &urls); err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+
+		page := domain.URLPage{URLs: urls, Total: total}
+		if len(urls) > query.Limit {
+			page.URLs, page.HasMore = urls[:query.Limit], true
+		}
+		ch <- rxgo.Of(page)
+	}})
+}
+
+// UpdateClickCount raises the stored click count of a URL of the tenant reactively; it
+// never lowers it
+func (s *URLServiceImpl) UpdateClickCount(tenant, shortID string, count int64) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_, err := s.UrlCollection.UpdateOne(ctx, bson.M{"id": shortID, "tenant": tenant}, bson.M{"$max": bson.M{"click_count": count}})
+		if err != nil {
+			ch <- rxgo.Error(errors.New("failed to update click count"))
+		} else {
+			ch <- rxgo.Of(shortID)
+		}
+	}})
+}
+
+// listFilter translates the filters of a URL query, except its cursor, into a MongoDB filter
+func listFilter(query domain.URLQuery) bson.M {
+	filter := bson.M{"deleted_at": nil, "tenant": query.Tenant}
+	if query.Enabled != nil {
+		filter["enabled"] = *query.Enabled
+	}
+	createdAt := bson.M{}
+	if query.CreatedFrom != nil {
+		createdAt["$gte"] = *query.CreatedFrom
+	}
+	if query.CreatedTo != nil {
+		createdAt["$lt"] = *query.CreatedTo
+	}
+	if len(createdAt) > 0 {
+		filter["created_at"] = createdAt
+	}
+	if query.Domain != "" {
+		filter["domain"] = query.Domain
+	}
+	if query.Owner != "" {
+		filter["owner"] = query.Owner
+	}
+	if query.Tag != "" {
+		filter["tags"] = query.Tag
+	}
+	if query.Search != "" {
+		filter["original_url"] = primitive.Regex{Pattern: regexp.QuoteMeta(query.Search), Options: "i"}
+	}
+	return filter
+}
+
+// setOrUnsetTime adds an optional time field to the $set document, or to $unset when it is nil
+func setOrUnsetTime(set, unset bson.M, field string, value *time.Time) {
+	if value != nil {
+		set[field] = *value
+	} else {
+		unset[field] = ""
+	}
+}
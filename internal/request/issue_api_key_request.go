@@ -0,0 +1,8 @@
+package request
+
+// IssueAPIKeyRequest defines the structure for API key creation requests
+type IssueAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required"`   // Label to recognise the key, e.g. the client using it
+	Scopes []string `json:"scopes" binding:"required"` // create, manage, read-stats and/or admin
+	Tenant string   `json:"tenant"`                    // Tenant of the key; defaults to the caller's
+}
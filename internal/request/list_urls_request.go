@@ -0,0 +1,17 @@
+package request
+
+import "time"
+
+// ListURLsRequest defines the query string of a URL listing; every filter is optional
+type ListURLsRequest struct {
+	Enabled     *bool      `form:"enabled"`      // Only enabled (true) or disabled (false) links
+	CreatedFrom *time.Time `form:"created_from"` // Created at or after this moment (RFC 3339)
+	CreatedTo   *time.Time `form:"created_to"`   // Created before this moment (RFC 3339)
+	Domain      string     `form:"domain"`       // Exact host of the destination
+	Owner       string     `form:"owner"`        // Exact owner of the link
+	Tag         string     `form:"tag"`          // Links carrying this tag
+	Query       string     `form:"q"`            // Case-insensitive substring of the destination
+	Sort        string     `form:"sort"`         // created_at or click_count, prefixed with '-' for descending order
+	Limit       int        `form:"limit"`        // Page size, 20 by default and at most 100
+	Cursor      string     `form:"cursor"`       // next_cursor of the previous page
+}
@@ -0,0 +1,13 @@
+package request
+
+// ReportRequest defines the structure for abuse reports filed against a link
+type ReportRequest struct {
+	Reason  string `json:"reason" binding:"required"` // phishing, malware, spam or other
+	Details string `json:"details"`                   // Optional free text, up to 1000 characters
+	Email   string `json:"email"`                     // Optional address to contact the reporter
+}
+
+// ModerationRequest defines the action a moderator takes on a reported link
+type ModerationRequest struct {
+	Action string `json:"action" binding:"required"` // dismiss, warn, unwarn, disable, enable or ban_domain
+}
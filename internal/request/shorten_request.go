@@ -1,6 +1,15 @@
 package request
 
+import "time"
+
 // ShortenRequest defines the structure for URL shortening requests
 type ShortenRequest struct {
-	OriginalURL string `json:"original_url" binding:"required"`
+	OriginalURL string     `json:"original_url" binding:"required"`
+	Alias       string     `json:"alias"`      // Optional custom short code used instead of a generated ID
+	ExpiresAt   *time.Time `json:"expires_at"` // Optional absolute expiration time (RFC 3339)
+	TTL         int64      `json:"ttl"`        // Optional lifetime in seconds; mutually exclusive with ExpiresAt
+	MaxClicks   int64      `json:"max_clicks"` // Optional number of redirects before the link is disabled; 1 for one-time links
+	Tags        []string   `json:"tags"`       // Optional labels used to filter listings
+	Warn        bool       `json:"warn"`       // Show a warning page with the destination before redirecting
+	Password    string     `json:"password"`   // Optional password asked for before redirecting
 }
@@ -0,0 +1,14 @@
+package request
+
+import "time"
+
+// UpdateURLRequest defines the mutable fields of a short URL; omitted fields are left unchanged
+type UpdateURLRequest struct {
+	OriginalURL *string    `json:"original_url"` // New destination
+	ExpiresAt   *time.Time `json:"expires_at"`   // New absolute expiration time (RFC 3339)
+	TTL         *int64     `json:"ttl"`          // New lifetime in seconds from now; 0 removes the expiration
+	MaxClicks   *int64     `json:"max_clicks"`   // New click limit; 0 removes the limit
+	Tags        *[]string  `json:"tags"`         // New labels; an empty list removes them all
+	Warn        *bool      `json:"warn"`         // Set or lift the warning page; moderator warnings cannot be lifted
+	Password    *string    `json:"password"`     // New password asked for before redirecting; an empty string removes it
+}
@@ -0,0 +1,6 @@
+package request
+
+// URLStateRequest defines the explicit state requested for a short URL
+type URLStateRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
@@ -0,0 +1,263 @@
+package safety
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"golang.org/x/net/idna"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config lists the destinations a Checker rejects
+type Config struct {
+	Blocklist      []string // Domains whose destinations, subdomains included, are rejected
+	Allowlist      []string // When set, only destinations on these domains and their subdomains are accepted
+	ThreatListPath string   // Threat list file of SHA-256 hash prefixes, see LoadThreatList; empty disables it
+}
+
+// Checker decides whether destinations are safe to shorten and redirect to. The threat
+// list is swapped atomically on Reload so checks never see a partial list.
+type Checker struct {
+	blocklist      []string
+	allowlist      []string
+	threatListPath string
+
+	mu      sync.RWMutex
+	threats *ThreatList
+	modTime time.Time
+	size    int64
+}
+
+// NewChecker creates a Checker and loads its threat list, if any
+func NewChecker(config Config) (*Checker, error) {
+	blocklist, err := normalizeDomains(config.Blocklist)
+	if err != nil {
+		return nil, err
+	}
+	allowlist, err := normalizeDomains(config.Allowlist)
+	if err != nil {
+		return nil, err
+	}
+	checker := &Checker{blocklist: blocklist, allowlist: allowlist, threatListPath: config.ThreatListPath}
+	if checker.threatListPath != "" {
+		if err := checker.Reload(); err != nil {
+			return nil, err
+		}
+	}
+	return checker, nil
+}
+
+// Reload reads the threat list file again; the previous list stays in use on error
+func (c *Checker) Reload() error {
+	info, err := os.Stat(c.threatListPath)
+	if err != nil {
+		return fmt.Errorf("failed to read threat list: %w", err)
+	}
+	threats, err := LoadThreatList(c.threatListPath)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.threats, c.modTime, c.size = threats, info.ModTime(), info.Size()
+	c.mu.Unlock()
+	log.Printf("Loaded %d threat list hash prefixes", threats.Len())
+	return nil
+}
+
+// StartReload checks the threat list file every interval and reloads it when it changed,
+// so lists can be updated without restarting
+func (c *Checker) StartReload(interval time.Duration) {
+	if c.threatListPath == "" || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			info, err := os.Stat(c.threatListPath)
+			if err != nil {
+				log.Printf("Error checking threat list: %v", err)
+				continue
+			}
+			c.mu.RLock()
+			changed := !info.ModTime().Equal(c.modTime) || info.Size() != c.size
+			c.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := c.Reload(); err != nil {
+				log.Printf("Error reloading threat list: %v", err)
+			}
+		}
+	}()
+}
+
+// Check reports whether rawURL must be rejected and why
+func (c *Checker) Check(rawURL string) (string, bool) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "destination cannot be parsed", true
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+
+	if entry, found := MatchDomain(host, c.blocklist); found {
+		return fmt.Sprintf("destination domain %s is blocked", entry), true
+	}
+	if len(c.allowlist) > 0 {
+		if _, found := MatchDomain(host, c.allowlist); !found {
+			return fmt.Sprintf("destination domain %s is not allowed", host), true
+		}
+	}
+
+	c.mu.RLock()
+	threats := c.threats
+	c.mu.RUnlock()
+	if threats != nil && threats.Matches(parsed) {
+		return "destination is listed as phishing or malware", true
+	}
+	return "", false
+}
+
+// MatchDomain returns the entry of domains that host equals or is a subdomain of
+func MatchDomain(host string, domains []string) (string, bool) {
+	for _, entry := range domains {
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return entry, true
+		}
+	}
+	return "", false
+}
+
+// normalizeDomains lowercases domains, converts them to punycode and drops leading
+// wildcards, since entries always cover their subdomains
+func normalizeDomains(domains []string) ([]string, error) {
+	normalized := make([]string, 0, len(domains))
+	for _, entry := range domains {
+		entry = strings.Trim(strings.TrimPrefix(strings.TrimSpace(entry), "*."), ".")
+		if entry == "" {
+			continue
+		}
+		if net.ParseIP(entry) == nil {
+			ascii, err := idna.Lookup.ToASCII(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid domain %q: %w", entry, err)
+			}
+			entry = ascii
+		}
+		normalized = append(normalized, strings.ToLower(entry))
+	}
+	return normalized, nil
+}
+
+// ThreatList holds SHA-256 hash prefixes of unsafe URL expressions, in the spirit of the
+// Safe Browsing lists: a URL matches when the hash of any of its host suffix and path
+// prefix combinations starts with a listed prefix
+type ThreatList struct {
+	prefixes map[string]struct{}
+	lengths  []int // Distinct prefix lengths in bytes
+}
+
+// LoadThreatList reads a threat list file holding one hex-encoded hash prefix of 4 to 32
+// bytes per line. Blank lines and lines starting with '#' are ignored. A prefix is the
+// start of sha256(expression), where an expression is a host and path such as
+// "evil.example.com/login/" or "example.com/".
+func LoadThreatList(path string) (*ThreatList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read threat list: %w", err)
+	}
+	defer file.Close()
+
+	list := &ThreatList{prefixes: map[string]struct{}{}}
+	seenLengths := map[int]bool{}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		prefix, err := hex.DecodeString(line)
+		if err != nil || len(prefix) < 4 || len(prefix) > sha256.Size {
+			return nil, fmt.Errorf("threat list line %d must be a hex hash prefix of 4 to 32 bytes", lineNumber)
+		}
+		list.prefixes[string(prefix)] = struct{}{}
+		if !seenLengths[len(prefix)] {
+			seenLengths[len(prefix)] = true
+			list.lengths = append(list.lengths, len(prefix))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read threat list: %w", err)
+	}
+	return list, nil
+}
+
+// Len returns the number of hash prefixes in the list
+func (l *ThreatList) Len() int {
+	return len(l.prefixes)
+}
+
+// Matches reports whether any expression of the URL has a listed hash prefix
+func (l *ThreatList) Matches(parsed *url.URL) bool {
+	for _, expression := range Expressions(parsed) {
+		digest := sha256.Sum256([]byte(expression))
+		for _, length := range l.lengths {
+			if _, found := l.prefixes[string(digest[:length])]; found {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Expressions returns the host suffix and path prefix combinations looked up for a URL:
+// the exact host and up to four of its parent domains, except the top-level one, each
+// with the exact path and query, the exact path and up to four leading directories
+func Expressions(parsed *url.URL) []string {
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	hosts := []string{host}
+	if net.ParseIP(host) == nil {
+		labels := strings.Split(host, ".")
+		for i := max(1, len(labels)-5); i <= len(labels)-2; i++ {
+			hosts = append(hosts, strings.Join(labels[i:], "."))
+		}
+	}
+
+	path := parsed.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	var paths []string
+	if parsed.RawQuery != "" {
+		paths = append(paths, path+"?"+parsed.RawQuery)
+	}
+	paths = append(paths, path)
+	directory := "/"
+	paths = append(paths, directory)
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; i < len(segments)-1 && i < 3; i++ {
+		directory += segments[i] + "/"
+		paths = append(paths, directory)
+	}
+
+	seen := map[string]bool{}
+	var expressions []string
+	for _, h := range hosts {
+		for _, p := range paths {
+			expression := h + p
+			if !seen[expression] {
+				seen[expression] = true
+				expressions = append(expressions, expression)
+			}
+		}
+	}
+	return expressions
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	models2 "urlshortener/internal/models"
+)
+
+// aliasPattern restricts custom aliases to URL-safe characters
+var aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,32}$`)
+
+// reservedAliases cannot be used as custom aliases because they clash with API routes
+var reservedAliases = map[string]bool{
+	"shorten": true,
+	"stats":   true,
+	"system":  true,
+	"urls":    true,
+	"admin":   true,
+	"api":     true,
+	"report":  true,
+}
+
+// validateAlias checks that a requested alias is well formed and not reserved
+func validateAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) {
+		return &models2.APIError{
+			Code:    http.StatusBadRequest,
+			Message: "Alias must be 3 to 32 characters long and contain only letters, digits, '-' or '_'",
+		}
+	}
+	if reservedAliases[strings.ToLower(alias)] {
+		return &models2.APIError{
+			Code:    http.StatusBadRequest,
+			Message: "Alias is reserved",
+		}
+	}
+	return nil
+}
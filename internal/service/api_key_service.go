@@ -0,0 +1,214 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/interfaces"
+	models2 "urlshortener/internal/models"
+	"urlshortener/internal/repository"
+	"urlshortener/internal/request"
+)
+
+// APIKeyPrefix starts every API key token so keys are easy to recognise, e.g. in logs
+const APIKeyPrefix = "usk_"
+
+// BootstrapAPIKeyID identifies the admin key configured with ADMIN_API_KEY
+const BootstrapAPIKeyID = "bootstrap"
+
+// APIKeyServiceInstance APIKeyServiceInterface is the injected API key repository
+var APIKeyServiceInstance interfaces.APIKeyServiceInterface
+
+// bootstrapKeyHash is the hash of the configured admin key, empty when there is none
+var bootstrapKeyHash string
+
+// errInvalidAPIKey is returned for unknown and revoked API keys alike
+var errInvalidAPIKey = &models2.APIError{
+	Code:    http.StatusUnauthorized,
+	Message: "Invalid API key",
+}
+
+// errAPIKeyNotFound is returned by key management operations on unknown key IDs
+var errAPIKeyNotFound = &models2.APIError{
+	Code:    http.StatusNotFound,
+	Message: "API key not found",
+}
+
+// SetBootstrapAPIKey configures an admin key that is accepted without being stored, so
+// the first keys can be issued. An empty token disables it.
+func SetBootstrapAPIKey(token string) {
+	bootstrapKeyHash = ""
+	if token != "" {
+		bootstrapKeyHash = hashAPIKey(token)
+	}
+}
+
+// IssueAPIKey creates an API key on behalf of actor from callerTenant, in that tenant unless
+// the request names another. The returned token is shown once; only its hash is stored.
+func IssueAPIKey(req request.IssueAPIKeyRequest, callerTenant, actor string) (domain.APIKey, string, error) {
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return domain.APIKey{}, "", err
+	}
+	tenant, err := managedTenant(callerTenant, req.Tenant)
+	if err != nil {
+		return domain.APIKey{}, "", err
+	}
+
+	id, err := randomToken(8, hex.EncodeToString)
+	if err != nil {
+		return domain.APIKey{}, "", err
+	}
+	secret, err := randomToken(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return domain.APIKey{}, "", err
+	}
+	token := APIKeyPrefix + secret
+
+	key := domain.APIKey{
+		ID:        id,
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    token[:len(APIKeyPrefix)+4],
+		Hash:      hashAPIKey(token),
+		Tenant:    tenant,
+		Scopes:    scopes,
+		CreatedAt: auditNow(),
+		CreatedBy: actor,
+	}
+	saveResult := <-APIKeyServiceInstance.SaveAPIKey(key).Observe()
+	if saveResult.E != nil {
+		return domain.APIKey{}, "", saveResult.E
+	}
+	return key, token, nil
+}
+
+// ListAPIKeys returns every API key of a tenant, including revoked ones. tenant defaults to
+// callerTenant.
+func ListAPIKeys(callerTenant, tenant string) ([]domain.APIKey, error) {
+	tenant, err := managedTenant(callerTenant, tenant)
+	if err != nil {
+		return nil, err
+	}
+	listResult := <-APIKeyServiceInstance.ListAPIKeys(tenant).Observe()
+	if listResult.E != nil {
+		return nil, listResult.E
+	}
+	return listResult.V.([]domain.APIKey), nil
+}
+
+// RevokeAPIKey permanently disables an API key that callerTenant manages and returns it
+func RevokeAPIKey(id, callerTenant string) (domain.APIKey, error) {
+	getResult := <-APIKeyServiceInstance.GetAPIKey(id).Observe()
+	if errors.Is(getResult.E, repository.ErrAPIKeyNotFound) {
+		return domain.APIKey{}, errAPIKeyNotFound
+	} else if getResult.E != nil {
+		return domain.APIKey{}, getResult.E
+	}
+	if _, err := managedTenant(callerTenant, getResult.V.(domain.APIKey).Tenant); err != nil {
+		// Keys of other tenants are hidden as if they did not exist
+		return domain.APIKey{}, errAPIKeyNotFound
+	}
+
+	revokeResult := <-APIKeyServiceInstance.RevokeAPIKey(id, auditNow()).Observe()
+	if errors.Is(revokeResult.E, repository.ErrAPIKeyNotFound) {
+		return domain.APIKey{}, errAPIKeyNotFound
+	} else if revokeResult.E != nil {
+		return domain.APIKey{}, revokeResult.E
+	}
+
+	getResult = <-APIKeyServiceInstance.GetAPIKey(id).Observe()
+	if getResult.E != nil {
+		return domain.APIKey{}, getResult.E
+	}
+	return getResult.V.(domain.APIKey), nil
+}
+
+// AuthenticateAPIKey returns the active API key matching token
+func AuthenticateAPIKey(token string) (domain.APIKey, error) {
+	hash := hashAPIKey(token)
+	if bootstrapKeyHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(bootstrapKeyHash)) == 1 {
+		return domain.APIKey{ID: BootstrapAPIKeyID, Name: BootstrapAPIKeyID, Tenant: domain.DefaultTenant, Scopes: []string{domain.ScopeAdmin}}, nil
+	}
+
+	findResult := <-APIKeyServiceInstance.FindAPIKeyByHash(hash).Observe()
+	if errors.Is(findResult.E, repository.ErrAPIKeyNotFound) {
+		return domain.APIKey{}, errInvalidAPIKey
+	} else if findResult.E != nil {
+		return domain.APIKey{}, findResult.E
+	}
+	key := findResult.V.(domain.APIKey)
+	if key.RevokedAt != nil && !key.RevokedAt.After(time.Now()) {
+		return domain.APIKey{}, errInvalidAPIKey
+	}
+	return key, nil
+}
+
+// managedTenant returns the tenant whose API keys or reports a caller from callerTenant asks
+// to manage, defaulting to its own. Only the default tenant manages other tenants.
+func managedTenant(callerTenant, tenant string) (string, error) {
+	if tenant == "" || tenant == callerTenant {
+		return callerTenant, nil
+	}
+	if callerTenant != domain.DefaultTenant {
+		return "", &models2.APIError{
+			Code:    http.StatusForbidden,
+			Message: "Only admins of the default tenant can manage other tenants",
+		}
+	}
+	if !domain.IsValidTenant(tenant) {
+		return "", &models2.APIError{
+			Code:    http.StatusBadRequest,
+			Message: "Tenant must be 1 to 32 lowercase letters, digits, '-' or '_'",
+		}
+	}
+	return tenant, nil
+}
+
+// normalizeScopes validates and deduplicates the requested scopes
+func normalizeScopes(scopes []string) ([]string, error) {
+	normalized := []string{}
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !domain.IsValidScope(scope) {
+			return nil, &models2.APIError{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("Unknown scope %q; use create, manage, read-stats, moderate or admin", scope),
+			}
+		}
+		if !slices.Contains(normalized, scope) {
+			normalized = append(normalized, scope)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, &models2.APIError{
+			Code:    http.StatusBadRequest,
+			Message: "At least one scope is required",
+		}
+	}
+	return normalized, nil
+}
+
+// hashAPIKey returns the hex SHA-256 of a token. Tokens carry 256 bits of randomness, so a
+// fast hash is enough and keeps the per-request lookup cheap.
+func hashAPIKey(token string) string {
+	digest := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(digest[:])
+}
+
+// randomToken encodes size random bytes
+func randomToken(size int, encode func([]byte) string) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return encode(raw), nil
+}
@@ -0,0 +1,22 @@
+package service
+
+import (
+	"time"
+	"urlshortener/internal/domain"
+)
+
+// SystemActor is recorded for changes the service makes on its own, such as disabling
+// a link that ran out of clicks
+const SystemActor = "system"
+
+// auditNow is the timestamp recorded on created and updated URLs. Millisecond precision
+// survives every store, so listing cursors compare exactly.
+func auditNow() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// touch records that actor is changing url now
+func touch(url *domain.URL, actor string) {
+	url.UpdatedAt = auditNow()
+	url.UpdatedBy = actor
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// BaseURL is the public scheme, host and optional path prefix of generated short links
+var BaseURL = "http://localhost:8080"
+
+// SetBaseURL validates and assigns the public base URL used for generated short links
+func SetBaseURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid base URL %q: %w", rawURL, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("base URL %q must use http or https", rawURL)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("base URL %q has no host", rawURL)
+	}
+	if parsed.RawQuery != "" || parsed.Fragment != "" {
+		return fmt.Errorf("base URL %q must not contain a query or fragment", rawURL)
+	}
+
+	BaseURL = strings.TrimRight(parsed.Scheme+"://"+parsed.Host+parsed.EscapedPath(), "/")
+	return nil
+}
+
+// buildShortURL returns the public link for a short ID under baseURL, or under
+// BaseURL when baseURL is empty
+func buildShortURL(baseURL, shortID string) string {
+	if baseURL == "" {
+		baseURL = BaseURL
+	}
+	return strings.TrimRight(baseURL, "/") + "/" + shortID
+}
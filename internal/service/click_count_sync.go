@@ -0,0 +1,75 @@
+package service
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"urlshortener/internal/cache"
+)
+
+// pendingClickCountsKey is the Redis set of links redirected since the last sync, as
+// members made by pendingClickCount
+const pendingClickCountsKey = "click_count:pending"
+
+// clickCountSyncBatch bounds how many links are taken from the set at once
+const clickCountSyncBatch = 500
+
+// pendingClickCount is the member of pendingClickCountsKey for the link shortID of tenant.
+// Tenant IDs never contain ':', so the first one separates both.
+func pendingClickCount(tenant, shortID string) string {
+	return tenant + ":" + shortID
+}
+
+// SyncClickCounts copies the Redis access counters of recently redirected URLs into the
+// repository, so listings can be sorted by click count without reading Redis per URL
+func SyncClickCounts() error {
+	var failed error
+	for {
+		members, err := cache.PopFromSet(pendingClickCountsKey, clickCountSyncBatch)
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			tenant, shortID, found := strings.Cut(member, ":")
+			if !found {
+				// Queued before counters were kept per tenant
+				continue
+			}
+			countResult := <-cache.GetURL(linkKey(tenant, shortID, ":access_count")).Observe()
+			if countResult.E != nil {
+				return countResult.E
+			}
+			count, err := strconv.ParseInt(countResult.V.(string), 10, 64)
+			if err != nil {
+				continue
+			}
+			updateResult := <-URLServiceInstance.UpdateClickCount(tenant, shortID, count).Observe()
+			if updateResult.E != nil {
+				// Queue the link again so the next run retries it
+				_ = cache.AddToSet(pendingClickCountsKey, member)
+				failed = updateResult.E
+			}
+		}
+		if len(members) < clickCountSyncBatch || failed != nil {
+			return failed
+		}
+	}
+}
+
+// StartClickCountSync periodically runs SyncClickCounts
+func StartClickCountSync(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := SyncClickCounts(); err != nil {
+				log.Printf("Failed to sync click counts: %v", err)
+			}
+		}
+	}()
+}
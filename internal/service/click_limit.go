@@ -0,0 +1,76 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"urlshortener/internal/cache"
+	"urlshortener/internal/domain"
+	models2 "urlshortener/internal/models"
+)
+
+// clicksUsedKey is the Redis counter enforcing max_clicks of a URL. It is kept apart from
+// the access_count statistic so that the limit does not depend on how stats are recorded.
+func clicksUsedKey(url domain.URL) string {
+	return linkKey(url.Tenant, url.ID, ":clicks_used")
+}
+
+// errClickLimitReached is returned once a limited link has no clicks left
+var errClickLimitReached = &models2.APIError{
+	Code:    http.StatusGone,
+	Message: "URL has reached its click limit",
+}
+
+// validateMaxClicks rejects negative click limits
+func validateMaxClicks(maxClicks int64) error {
+	if maxClicks < 0 {
+		return &models2.APIError{
+			Code:    http.StatusBadRequest,
+			Message: "max_clicks must be a positive number",
+		}
+	}
+	return nil
+}
+
+// claimClick consumes one click of a limited link, shared by every replica through Redis.
+// The link is disabled in the database as soon as its last click is used.
+func claimClick(url domain.URL) error {
+	used, ok, err := cache.ClaimClick(clicksUsedKey(url), url.MaxClicks)
+	if err != nil {
+		// Fail closed: without Redis the limit cannot be enforced
+		return &models2.APIError{
+			Code:    http.StatusServiceUnavailable,
+			Message: "Could not verify the click limit",
+		}
+	}
+	if used >= url.MaxClicks {
+		disableExhaustedURL(url)
+	}
+	if !ok {
+		return errClickLimitReached
+	}
+	return nil
+}
+
+// clicksExhausted reports whether every click of a limited link has been used
+func clicksExhausted(url domain.URL) bool {
+	result := <-cache.GetURL(clicksUsedKey(url)).Observe()
+	if result.E != nil || result.V.(string) == "" {
+		return false
+	}
+	used, err := strconv.ParseInt(result.V.(string), 10, 64)
+	return err == nil && used >= url.MaxClicks
+}
+
+// disableExhaustedURL persists the disabled state of a link that used its last click
+func disableExhaustedURL(url domain.URL) {
+	if !url.Enabled {
+		return
+	}
+	url.Enabled = false
+	touch(&url, SystemActor)
+	updateResult := <-URLServiceInstance.UpdateURL(url).Observe()
+	if updateResult.E != nil {
+		fmt.Printf("Error disabling exhausted URL %s: %v\n", url.ID, updateResult.E)
+	}
+}
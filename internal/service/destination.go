@@ -0,0 +1,133 @@
+package service
+
+import (
+	"fmt"
+	"golang.org/x/net/idna"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	models2 "urlshortener/internal/models"
+)
+
+// DestinationPolicy decides which destinations can be shortened and how they are
+// canonicalized so equivalent URLs map to one link
+type DestinationPolicy struct {
+	AllowedSchemes []string // Lowercase schemes a destination may use
+	MaxLength      int      // Maximum length of the canonical URL
+	SortQuery      bool     // Sort query parameters by name
+	StripFragment  bool     // Drop the #fragment
+}
+
+// DefaultDestinationPolicy accepts http and https URLs of up to 2048 characters
+var DefaultDestinationPolicy = DestinationPolicy{
+	AllowedSchemes: []string{"http", "https"},
+	MaxLength:      2048,
+}
+
+// destinationPolicy is the policy applied by normalizeDestination
+var destinationPolicy = DefaultDestinationPolicy
+
+// defaultPorts are dropped from destinations, since they are implied by the scheme
+var defaultPorts = map[string]string{"http": "80", "https": "443", "ftp": "21", "ws": "80", "wss": "443"}
+
+// hostProfile converts international host names to punycode. Underscores are allowed
+// because they appear in real host names even though DNS rules forbid them.
+var hostProfile = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.StrictDomainName(false))
+
+// SetDestinationPolicy configures the destinations accepted from now on
+func SetDestinationPolicy(policy DestinationPolicy) error {
+	if len(policy.AllowedSchemes) == 0 {
+		return fmt.Errorf("at least one destination scheme must be allowed")
+	}
+	schemes := make([]string, 0, len(policy.AllowedSchemes))
+	for _, scheme := range policy.AllowedSchemes {
+		scheme = strings.ToLower(strings.TrimSpace(scheme))
+		if scheme == "" || strings.ContainsAny(scheme, ":/") {
+			return fmt.Errorf("invalid destination scheme %q", scheme)
+		}
+		schemes = append(schemes, scheme)
+	}
+	if policy.MaxLength <= 0 {
+		return fmt.Errorf("maximum destination length must be positive")
+	}
+	policy.AllowedSchemes = schemes
+	destinationPolicy = policy
+	return nil
+}
+
+// invalidDestination builds the 400 error returned for a rejected destination
+func invalidDestination(format string, args ...interface{}) error {
+	return &models2.APIError{
+		Code:    http.StatusBadRequest,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// normalizeDestination validates a destination against the policy and returns its
+// canonical form: lowercase scheme and punycode host, no default port and, when
+// configured, sorted query parameters and no fragment
+func normalizeDestination(rawURL string) (string, error) {
+	policy := destinationPolicy
+	rawURL = strings.TrimSpace(rawURL)
+	// Checked up front too so huge inputs are not parsed at all
+	if len(rawURL) > policy.MaxLength {
+		return "", invalidDestination("original_url cannot be longer than %d characters", policy.MaxLength)
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme == "" {
+		return "", invalidDestination("original_url must be an absolute URL")
+	}
+	if !slices.Contains(policy.AllowedSchemes, parsed.Scheme) {
+		return "", invalidDestination("original_url must use one of the schemes %s", strings.Join(policy.AllowedSchemes, ", "))
+	}
+	if parsed.Opaque != "" || parsed.Hostname() == "" {
+		return "", invalidDestination("original_url must include a host")
+	}
+
+	host, err := canonicalHost(parsed.Hostname())
+	if err != nil {
+		return "", invalidDestination("original_url has an invalid host")
+	}
+	if port := parsed.Port(); port != "" && port != defaultPorts[parsed.Scheme] {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	parsed.Host = host
+
+	if policy.SortQuery && parsed.RawQuery != "" {
+		// Malformed queries are kept as they are rather than silently losing parameters
+		if values, err := url.ParseQuery(parsed.RawQuery); err == nil {
+			parsed.RawQuery = values.Encode()
+		}
+	}
+	if policy.StripFragment {
+		parsed.Fragment, parsed.RawFragment = "", ""
+	}
+
+	canonical := parsed.String()
+	if len(canonical) > policy.MaxLength {
+		return "", invalidDestination("original_url cannot be longer than %d characters", policy.MaxLength)
+	}
+	return canonical, nil
+}
+
+// canonicalHost lowercases a host and converts international names to punycode
+func canonicalHost(host string) (string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+	host, err := hostProfile.ToASCII(strings.TrimSuffix(host, "."))
+	if err != nil {
+		return "", err
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" {
+			return "", fmt.Errorf("empty label in host %q", host)
+		}
+	}
+	return host, nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"log"
+	"net/http"
+	"time"
+	"urlshortener/internal/cache"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/interfaces"
+	models2 "urlshortener/internal/models"
+)
+
+// resolveExpiry turns an optional expires_at or ttl (in seconds) into an absolute expiration time
+func resolveExpiry(expiresAt *time.Time, ttl int64, now time.Time) (*time.Time, error) {
+	switch {
+	case expiresAt != nil && ttl != 0:
+		return nil, &models2.APIError{
+			Code:    http.StatusBadRequest,
+			Message: "Use either expires_at or ttl, not both",
+		}
+	case ttl < 0:
+		return nil, &models2.APIError{
+			Code:    http.StatusBadRequest,
+			Message: "ttl must be a positive number of seconds",
+		}
+	case ttl > 0:
+		expiry := now.Add(time.Duration(ttl) * time.Second).UTC()
+		return &expiry, nil
+	case expiresAt != nil:
+		if !expiresAt.After(now) {
+			return nil, &models2.APIError{
+				Code:    http.StatusBadRequest,
+				Message: "expires_at must be in the future",
+			}
+		}
+		expiry := expiresAt.UTC()
+		return &expiry, nil
+	}
+	return nil, nil
+}
+
+// cacheTTL caps the cache lifetime of a URL at the remaining lifetime of the link.
+// A zero result means the link must not be cached.
+func cacheTTL(url domain.URL, now time.Time) time.Duration {
+	if url.MaxClicks > 0 {
+		// Every hit of a limited link must reach claimClick, so it never comes from cache
+		return 0
+	}
+	if url.DeletedAt != nil {
+		// Trashed links never redirect
+		return 0
+	}
+	if url.Warning != "" {
+		// The cache only holds destinations, so warned links are read from storage
+		return 0
+	}
+	if url.HasPassword() {
+		// Passwords are checked on every redirect
+		return 0
+	}
+	if url.ExpiresAt == nil {
+		return cache.DefaultURLTTL
+	}
+	remaining := url.ExpiresAt.Sub(now)
+	if remaining <= 0 {
+		return 0
+	}
+	if remaining < cache.DefaultURLTTL {
+		return remaining
+	}
+	return cache.DefaultURLTTL
+}
+
+// cacheURL stores an enabled URL in Redis for at most its remaining lifetime, together
+// with the route to its tenant
+func cacheURL(url domain.URL) error {
+	ttl := cacheTTL(url, time.Now())
+	if ttl == 0 {
+		return nil
+	}
+	cacheResult := <-cache.SetURL(linkKey(url.Tenant, url.ID, ""), url.OriginalURL, ttl).Observe()
+	if cacheResult.E != nil {
+		return cacheResult.E
+	}
+	routeResult := <-cache.SetURL(routeKey(url.ID), url.Tenant, ttl).Observe()
+	return routeResult.E
+}
+
+// SweepExpiredURLs removes expired URLs from repositories that need it, together with their
+// Redis keys, and returns how many were removed. MongoDB relies on its TTL index instead;
+// the keys a link leaves behind there expire with the cache or are cleared when a new URL
+// takes its ID.
+func SweepExpiredURLs() (int, error) {
+	sweeper, ok := URLServiceInstance.(interfaces.URLSweeper)
+	if !ok {
+		return 0, nil
+	}
+	result := <-sweeper.DeleteExpiredURLs(time.Now()).Observe()
+	if result.E != nil {
+		return 0, result.E
+	}
+	deleted := result.V.([]domain.URL)
+	for _, url := range deleted {
+		if err := cache.DeleteKeys(urlCacheKeys(url.Tenant, url.ID)...); err != nil {
+			log.Printf("Failed to clear the Redis keys of expired URL %s: %v", url.ID, err)
+		}
+	}
+	return len(deleted), nil
+}
+
+// StartExpirySweeper periodically runs SweepExpiredURLs. Nothing is started for MongoDB,
+// which removes expired URLs on its own. It must be called once URLServiceInstance is set.
+func StartExpirySweeper(interval time.Duration) {
+	if interval <= 0 {
+		log.Println("Expiry sweeper disabled; expired URLs stay stored until deleted")
+		return
+	}
+	if _, ok := URLServiceInstance.(interfaces.URLSweeper); !ok {
+		log.Printf("Expiry sweeper not started: %T removes expired URLs on its own", URLServiceInstance)
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if deleted, err := SweepExpiredURLs(); err != nil {
+				log.Printf("Failed to delete expired URLs: %v", err)
+			} else if deleted > 0 {
+				log.Printf("Deleted %d expired URLs", deleted)
+			}
+		}
+	}()
+}
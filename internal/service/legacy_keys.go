@@ -0,0 +1,69 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"urlshortener/internal/cache"
+)
+
+// legacyKeysMigratedKey marks that MigrateLegacyLinkKeys has moved every legacy key
+const legacyKeysMigratedKey = "link_keys:migrated"
+
+// legacyLinkKeySuffixes are the per-link state kept as "<id>:<suffix>" before the keys of a
+// link were namespaced per tenant. Cached destinations and password failures are left to
+// expire.
+var legacyLinkKeySuffixes = []string{"access_count", "last_access", "clicks_used", "interstitial_views", "interstitial_continues"}
+
+// MigrateLegacyLinkKeys moves the statistics and click counters stored before keys were
+// namespaced per tenant into the namespace of the tenant of their link, and deletes the
+// ones of links that no longer exist. It runs once per Redis database; keys already
+// present in the namespace win over legacy ones.
+func MigrateLegacyLinkKeys() error {
+	migratedResult := <-cache.GetURL(legacyKeysMigratedKey).Observe()
+	if migratedResult.E != nil {
+		return migratedResult.E
+	} else if migratedResult.V.(string) != "" {
+		return nil
+	}
+
+	moved := 0
+	for _, suffix := range legacyLinkKeySuffixes {
+		err := cache.ScanKeys("*:"+suffix, func(key string) error {
+			shortID, rest, _ := strings.Cut(key, ":")
+			if rest != suffix {
+				// Already namespaced, or a key of something else
+				return nil
+			}
+			tenant, err := getURLTenant(shortID)
+			if errors.Is(err, errURLNotFound) {
+				return cache.DeleteKeys(key)
+			} else if err != nil {
+				return err
+			}
+			ok, err := cache.RenameKey(key, linkKey(tenant, shortID, ":"+suffix))
+			if err != nil {
+				return err
+			} else if !ok {
+				return cache.DeleteKeys(key)
+			}
+			moved++
+			if suffix == "access_count" {
+				// Let the next sync copy the counter under its new key
+				return cache.AddToSet(pendingClickCountsKey, pendingClickCount(tenant, shortID))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if result := <-cache.SetURL(legacyKeysMigratedKey, "1", 0).Observe(); result.E != nil {
+		return result.E
+	}
+	if moved > 0 {
+		log.Printf("Moved %d legacy link keys into their tenant namespace", moved)
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package service
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/interfaces"
+	models2 "urlshortener/internal/models"
+	"urlshortener/internal/repository"
+	"urlshortener/internal/request"
+	"urlshortener/internal/safety"
+)
+
+// Actions a moderator can take on a reported link
+const (
+	ModerationDismiss   = "dismiss"
+	ModerationWarn      = "warn"
+	ModerationUnwarn    = "unwarn"
+	ModerationDisable   = "disable"
+	ModerationEnable    = "enable"
+	ModerationBanDomain = "ban_domain"
+)
+
+// QuarantineModerator is the quarantine reason of links disabled by a moderator, which only
+// a moderator can enable again
+const QuarantineModerator = "disabled by a moderator"
+
+// errModeratorLock is returned when an owner tries to enable a link disabled by a moderator
+var errModeratorLock = &models2.APIError{
+	Code:    http.StatusForbidden,
+	Message: "Only a moderator can enable this link",
+}
+
+// maxReportDetails bounds the free text of a report
+const maxReportDetails = 1000
+
+// maxReportedURLs bounds one page of the moderation queue
+const maxReportedURLs = 500
+
+// ReportServiceInstance ReportServiceInterface is the injected report repository
+var ReportServiceInstance interfaces.ReportServiceInterface
+
+// bannedDomains holds the banned domains checked on every shorten and redirect, so the
+// store is not queried each time. Other replicas pick up bans on StartBannedDomainSync.
+var bannedDomains struct {
+	sync.RWMutex
+	domains []string
+}
+
+// ModerationResult describes the outcome of a moderator action
+type ModerationResult struct {
+	ShortID       string               `json:"short_id"`
+	Action        string               `json:"action"`
+	ClosedReports int64                `json:"closed_reports"` // Open reports closed by the action
+	BannedDomain  *domain.BannedDomain `json:"banned_domain,omitempty"`
+}
+
+// ReportURL files an abuse report against the live link shortID, whatever its tenant, on
+// behalf of the client at ip with the given User-Agent
+func ReportURL(shortID string, req request.ReportRequest, ip, agent string) (domain.Report, error) {
+	reason := strings.ToLower(strings.TrimSpace(req.Reason))
+	if !domain.IsValidReportReason(reason) {
+		return domain.Report{}, &models2.APIError{
+			Code:    http.StatusBadRequest,
+			Message: "Reason must be phishing, malware, spam or other",
+		}
+	}
+	details := strings.TrimSpace(req.Details)
+	if len(details) > maxReportDetails {
+		return domain.Report{}, &models2.APIError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Details cannot be longer than %d characters", maxReportDetails),
+		}
+	}
+	email := strings.TrimSpace(req.Email)
+	if email != "" {
+		if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+			return domain.Report{}, &models2.APIError{
+				Code:    http.StatusBadRequest,
+				Message: "Email must be a plain email address",
+			}
+		}
+	}
+
+	url, err := getPublicURL(shortID)
+	if err != nil {
+		return domain.Report{}, err
+	}
+
+	id, err := randomToken(8, hex.EncodeToString)
+	if err != nil {
+		return domain.Report{}, err
+	}
+	report := domain.Report{
+		ID:            id,
+		ShortID:       url.ID,
+		Tenant:        url.Tenant,
+		Reason:        reason,
+		Details:       details,
+		ReporterIP:    ip,
+		ReporterAgent: agent,
+		ReporterEmail: email,
+		CreatedAt:     auditNow(),
+		Status:        domain.ReportOpen,
+	}
+	saveResult := <-ReportServiceInstance.SaveReport(report).Observe()
+	if saveResult.E != nil {
+		return domain.Report{}, saveResult.E
+	}
+	return report, nil
+}
+
+// ListReportedURLs returns the moderation queue of a tenant: its links with open reports,
+// most reported first, with the links themselves when they still exist. tenant defaults
+// to callerTenant.
+func ListReportedURLs(callerTenant, tenant string, limit int) ([]domain.ReportedURL, error) {
+	tenant, err := managedTenant(callerTenant, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxReportedURLs {
+		limit = maxReportedURLs
+	}
+
+	listResult := <-ReportServiceInstance.ListReportedURLs(tenant, limit).Observe()
+	if listResult.E != nil {
+		return nil, listResult.E
+	}
+	reportedURLs := listResult.V.([]domain.ReportedURL)
+	for i := range reportedURLs {
+		if url, err := getStoredURL(tenant, reportedURLs[i].ShortID); err == nil {
+			reportedURLs[i].URL = &url
+		}
+	}
+	return reportedURLs, nil
+}
+
+// ModerateURL takes action on the reports of the link shortID on behalf of actor from
+// callerTenant and closes its open reports. Warning puts a warning page in front of the
+// link that only moderators can lift. Disabling goes through SetURLState, like the state
+// endpoint, so caches are invalidated. Banning the destination domain also disables
+// the link, and quarantines other links to the domain as they are visited; only the default
+// tenant bans domains, since bans apply to every tenant.
+func ModerateURL(shortID, action, callerTenant, actor string) (ModerationResult, error) {
+	tenant, err := getURLTenant(shortID)
+	if err != nil {
+		return ModerationResult{}, err
+	}
+	if _, err := managedTenant(callerTenant, tenant); err != nil {
+		// Links of other tenants are hidden as if they did not exist
+		return ModerationResult{}, errURLNotFound
+	}
+	url, err := getStoredURL(tenant, shortID)
+	if err != nil {
+		return ModerationResult{}, err
+	}
+
+	result := ModerationResult{ShortID: url.ID, Action: action}
+	status := ""
+	switch action {
+	case ModerationDismiss:
+		status = domain.ReportDismissed
+	case ModerationWarn:
+		status = domain.ReportWarned
+		if err := setURLWarning(url.Tenant, url.ID, WarningModerator, actor); err != nil {
+			return ModerationResult{}, err
+		}
+	case ModerationUnwarn:
+		// Lifting a warning clears the link, so its reports are dismissed
+		status = domain.ReportDismissed
+		if err := setURLWarning(url.Tenant, url.ID, "", actor); err != nil {
+			return ModerationResult{}, err
+		}
+	case ModerationDisable:
+		status = domain.ReportDisabled
+		if _, err := setURLState(url.Tenant, url.ID, false, actor, true); err != nil {
+			return ModerationResult{}, err
+		}
+	case ModerationEnable:
+		// Enabling the link again clears it, so its reports are dismissed
+		status = domain.ReportDismissed
+		if _, err := setURLState(url.Tenant, url.ID, true, actor, true); err != nil {
+			return ModerationResult{}, err
+		}
+	case ModerationBanDomain:
+		status = domain.ReportDomainBanned
+		if callerTenant != domain.DefaultTenant {
+			return ModerationResult{}, &models2.APIError{
+				Code:    http.StatusForbidden,
+				Message: "Only moderators of the default tenant can ban domains",
+			}
+		}
+		ban, err := banDestinationDomain(url.OriginalURL, actor)
+		if err != nil {
+			return ModerationResult{}, err
+		}
+		result.BannedDomain = &ban
+		if _, err := setURLState(url.Tenant, url.ID, false, actor, true); err != nil {
+			return ModerationResult{}, err
+		}
+	default:
+		return ModerationResult{}, &models2.APIError{
+			Code:    http.StatusBadRequest,
+			Message: "Action must be dismiss, warn, unwarn, disable, enable or ban_domain",
+		}
+	}
+
+	closeResult := <-ReportServiceInstance.CloseReports(url.ID, status, actor, auditNow()).Observe()
+	if closeResult.E != nil {
+		return ModerationResult{}, closeResult.E
+	}
+	result.ClosedReports = closeResult.V.(int64)
+	return result, nil
+}
+
+// banDestinationDomain bans the host of a destination and makes the ban effective at once
+func banDestinationDomain(originalURL, actor string) (domain.BannedDomain, error) {
+	parsed, err := url.Parse(originalURL)
+	if err != nil || parsed.Hostname() == "" {
+		return domain.BannedDomain{}, &models2.APIError{
+			Code:    http.StatusUnprocessableEntity,
+			Message: "Destination has no domain to ban",
+		}
+	}
+	ban := domain.BannedDomain{
+		Domain:   strings.TrimSuffix(strings.ToLower(parsed.Hostname()), "."),
+		BannedAt: auditNow(),
+		BannedBy: actor,
+	}
+	saveResult := <-ReportServiceInstance.SaveBannedDomain(ban).Observe()
+	if saveResult.E != nil {
+		return domain.BannedDomain{}, saveResult.E
+	}
+	ban = saveResult.V.(domain.BannedDomain)
+
+	bannedDomains.Lock()
+	if !slices.Contains(bannedDomains.domains, ban.Domain) {
+		bannedDomains.domains = append(bannedDomains.domains, ban.Domain)
+	}
+	bannedDomains.Unlock()
+	return ban, nil
+}
+
+// ListBannedDomains returns every banned domain
+func ListBannedDomains() ([]domain.BannedDomain, error) {
+	listResult := <-ReportServiceInstance.ListBannedDomains().Observe()
+	if listResult.E != nil {
+		return nil, listResult.E
+	}
+	return listResult.V.([]domain.BannedDomain), nil
+}
+
+// UnbanDomain lifts the ban on a domain on behalf of a caller from callerTenant. Links
+// quarantined meanwhile stay disabled until their owners enable them again.
+func UnbanDomain(domainName, callerTenant string) error {
+	if callerTenant != domain.DefaultTenant {
+		return &models2.APIError{
+			Code:    http.StatusForbidden,
+			Message: "Only moderators of the default tenant can lift domain bans",
+		}
+	}
+	domainName = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domainName)), ".")
+	deleteResult := <-ReportServiceInstance.DeleteBannedDomain(domainName).Observe()
+	if errors.Is(deleteResult.E, repository.ErrBannedDomainNotFound) {
+		return &models2.APIError{
+			Code:    http.StatusNotFound,
+			Message: "Domain is not banned",
+		}
+	} else if deleteResult.E != nil {
+		return deleteResult.E
+	}
+
+	bannedDomains.Lock()
+	// A new slice, since checks may still be reading the current one
+	remaining := make([]string, 0, len(bannedDomains.domains))
+	for _, entry := range bannedDomains.domains {
+		if entry != domainName {
+			remaining = append(remaining, entry)
+		}
+	}
+	bannedDomains.domains = remaining
+	bannedDomains.Unlock()
+	return nil
+}
+
+// LoadBannedDomains replaces the banned domains checked in memory by the stored ones
+func LoadBannedDomains() error {
+	bans, err := ListBannedDomains()
+	if err != nil {
+		return err
+	}
+	domains := make([]string, 0, len(bans))
+	for _, ban := range bans {
+		domains = append(domains, ban.Domain)
+	}
+
+	bannedDomains.Lock()
+	bannedDomains.domains = domains
+	bannedDomains.Unlock()
+	return nil
+}
+
+// StartBannedDomainSync reloads the banned domains every interval, so bans and lifted bans
+// made through other replicas take effect here too
+func StartBannedDomainSync(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := LoadBannedDomains(); err != nil {
+				log.Printf("Error reloading banned domains: %v", err)
+			}
+		}
+	}()
+}
+
+// bannedDomain returns the banned domain that the host of rawURL equals or is a subdomain of
+func bannedDomain(rawURL string) (string, bool) {
+	bannedDomains.RLock()
+	domains := bannedDomains.domains
+	bannedDomains.RUnlock()
+	if len(domains) == 0 {
+		return "", false
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	return safety.MatchDomain(strings.TrimSuffix(strings.ToLower(parsed.Hostname()), "."), domains)
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	models2 "urlshortener/internal/models"
+)
+
+// errNotOwner is returned when a caller acts on a link that belongs to someone else
+var errNotOwner = &models2.APIError{
+	Code:    http.StatusForbidden,
+	Message: "Only the owner of the URL or an admin can do this",
+}
+
+// AuthorizeURL checks that actor from tenant owns the URL shortID, trashed or not. Admins
+// may act on every URL of their tenant; links without an owner, stored before ownership was
+// recorded, are admin only. URLs of other tenants are reported as not found, and missing
+// URLs pass so the operation itself reports them.
+func AuthorizeURL(shortID, tenant, actor string, admin bool) error {
+	url, err := getStoredURL(tenant, shortID)
+	if errors.Is(err, errURLNotFound) {
+		// Statistics live in the tenant's namespace and exist for any ID, so links of
+		// other tenants must be refused here
+		if _, err = getURLTenant(shortID); errors.Is(err, errURLNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return errURLNotFound
+	} else if err != nil {
+		return err
+	}
+	if admin {
+		return nil
+	}
+	if url.Owner == "" || url.Owner != actor {
+		return errNotOwner
+	}
+	return nil
+}
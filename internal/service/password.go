@@ -0,0 +1,150 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"log"
+	"net/http"
+	"time"
+	"urlshortener/internal/cache"
+	"urlshortener/internal/domain"
+	models2 "urlshortener/internal/models"
+)
+
+// Length bounds of link passwords; bcrypt ignores anything past 72 bytes
+const (
+	minPasswordLength = 6
+	maxPasswordLength = 72
+)
+
+// PasswordThrottle bounds the wrong passwords accepted for a link and from an IP address.
+// Once either is reached, attempts are refused until the period passes without failures.
+type PasswordThrottle struct {
+	PerLink models2.RateLimit
+	PerIP   models2.RateLimit
+}
+
+// passwordThrottle is the throttle applied to password attempts
+var passwordThrottle = PasswordThrottle{
+	PerLink: models2.RateLimit{Limit: 20, Period: 15 * time.Minute},
+	PerIP:   models2.RateLimit{Limit: 10, Period: 15 * time.Minute},
+}
+
+// SetPasswordThrottle configures how many wrong passwords are accepted before attempts are refused
+func SetPasswordThrottle(throttle PasswordThrottle) {
+	passwordThrottle = throttle
+}
+
+// ErrPasswordRequired is returned when redirecting to a password-protected link without a password
+var ErrPasswordRequired = &models2.APIError{
+	Code:    http.StatusUnauthorized,
+	Message: "This link is protected by a password",
+}
+
+// ErrWrongPassword is returned when redirecting to a password-protected link with a wrong password
+var ErrWrongPassword = &models2.APIError{
+	Code:    http.StatusUnauthorized,
+	Message: "Wrong password",
+}
+
+// RedirectAccess is what a client brings when following a link
+type RedirectAccess struct {
+	Confirmed bool   // The client went through the warning page, if any
+	Password  string // Password given for protected links
+	ClientIP  string // Address of the client, to throttle wrong passwords; empty when unknown
+}
+
+// PasswordError is returned when a protected link cannot be followed with the password
+// given. Err is ErrPasswordRequired, ErrWrongPassword or a *ThrottledError.
+type PasswordError struct {
+	Err     error
+	Warning string // Why a warning page would be shown before following the link, if at all
+}
+
+func (e *PasswordError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap exposes the cause so handlers can tell the cases apart
+func (e *PasswordError) Unwrap() error {
+	return e.Err
+}
+
+// ThrottledError is returned while password attempts are refused
+type ThrottledError struct {
+	*models2.APIError
+	RetryAfter time.Duration // How long until attempts are accepted again
+}
+
+// Unwrap exposes the APIError so handlers report it like any other
+func (e *ThrottledError) Unwrap() error {
+	return e.APIError
+}
+
+// hashPassword validates a link password and returns its salted bcrypt hash
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return "", &models2.APIError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("password must be %d to %d bytes long", minPasswordLength, maxPasswordLength),
+		}
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkPassword verifies the password given for a protected link from clientIP, refusing
+// attempts while the link or the address has too many recent failures. clientIP must not
+// come from headers of untrusted proxies, or clients could pick a fresh one for every guess.
+// When it is empty, as when only the address of a proxy is known, only the link is
+// throttled. Without Redis attempts are not throttled.
+func checkPassword(url domain.URL, password, clientIP string) error {
+	if password == "" {
+		return ErrPasswordRequired
+	}
+
+	keys := map[string]models2.RateLimit{
+		linkKey(url.Tenant, url.ID, ":password_failures"): passwordThrottle.PerLink,
+	}
+	if clientIP != "" {
+		keys["password_failures:ip:"+clientIP] = passwordThrottle.PerIP
+	}
+	for key, limit := range keys {
+		if !limit.Enabled() {
+			continue
+		}
+		failures, ttl, err := cache.GetCounter(key)
+		if err != nil {
+			log.Printf("Skipping password throttle %s: %v", key, err)
+			continue
+		}
+		if failures >= limit.Limit {
+			return &ThrottledError{
+				APIError: &models2.APIError{
+					Code:    http.StatusTooManyRequests,
+					Message: "Too many wrong passwords; retry later",
+				},
+				RetryAfter: ttl,
+			}
+		}
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(url.PasswordHash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		// Every failure extends the window, so steady guessing stays throttled
+		for key, limit := range keys {
+			if !limit.Enabled() {
+				continue
+			}
+			if _, err := cache.IncrementWithTTL(key, limit.Period); err != nil {
+				log.Printf("Failed to count wrong password %s: %v", key, err)
+			}
+		}
+		return ErrWrongPassword
+	}
+	return err
+}
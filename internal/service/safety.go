@@ -0,0 +1,73 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/interfaces"
+	models2 "urlshortener/internal/models"
+)
+
+// DestinationCheckerInstance rejects unsafe destinations; nil accepts every destination
+var DestinationCheckerInstance interfaces.DestinationChecker
+
+// destinationBlocked reports whether rawURL is on a banned domain or, when there is a
+// checker, whether the checker considers it unsafe, and why
+func destinationBlocked(rawURL string) (string, bool) {
+	if entry, banned := bannedDomain(rawURL); banned {
+		return fmt.Sprintf("destination domain %s is banned", entry), true
+	}
+	if DestinationCheckerInstance == nil {
+		return "", false
+	}
+	return DestinationCheckerInstance.Check(rawURL)
+}
+
+// checkDestination rejects destinations the checker considers unsafe with 422 and the reason
+func checkDestination(rawURL string) error {
+	if reason, blocked := destinationBlocked(rawURL); blocked {
+		return &models2.APIError{
+			Code:    http.StatusUnprocessableEntity,
+			Message: "Destination rejected: " + reason,
+		}
+	}
+	return nil
+}
+
+// quarantinedError is returned when redirecting to a quarantined link
+func quarantinedError(reason string) error {
+	return &models2.APIError{
+		Code:    http.StatusForbidden,
+		Message: "URL has been blocked: " + reason,
+	}
+}
+
+// quarantineURL disables a link whose destination became unsafe, recording why, and
+// evicts it from the cache. Owners can only enable it again once the destination passes
+// the checks.
+func quarantineURL(url domain.URL, reason string) error {
+	url.Enabled = false
+	url.Quarantine = reason
+	touch(&url, SystemActor)
+	updateResult := <-URLServiceInstance.UpdateURL(url).Observe()
+	if updateResult.E != nil {
+		fmt.Printf("Error quarantining URL %s: %v\n", url.ID, updateResult.E)
+	} else if err := refreshCachedURL(url); err != nil {
+		fmt.Printf("Error evicting quarantined URL %s from Redis: %v\n", url.ID, err)
+	}
+	return quarantinedError(reason)
+}
+
+// screenRedirect quarantines the link shortID of tenant when its destination no longer
+// passes the safety checks
+func screenRedirect(tenant, shortID, originalURL string) error {
+	reason, blocked := destinationBlocked(originalURL)
+	if !blocked {
+		return nil
+	}
+	url, err := getStoredURL(tenant, shortID)
+	if err != nil {
+		return quarantinedError(reason)
+	}
+	return quarantineURL(url, reason)
+}
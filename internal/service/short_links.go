@@ -0,0 +1,171 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	models2 "urlshortener/internal/models"
+	"urlshortener/internal/safety"
+)
+
+// maxShortLinkHops bounds how many of our own links are followed to find a final destination
+const maxShortLinkHops = 5
+
+// ShortLinkPolicy decides what happens to destinations that are short links themselves
+type ShortLinkPolicy struct {
+	Domains          []string // Hosts serving our links besides the one of BaseURL, e.g. sho.rt or sho.rt:8443
+	ResolveOwnLinks  bool     // Replace links to our own links by their final destination instead of rejecting them
+	RejectShorteners bool     // Reject links of known third-party shorteners, see KnownShorteners
+}
+
+// KnownShorteners are third-party shortener domains, rejected when RejectShorteners is set
+var KnownShorteners = []string{
+	"bit.ly", "bitly.com", "j.mp", "tinyurl.com", "t.co", "goo.gl", "ow.ly", "is.gd", "v.gd", "buff.ly",
+	"rebrand.ly", "cutt.ly", "shorturl.at", "tiny.cc", "bl.ink", "t.ly", "rb.gy", "s.id", "lnkd.in",
+	"tr.im", "short.io", "soo.gd", "x.co", "qr.ae", "adf.ly", "shorte.st", "clck.ru", "urlz.fr",
+}
+
+// shortLinkPolicy is the policy applied by resolveShortLink; own links are resolved by default
+var shortLinkPolicy = ShortLinkPolicy{ResolveOwnLinks: true}
+
+// SetShortLinkPolicy configures how destinations pointing at short links are handled
+func SetShortLinkPolicy(policy ShortLinkPolicy) error {
+	domains := make([]string, 0, len(policy.Domains))
+	for _, entry := range policy.Domains {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, err := canonicalHostPort(entry)
+		if err != nil {
+			return fmt.Errorf("invalid short domain %q: %w", entry, err)
+		}
+		domains = append(domains, host)
+	}
+	policy.Domains = domains
+	shortLinkPolicy = policy
+	return nil
+}
+
+// canonicalHostPort canonicalizes a host with an optional port like normalizeDestination does
+func canonicalHostPort(hostPort string) (string, error) {
+	parsed, err := url.Parse("http://" + hostPort)
+	if err != nil || parsed.Host != hostPort || parsed.Hostname() == "" {
+		return "", fmt.Errorf("%q is not a host with an optional port", hostPort)
+	}
+	host, port := parsed.Hostname(), parsed.Port()
+	host, err = canonicalHost(host)
+	if err != nil {
+		return "", err
+	}
+	if port != "" {
+		return net.JoinHostPort(host, port), nil
+	}
+	if strings.Contains(host, ":") {
+		return "[" + host + "]", nil
+	}
+	return host, nil
+}
+
+// rejectedShortLink builds the 422 error returned for destinations that are short links
+func rejectedShortLink(message string) error {
+	return &models2.APIError{
+		Code:    http.StatusUnprocessableEntity,
+		Message: message,
+	}
+}
+
+// resolveShortLink replaces a canonical destination pointing at one of our own links by
+// the destination of that link, following chains left by older links, or rejects it,
+// depending on the policy. selfID is the link being created or updated, if known, so it
+// cannot end up pointing at itself. baseURL is the base of the link being created, or
+// empty for BaseURL.
+func resolveShortLink(originalURL, selfID, baseURL string) (string, error) {
+	policy := shortLinkPolicy
+	visited := map[string]bool{}
+	if selfID != "" {
+		visited[selfID] = true
+	}
+
+	for hop := 0; ; hop++ {
+		parsed, err := url.Parse(originalURL)
+		if err != nil {
+			return originalURL, nil
+		}
+		if policy.RejectShorteners {
+			if entry, found := safety.MatchDomain(parsed.Hostname(), KnownShorteners); found {
+				return "", rejectedShortLink(fmt.Sprintf("Destination cannot be a link of the shortener %s", entry))
+			}
+		}
+		if !isOwnHost(parsed.Host, policy.Domains, baseURL) {
+			return originalURL, nil
+		}
+		if !policy.ResolveOwnLinks {
+			return "", rejectedShortLink("Destination cannot be a link of this shortener")
+		}
+
+		shortID := ownShortID(parsed)
+		if shortID == "" {
+			return "", rejectedShortLink("Destination points at this shortener but not at a link")
+		}
+		if visited[shortID] || hop == maxShortLinkHops {
+			return "", rejectedShortLink("Destination would create a redirect loop")
+		}
+		visited[shortID] = true
+
+		// Links of any tenant are served from the same domains
+		target, err := getPublicURL(shortID)
+		if err != nil {
+			return "", rejectedShortLink("Destination points at a link of this shortener that does not exist")
+		}
+		if !target.Enabled || target.Quarantine != "" || target.IsExpired(time.Now()) {
+			return "", rejectedShortLink("Destination points at a link of this shortener that is not active")
+		}
+		// Copying the destination would drop the limits and warning of the target link
+		if target.MaxClicks > 0 || target.ExpiresAt != nil {
+			return "", rejectedShortLink("Destination points at a link of this shortener with a click limit or an expiration")
+		}
+		if target.Warning != "" {
+			return "", rejectedShortLink("Destination points at a link of this shortener with a warning")
+		}
+		if target.HasPassword() {
+			return "", rejectedShortLink("Destination points at a password-protected link of this shortener")
+		}
+		originalURL = target.OriginalURL
+	}
+}
+
+// isOwnHost reports whether a canonical host[:port] serves our short links
+func isOwnHost(host string, domains []string, baseURL string) bool {
+	for _, base := range []string{BaseURL, baseURL} {
+		if parsed, err := url.Parse(base); err == nil && base != "" {
+			if own, err := canonicalHostPort(defaultPortless(parsed)); err == nil && own == host {
+				return true
+			}
+		}
+	}
+	for _, domain := range domains {
+		if domain == host {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultPortless returns the host of a base URL without the default port of its scheme
+func defaultPortless(parsed *url.URL) string {
+	if port := parsed.Port(); port != "" && port == defaultPorts[parsed.Scheme] {
+		return parsed.Hostname()
+	}
+	return parsed.Host
+}
+
+// ownShortID returns the short ID a link of ours points at: the last path segment, without
+// the '+' asking for a preview
+func ownShortID(parsed *url.URL) string {
+	path := strings.TrimSuffix(parsed.Path, "/")
+	return strings.TrimSuffix(path[strings.LastIndex(path, "/")+1:], "+")
+}
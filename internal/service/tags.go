@@ -0,0 +1,44 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	models2 "urlshortener/internal/models"
+)
+
+// maxTags bounds how many tags a single URL can carry
+const maxTags = 10
+
+// tagPattern restricts tags to lowercase URL-safe characters
+var tagPattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// normalizeTags lowercases, validates and deduplicates tags, keeping their order
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !tagPattern.MatchString(tag) {
+			return nil, &models2.APIError{
+				Code:    http.StatusBadRequest,
+				Message: "Tags must be 1 to 32 characters long and contain only letters, digits, '-' or '_'",
+			}
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > maxTags {
+		return nil, &models2.APIError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("A URL can carry at most %d tags", maxTags),
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, nil
+	}
+	return normalized, nil
+}
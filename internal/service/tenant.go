@@ -0,0 +1,162 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"urlshortener/internal/cache"
+	"urlshortener/internal/domain"
+	models2 "urlshortener/internal/models"
+)
+
+// dailyCreationsTTL keeps a day's creation counter around a little longer than the day
+const dailyCreationsTTL = 48 * time.Hour
+
+// defaultTenantQuota applies to tenants without their own entry in tenantQuotas
+var defaultTenantQuota domain.TenantQuota
+
+// tenantQuotas holds the quotas configured for specific tenants
+var tenantQuotas = map[string]domain.TenantQuota{}
+
+// SetTenantQuotas configures the quota of every tenant and per-tenant overrides given as a
+// JSON object, e.g. {"marketing":{"max_links":1000,"max_daily_creations":100}}
+func SetTenantQuotas(defaults domain.TenantQuota, overrides string) error {
+	quotas := map[string]domain.TenantQuota{}
+	if overrides != "" {
+		if err := json.Unmarshal([]byte(overrides), &quotas); err != nil {
+			return fmt.Errorf("tenant quotas must be a JSON object of tenant IDs to quotas: %w", err)
+		}
+	}
+	for tenant, quota := range quotas {
+		if !domain.IsValidTenant(tenant) {
+			return fmt.Errorf("invalid tenant ID %q", tenant)
+		}
+		if quota.MaxLinks < 0 || quota.MaxDailyCreations < 0 {
+			return fmt.Errorf("quotas of tenant %q cannot be negative", tenant)
+		}
+	}
+	if defaults.MaxLinks < 0 || defaults.MaxDailyCreations < 0 {
+		return fmt.Errorf("default tenant quotas cannot be negative")
+	}
+
+	defaultTenantQuota = defaults
+	tenantQuotas = quotas
+	return nil
+}
+
+// QuotaOf returns the quota that applies to tenant
+func QuotaOf(tenant string) domain.TenantQuota {
+	if quota, ok := tenantQuotas[tenant]; ok {
+		return quota
+	}
+	return defaultTenantQuota
+}
+
+// pendingLinksTTL bounds how long a reservation of a replica that died before finishing it
+// holds a place in the link quota
+const pendingLinksTTL = time.Minute
+
+// reserveCreation checks the quotas of tenant before one of its links is created and counts
+// the creation against the daily quota. finish must be called once the link was stored or
+// not; it gives the creation back to the day when it was not. The daily quota is not
+// enforced while Redis is unavailable.
+func reserveCreation(tenant string, now time.Time) (finish func(created bool), err error) {
+	quota := QuotaOf(tenant)
+	linkDone, err := reserveLink(tenant)
+	if err != nil {
+		return func(bool) {}, err
+	}
+	finish = func(bool) { linkDone() }
+
+	if quota.MaxDailyCreations > 0 {
+		key := tenantKey(tenant, "creations:"+now.UTC().Format(time.DateOnly))
+		created, err := cache.IncrementWithTTL(key, dailyCreationsTTL)
+		if err != nil {
+			log.Printf("Skipping daily quota of tenant %s: %v", tenant, err)
+			return finish, nil
+		}
+		release := func() {
+			if err := cache.DecrementCounter(key); err != nil {
+				log.Printf("Failed to release daily quota of tenant %s: %v", tenant, err)
+			}
+		}
+		if created > quota.MaxDailyCreations {
+			release()
+			linkDone()
+			return func(bool) {}, &models2.APIError{
+				Code:    http.StatusTooManyRequests,
+				Message: fmt.Sprintf("Tenant has reached its quota of %d links per day; it resets at midnight UTC", quota.MaxDailyCreations),
+			}
+		}
+		finish = func(created bool) {
+			linkDone()
+			if !created {
+				release()
+			}
+		}
+	}
+	return finish, nil
+}
+
+// reserveLink holds a place for one more live link in tenant, be it a new link or one
+// restored from the trash, and refuses it once the tenant holds as many as its quota
+// allows. Reservations of every replica are counted in Redis until done is called, after
+// the link was stored or not, so concurrent requests cannot overshoot the quota together;
+// one racing for the last free place may be turned away instead. Without Redis only the
+// stored links are counted.
+func reserveLink(tenant string) (done func(), err error) {
+	done = func() {}
+	quota := QuotaOf(tenant)
+	if quota.MaxLinks <= 0 {
+		return done, nil
+	}
+
+	key := tenantKey(tenant, "pending_links")
+	pending, err := cache.IncrementWithTTL(key, pendingLinksTTL)
+	if err != nil {
+		log.Printf("Counting only stored links against the quota of tenant %s: %v", tenant, err)
+		pending = 1
+	} else {
+		done = func() {
+			if err := cache.DecrementCounter(key); err != nil {
+				log.Printf("Failed to release link reservation of tenant %s: %v", tenant, err)
+			}
+		}
+	}
+
+	// Links stored after the increment are counted here too, so a reservation sees every
+	// other one either as pending or as stored
+	countResult := <-URLServiceInstance.ListURLs(domain.URLQuery{Tenant: tenant, Limit: 1}).Observe()
+	if countResult.E != nil {
+		done()
+		return func() {}, countResult.E
+	}
+	if countResult.V.(domain.URLPage).Total+pending > quota.MaxLinks {
+		done()
+		return func() {}, &models2.APIError{
+			Code:    http.StatusTooManyRequests,
+			Message: fmt.Sprintf("Tenant has reached its quota of %d links", quota.MaxLinks),
+		}
+	}
+	return done, nil
+}
+
+// tenantKey namespaces a Redis key that holds state of tenant
+func tenantKey(tenant, name string) string {
+	return "tenant:" + tenant + ":" + name
+}
+
+// linkKey namespaces a Redis key kept for the link shortID of tenant: its cached
+// destination when suffix is empty, or the state named by suffix, e.g. ":access_count"
+func linkKey(tenant, shortID, suffix string) string {
+	return tenantKey(tenant, "url:"+shortID+suffix)
+}
+
+// routeKey caches the tenant of a short ID, so redirects served from the cache find the
+// keys of the link without asking the repository. It is the only per-link key kept
+// outside the namespace of the tenant, and holds nothing but the tenant ID.
+func routeKey(shortID string) string {
+	return "link:" + shortID
+}
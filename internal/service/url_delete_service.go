@@ -0,0 +1,163 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+	"urlshortener/internal/cache"
+	"urlshortener/internal/domain"
+	models2 "urlshortener/internal/models"
+	"urlshortener/internal/repository"
+)
+
+// TrashRetention is how long a trashed URL can be restored before it is purged
+var TrashRetention = 30 * 24 * time.Hour
+
+// TrashURL soft deletes a URL of tenant on behalf of actor: it stops redirecting and is
+// hidden from listings, but can be restored with RestoreURL until TrashRetention has passed
+func TrashURL(shortID, tenant, actor string) (domain.URL, error) {
+	url, err := getStoredURL(tenant, shortID)
+	if err != nil {
+		return domain.URL{}, err
+	}
+	if url.DeletedAt != nil {
+		// Already in the trash; keep the original deletion time
+		return url, nil
+	}
+
+	touch(&url, actor)
+	deletedAt := url.UpdatedAt
+	url.DeletedAt = &deletedAt
+	updateResult := <-URLServiceInstance.UpdateURL(url).Observe()
+	if updateResult.E != nil {
+		return domain.URL{}, updateResult.E
+	}
+
+	// Trashed links never redirect, so this evicts the cached entry
+	return url, refreshCachedURL(url)
+}
+
+// RestoreURL takes a URL of tenant out of the trash on behalf of actor while its retention
+// window is still open and the tenant has room for it
+func RestoreURL(shortID, tenant, actor string) (domain.URL, error) {
+	url, err := getStoredURL(tenant, shortID)
+	if err != nil {
+		return domain.URL{}, err
+	}
+	if url.DeletedAt == nil {
+		return url, nil
+	}
+	if time.Since(*url.DeletedAt) > TrashRetention {
+		return domain.URL{}, &models2.APIError{
+			Code:    http.StatusGone,
+			Message: "URL can no longer be restored",
+		}
+	}
+
+	done, err := reserveLink(url.Tenant)
+	if err != nil {
+		return domain.URL{}, err
+	}
+	defer done()
+
+	url.DeletedAt = nil
+	touch(&url, actor)
+	updateResult := <-URLServiceInstance.UpdateURL(url).Observe()
+	if updateResult.E != nil {
+		return domain.URL{}, updateResult.E
+	}
+	return url, refreshCachedURL(url)
+}
+
+// PurgeURL permanently deletes a URL of tenant together with its cache entry and statistics.
+// Its open reports are closed on behalf of actor, so they leave the moderation queue and
+// never point at a later link that takes the ID.
+func PurgeURL(shortID, tenant, actor string) error {
+	deleteResult := <-URLServiceInstance.DeleteURL(tenant, shortID).Observe()
+	if errors.Is(deleteResult.E, repository.ErrURLNotFound) {
+		return errURLNotFound
+	} else if deleteResult.E != nil {
+		return deleteResult.E
+	}
+	closeResult := <-ReportServiceInstance.CloseReports(shortID, domain.ReportPurged, actor, auditNow()).Observe()
+	if closeResult.E != nil {
+		return closeResult.E
+	}
+	return cache.DeleteKeys(urlCacheKeys(tenant, shortID)...)
+}
+
+// StartTrashSweeper periodically purges URLs whose retention window has passed
+func StartTrashSweeper(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			result := <-URLServiceInstance.FindDeletedURLs(time.Now().Add(-TrashRetention)).Observe()
+			if result.E != nil {
+				log.Printf("Failed to list trashed URLs: %v", result.E)
+				continue
+			}
+			for _, url := range result.V.([]domain.URL) {
+				if err := PurgeURL(url.ID, url.Tenant, SystemActor); err != nil {
+					log.Printf("Failed to purge trashed URL %s: %v", url.ID, err)
+				}
+			}
+		}
+	}()
+}
+
+// urlCacheKeys lists every Redis key kept for the short ID of tenant
+func urlCacheKeys(tenant, shortID string) []string {
+	keys := []string{routeKey(shortID)}
+	for _, suffix := range []string{"", ":access_count", ":last_access", ":interstitial_views", ":interstitial_continues", ":clicks_used"} {
+		keys = append(keys, linkKey(tenant, shortID, suffix))
+	}
+	return keys
+}
+
+// getStoredURL retrieves a URL of tenant whether or not it is in the trash
+func getStoredURL(tenant, shortID string) (domain.URL, error) {
+	dbResult := <-URLServiceInstance.GetURL(tenant, shortID).Observe()
+	if errors.Is(dbResult.E, repository.ErrURLNotFound) {
+		return domain.URL{}, errURLNotFound
+	} else if dbResult.E != nil {
+		return domain.URL{}, dbResult.E
+	}
+	return dbResult.V.(domain.URL), nil
+}
+
+// getLiveURL retrieves a URL of tenant that is not in the trash; trashed URLs are
+// reported as not found
+func getLiveURL(tenant, shortID string) (domain.URL, error) {
+	url, err := getStoredURL(tenant, shortID)
+	if err == nil && url.DeletedAt != nil {
+		return domain.URL{}, errURLNotFound
+	}
+	return url, err
+}
+
+// getURLTenant returns the tenant of the link shortID, for requests such as redirects that
+// only know the short ID
+func getURLTenant(shortID string) (string, error) {
+	tenantResult := <-URLServiceInstance.GetURLTenant(shortID).Observe()
+	if errors.Is(tenantResult.E, repository.ErrURLNotFound) {
+		return "", errURLNotFound
+	} else if tenantResult.E != nil {
+		return "", tenantResult.E
+	}
+	return tenantResult.V.(string), nil
+}
+
+// getPublicURL retrieves the live URL shortID for a public request, whatever its tenant
+func getPublicURL(shortID string) (domain.URL, error) {
+	tenant, err := getURLTenant(shortID)
+	if err != nil {
+		return domain.URL{}, err
+	}
+	return getLiveURL(tenant, shortID)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"time"
+	"urlshortener/internal/domain"
+)
+
+// URLDetails is the stored record of a URL completed with its live statistics
+type URLDetails struct {
+	domain.URL
+	LastAccess        *time.Time `json:"last_access,omitempty"`        // Moment of the latest redirect, omitted if it never redirected
+	PasswordProtected bool       `json:"password_protected,omitempty"` // Redirects ask for a password
+}
+
+// URLPreview is what anybody holding a link may see of it without following it
+type URLPreview struct {
+	OriginalURL string     `json:"original_url"`
+	Enabled     bool       `json:"enabled"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // Omitted when the link never expires
+	ClickCount  int64      `json:"click_count"`
+}
+
+// PreviewURL returns the public preview of a live URL, whatever its tenant. Password-protected
+// URLs are not previewed, since that would reveal their destination.
+func PreviewURL(shortID string) (URLPreview, error) {
+	tenant, err := getURLTenant(shortID)
+	if err != nil {
+		return URLPreview{}, err
+	}
+	details, err := GetURLDetails(shortID, tenant, false)
+	if err != nil {
+		return URLPreview{}, err
+	}
+	if details.PasswordProtected {
+		return URLPreview{}, ErrPasswordRequired
+	}
+	return URLPreview{
+		OriginalURL: details.OriginalURL,
+		Enabled:     details.Enabled,
+		ExpiresAt:   details.ExpiresAt,
+		ClickCount:  details.ClickCount,
+	}, nil
+}
+
+// GetURLDetails returns the record and live click statistics of a URL of tenant without
+// counting a click. Trashed URLs are only returned when includeTrashed is set.
+func GetURLDetails(shortID, tenant string, includeTrashed bool) (URLDetails, error) {
+	url, err := getStoredURL(tenant, shortID)
+	if err != nil {
+		return URLDetails{}, err
+	}
+	if url.DeletedAt != nil && !includeTrashed {
+		return URLDetails{}, errURLNotFound
+	}
+	details := URLDetails{URL: url, PasswordProtected: url.HasPassword()}
+
+	// The synced click count lags behind Redis, so prefer the live counter
+	statsResult := <-NewURLStatService().GetURLStats(tenant, shortID).Observe()
+	if statsResult.E != nil {
+		return details, nil
+	}
+	stats := statsResult.V.(map[string]interface{})
+	if count := int64(stats["access_count"].(int)); count > details.ClickCount {
+		details.ClickCount = count
+	}
+	if lastAccess, err := time.Parse(time.RFC3339, stats["last_access"].(string)); err == nil {
+		details.LastAccess = &lastAccess
+	}
+	return details, nil
+}
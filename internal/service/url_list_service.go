@@ -0,0 +1,126 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"urlshortener/internal/domain"
+	models2 "urlshortener/internal/models"
+	"urlshortener/internal/request"
+)
+
+// Page sizes of URL listings
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// defaultListSort lists the newest URLs first
+const defaultListSort = "-" + domain.SortByCreatedAt
+
+// listCursor is the opaque next_cursor handed to clients. It remembers the sort it was
+// issued for so it cannot be replayed against a different order.
+type listCursor struct {
+	Sort string `json:"sort"`
+	domain.URLCursor
+}
+
+// ListURLs returns one page of live URLs of tenant matching the request filters, together
+// with the cursor of the next page, or an empty string on the last page
+func ListURLs(req request.ListURLsRequest, tenant string) (domain.URLPage, string, error) {
+	query, err := buildURLQuery(req)
+	if err != nil {
+		return domain.URLPage{}, "", err
+	}
+	query.Tenant = tenant
+
+	// Query the repository reactively
+	listResult := <-URLServiceInstance.ListURLs(query).Observe()
+	if listResult.E != nil {
+		return domain.URLPage{}, "", listResult.E
+	}
+	page := listResult.V.(domain.URLPage)
+
+	if !page.HasMore || len(page.URLs) == 0 {
+		return page, "", nil
+	}
+	last := page.URLs[len(page.URLs)-1]
+	next, err := encodeListCursor(listCursor{Sort: sortParam(query), URLCursor: domain.CursorOf(last)})
+	return page, next, err
+}
+
+// buildURLQuery validates the request and translates it into a repository query
+func buildURLQuery(req request.ListURLsRequest) (domain.URLQuery, error) {
+	query := domain.URLQuery{
+		Enabled:     req.Enabled,
+		CreatedFrom: req.CreatedFrom,
+		CreatedTo:   req.CreatedTo,
+		Domain:      strings.ToLower(req.Domain),
+		Owner:       req.Owner,
+		Tag:         strings.ToLower(req.Tag),
+		Search:      req.Query,
+		Limit:       req.Limit,
+	}
+
+	sort := req.Sort
+	if sort == "" {
+		sort = defaultListSort
+	}
+	query.Descending = strings.HasPrefix(sort, "-")
+	query.SortBy = strings.TrimPrefix(sort, "-")
+	if query.SortBy != domain.SortByCreatedAt && query.SortBy != domain.SortByClickCount {
+		return domain.URLQuery{}, &models2.APIError{
+			Code:    http.StatusBadRequest,
+			Message: "sort must be created_at or click_count, optionally prefixed with '-'",
+		}
+	}
+
+	switch {
+	case query.Limit == 0:
+		query.Limit = defaultListLimit
+	case query.Limit < 0 || query.Limit > maxListLimit:
+		return domain.URLQuery{}, &models2.APIError{
+			Code:    http.StatusBadRequest,
+			Message: "limit must be between 1 and 100",
+		}
+	}
+
+	if req.Cursor != "" {
+		cursor, err := decodeListCursor(req.Cursor)
+		if err != nil || cursor.Sort != sort {
+			return domain.URLQuery{}, &models2.APIError{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid cursor",
+			}
+		}
+		query.After = &cursor.URLCursor
+	}
+	return query, nil
+}
+
+// sortParam turns the sort of a query back into its sort parameter
+func sortParam(query domain.URLQuery) string {
+	if query.Descending {
+		return "-" + query.SortBy
+	}
+	return query.SortBy
+}
+
+func encodeListCursor(cursor listCursor) (string, error) {
+	raw, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeListCursor(encoded string) (listCursor, error) {
+	var cursor listCursor
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(raw, &cursor)
+	return cursor, err
+}
@@ -1,26 +1,100 @@
 package service
 
 import (
-	"crypto/md5"
-	"encoding/hex"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"time"
 	"urlshortener/internal/cache"
 	"urlshortener/internal/domain"
+	"urlshortener/internal/idgen"
 	"urlshortener/internal/interfaces"
 	models2 "urlshortener/internal/models"
+	"urlshortener/internal/repository"
+	"urlshortener/internal/request"
 )
 
+// maxShortIDAttempts bounds how many colliding short IDs are tried before giving up
+const maxShortIDAttempts = 5
+
 // URLServiceInstance URLServiceInterface is an instance of the interface URLServiceInterface which will be injected
 var URLServiceInstance interfaces.URLServiceInterface
 
-// CreateShortURL generates a shortened URL and stores it in the database and cache
-func CreateShortURL(originalURL string) (string, error) {
+// IDGeneratorInstance mints the short IDs; it defaults to the 6 character MD5 prefix
+var IDGeneratorInstance interfaces.IDGenerator = idgen.NewHashGenerator(6)
+
+// CreateShortURL generates a shortened URL, or uses the requested alias, and stores
+// it in the database and cache on behalf of actor from tenant, who becomes its owner.
+// Links are built under baseURL, or BaseURL when empty.
+func CreateShortURL(req request.ShortenRequest, baseURL, tenant, actor string) (string, error) {
+	// Canonicalize the destination so equivalent URLs are deduplicated
+	originalURL, err := normalizeDestination(req.OriginalURL)
+	if err != nil {
+		return "", err
+	}
+	// Links to our own links would chain redirects, so they point at the final destination
+	originalURL, err = resolveShortLink(originalURL, req.Alias, baseURL)
+	if err != nil {
+		return "", err
+	}
+	if err := checkDestination(originalURL); err != nil {
+		return "", err
+	}
 
-	// Check if the original URL already exists in the database reactively
-	existsObservable := URLServiceInstance.FindURLByOriginal(originalURL)
+	// Reject malformed or reserved aliases before touching the database
+	if req.Alias != "" {
+		if err := validateAlias(req.Alias); err != nil {
+			return "", err
+		}
+	}
+
+	// Work out when the link stops redirecting, if ever
+	expiresAt, err := resolveExpiry(req.ExpiresAt, req.TTL, time.Now())
+	if err != nil {
+		return "", err
+	}
+	if err := validateMaxClicks(req.MaxClicks); err != nil {
+		return "", err
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return "", err
+	}
+
+	createdAt := auditNow()
+	draft := domain.URL{
+		OriginalURL: originalURL,
+		Enabled:     true,
+		ExpiresAt:   expiresAt,
+		MaxClicks:   req.MaxClicks,
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+		CreatedBy:   actor,
+		UpdatedBy:   actor,
+		Tenant:      tenant,
+		Owner:       actor,
+		Domain:      domain.DestinationHost(originalURL),
+		Tags:        tags,
+	}
+	if req.Warn {
+		draft.Warning = WarningOwner
+	}
+	if req.Password != "" {
+		if draft.PasswordHash, err = hashPassword(req.Password); err != nil {
+			return "", err
+		}
+	}
+
+	// Check if the owner already shortened the original URL reactively
+	existsObservable := URLServiceInstance.FindURLByOriginal(originalURL, tenant, actor)
 	existsResult := <-existsObservable.Observe()
+	if existsResult.E == nil && existsResult.V.(domain.URL).DeletedAt != nil {
+		return "", &models2.APIError{
+			Code:    http.StatusConflict,
+			Message: "URL already exists in the trash; restore it instead",
+		}
+	}
 	if existsResult.E == nil && existsResult.V.(domain.URL).ShortURL != "" {
 		return existsResult.V.(domain.URL).ShortURL, &models2.APIError{
 			Code:    http.StatusConflict,
@@ -28,115 +102,243 @@ func CreateShortURL(originalURL string) (string, error) {
 		}
 	}
 
-	// Generate a unique ID (hash) for the shortened URL
-	shortID := generateShortID(originalURL)
-	shortURL := fmt.Sprintf("http://35.224.157.227/%s", shortID)
-
-	// Create the URL structure
-	url := domain.URL{
-		ID:          shortID,
-		OriginalURL: originalURL,
-		ShortURL:    shortURL,
-		Enabled:     true,
+	// Enforce the quotas of the tenant
+	finish, err := reserveCreation(tenant, createdAt)
+	if err != nil {
+		return "", err
 	}
 
-	// Save to MongoDB reactively
-	saveObservable := URLServiceInstance.SaveURL(url)
-	saveResult := <-saveObservable.Observe()
-	if saveResult.E != nil {
-		return "", saveResult.E
+	// Reserve the alias, or generate a unique ID (hash) for the shortened URL, and save it
+	var url domain.URL
+	if req.Alias != "" {
+		url, err = saveWithAlias(draft, req.Alias, baseURL)
+	} else {
+		url, err = saveWithUniqueID(draft, baseURL)
+	}
+	finish(err == nil)
+	if err != nil {
+		return "", err
 	}
 
-	// Cache in Redis reactively
-	cacheObservable := cache.SetURL(shortID, originalURL)
-	cacheResult := <-cacheObservable.Observe()
-	if cacheResult.E != nil {
-		fmt.Printf("Error caching URL in Redis: %v\n", cacheResult.E) // Non-blocking error handling
+	// Cache in Redis reactively, for no longer than the link lives
+	if err := cacheURL(url); err != nil {
+		fmt.Printf("Error caching URL in Redis: %v\n", err) // Non-blocking error handling
 	}
 
-	return shortURL, nil
+	return url.ShortURL, nil
 }
 
-// ResolveURL retrieves the original URL using the shortened ID
+// ResolveURL retrieves the original URL using the shortened ID, as if any warning page had
+// already been confirmed
 func ResolveURL(shortID string) (string, error) {
-	// Try to get the URL from cache reactively
-	cacheObservable := cache.GetURL(shortID)
-	cacheResult := <-cacheObservable.Observe()
-	if cacheResult.E == nil && cacheResult.V.(string) != "" {
-		return cacheResult.V.(string), nil
+	redirect, err := ResolveRedirect(shortID, RedirectAccess{Confirmed: true})
+	return redirect.OriginalURL, err
+}
+
+// ResolveRedirect retrieves where the link shortID leads, whatever its tenant. Links with a
+// warning are only followed once confirmed; until then the warning is returned and no click
+// is consumed. Password-protected links are only followed with their password.
+func ResolveRedirect(shortID string, access RedirectAccess) (Redirect, error) {
+	// Try to get the URL from cache reactively; links with a stored warning or a password are never cached
+	if tenant, originalURL := cachedRedirect(shortID); originalURL != "" {
+		// Lists change after links are cached, so cached destinations are screened too
+		if err := screenRedirect(tenant, shortID, originalURL); err != nil {
+			return Redirect{}, err
+		}
+		return Redirect{OriginalURL: originalURL, Warning: destinationWarning(originalURL), Tenant: tenant}, nil
+	}
+
+	// If not in cache, search in MongoDB reactively; trashed links are hidden as if they did not exist
+	url, err := getPublicURL(shortID)
+	if err != nil {
+		return Redirect{}, errors.New("URL not found")
+	}
+
+	// If expired, the link is gone for good
+	if url.IsExpired(time.Now()) {
+		return Redirect{}, &models2.APIError{
+			Code:    http.StatusGone,
+			Message: "URL has expired",
+		}
 	}
 
-	// If not in cache, search in MongoDB reactively
-	dbObservable := URLServiceInstance.GetURL(shortID)
-	dbResult := <-dbObservable.Observe()
-	if dbResult.E != nil {
-		return "", errors.New("URL not found")
+	// Quarantined links stay blocked until their owner fixes and enables them
+	if url.Quarantine != "" {
+		return Redirect{}, quarantinedError(url.Quarantine)
 	}
-	url := dbResult.V.(domain.URL)
 
-	// If disabled, return an error
+	// If disabled, return an error; limited links that ran out of clicks are gone
 	if !url.Enabled {
-		return "", errors.New("URL is disabled")
+		if url.MaxClicks > 0 && clicksExhausted(url) {
+			return Redirect{}, errClickLimitReached
+		}
+		return Redirect{}, errors.New("URL is disabled")
+	}
+
+	// Links whose destination became unsafe are quarantined
+	if reason, blocked := destinationBlocked(url.OriginalURL); blocked {
+		return Redirect{}, quarantineURL(url, reason)
+	}
+
+	// Neither the destination nor the click is given away without the password
+	redirect := Redirect{OriginalURL: url.OriginalURL, Warning: redirectWarning(url), Tenant: url.Tenant}
+	if url.HasPassword() {
+		if err := checkPassword(url, access.Password, access.ClientIP); err != nil {
+			return Redirect{}, &PasswordError{Err: err, Warning: redirect.Warning}
+		}
+	}
+
+	// Showing the warning page is not a click yet
+	if redirect.Warning != "" && !access.Confirmed {
+		return redirect, nil
+	}
+
+	// Limited links consume one click atomically across replicas
+	if url.MaxClicks > 0 {
+		if err := claimClick(url); err != nil {
+			return Redirect{}, err
+		}
 	}
 
 	// Cache for future requests reactively
-	cacheSaveObservable := cache.SetURL(shortID, url.OriginalURL)
-	cacheSaveResult := <-cacheSaveObservable.Observe()
-	if cacheSaveResult.E != nil {
-		fmt.Printf("Error caching URL in Redis: %v\n", cacheSaveResult.E) // Non-blocking error handling
+	if err := cacheURL(url); err != nil {
+		fmt.Printf("Error caching URL in Redis: %v\n", err) // Non-blocking error handling
 	}
 
-	return url.OriginalURL, nil
+	return redirect, nil
 }
 
-// ToggleURLState toggles the enabled/disabled state of the URL
-func ToggleURLState(shortID string) (bool, error) {
+// cachedRedirect returns the tenant and destination of the link shortID from the cache, or
+// an empty destination when it is not cached or Redis is unavailable
+func cachedRedirect(shortID string) (string, string) {
+	routeResult := <-cache.GetURL(routeKey(shortID)).Observe()
+	if routeResult.E != nil || routeResult.V.(string) == "" {
+		return "", ""
+	}
+	tenant := routeResult.V.(string)
+	cacheResult := <-cache.GetURL(linkKey(tenant, shortID, "")).Observe()
+	if cacheResult.E != nil {
+		return "", ""
+	}
+	return tenant, cacheResult.V.(string)
+}
+
+// SetURLState enables or disables a URL of tenant on behalf of actor and updates the cache
+// accordingly. It is idempotent and returns the state the URL had before the call.
+func SetURLState(shortID string, enabled bool, tenant, actor string) (bool, error) {
+	return setURLState(tenant, shortID, enabled, actor, false)
+}
+
+// setURLState is SetURLState on behalf of a moderator or not. Links disabled by a
+// moderator are locked until a moderator enables them again.
+func setURLState(tenant, shortID string, enabled bool, actor string, moderator bool) (bool, error) {
 	// Retrieve the URL from the database reactively
-	dbObservable := URLServiceInstance.GetURL(shortID)
-	dbResult := <-dbObservable.Observe()
-	if dbResult.E != nil {
-		return false, errors.New("URL not found")
+	url, err := getLiveURL(tenant, shortID)
+	if err != nil {
+		return false, err
+	}
+	previous := url.Enabled
+	if enabled && url.Quarantine == QuarantineModerator && !moderator {
+		return previous, errModeratorLock
 	}
-	url := dbResult.V.(domain.URL)
 
-	// Toggle the enabled state
-	url.Enabled = !url.Enabled
+	// Nothing to do when the URL is already in the requested state, unless a moderator
+	// locks a link its owner had disabled
+	lock := moderator && !enabled && url.Quarantine != QuarantineModerator
+	if previous == enabled && !lock {
+		return previous, nil
+	}
+	// Enabling lifts the quarantine, so the destination must pass the safety checks again
+	if enabled {
+		if err := checkDestination(url.OriginalURL); err != nil {
+			return previous, err
+		}
+		url.Quarantine = ""
+	} else if moderator {
+		url.Quarantine = QuarantineModerator
+	}
+	url.Enabled = enabled
+	touch(&url, actor)
 
 	// Save to MongoDB reactively
 	updateObservable := URLServiceInstance.UpdateURL(url)
 	updateResult := <-updateObservable.Observe()
 	if updateResult.E != nil {
-		return false, updateResult.E
+		return previous, updateResult.E
 	}
 
 	// Remove or update in cache based on the new state
-	if url.Enabled {
-		cacheUpdateObservable := cache.SetURL(shortID, url.OriginalURL)
-		cacheUpdateResult := <-cacheUpdateObservable.Observe()
-		if cacheUpdateResult.E != nil {
-			fmt.Printf("Error updating cache in Redis: %v\n", cacheUpdateResult.E)
-			return false, cacheUpdateResult.E
+	if err := refreshCachedURL(url); err != nil {
+		fmt.Printf("Error updating cache in Redis: %v\n", err)
+		return previous, err
+	}
+	return previous, nil
+}
+
+// saveWithUniqueID stores a new URL, asking the ID generator for another ID whenever
+// the generated one collides with a different URL
+func saveWithUniqueID(draft domain.URL, baseURL string) (domain.URL, error) {
+	for attempt := 0; attempt < maxShortIDAttempts; attempt++ {
+		shortID, err := IDGeneratorInstance.Generate(draft.OriginalURL, attempt)
+		if err != nil {
+			return domain.URL{}, err
 		}
-	} else {
-		cacheDeleteObservable := cache.DeleteURL(shortID)
-		cacheDeleteResult := <-cacheDeleteObservable.Observe()
-		if cacheDeleteResult.E != nil {
-			fmt.Printf("Error deleting from cache in Redis: %v\n", cacheDeleteResult.E)
-			return false, cacheDeleteResult.E
+
+		url, err := saveNewURL(draft, shortID, baseURL)
+		if !errors.Is(err, repository.ErrDuplicateURL) {
+			return url, err
 		}
 	}
-	return url.Enabled, nil
+
+	return domain.URL{}, &models2.APIError{
+		Code:    http.StatusInternalServerError,
+		Message: fmt.Sprintf("Could not generate a unique short ID after %d attempts", maxShortIDAttempts),
+	}
 }
 
-// generateShortID generates a unique ID based on the original URL
-func generateShortID(originalURL string) string {
-	// Uses hashFunction to generate a hash and takes the first 6 characters
-	return hashFunction(originalURL)[:6]
+// saveWithAlias stores a new URL under a caller chosen alias. The unique index on the
+// short ID makes the reservation atomic across replicas.
+func saveWithAlias(draft domain.URL, alias, baseURL string) (domain.URL, error) {
+	url, err := saveNewURL(draft, alias, baseURL)
+	if errors.Is(err, repository.ErrDuplicateURL) {
+		return domain.URL{}, &models2.APIError{
+			Code:    http.StatusConflict,
+			Message: "Alias is already taken",
+		}
+	}
+	return url, err
 }
 
-// hashFunction generates an MD5 hash of the original URL and converts it to a string
-func hashFunction(originalURL string) string {
-	hashMd5 := md5.New()
-	hashMd5.Write([]byte(originalURL))
-	return hex.EncodeToString(hashMd5.Sum(nil))
+// saveNewURL stores draft under shortID. It returns ErrDuplicateURL when the ID is taken
+// by a different URL, and a conflict when the original URL was stored concurrently.
+func saveNewURL(draft domain.URL, shortID, baseURL string) (domain.URL, error) {
+	// Complete the URL structure
+	url := draft
+	url.ID = shortID
+	url.ShortURL = buildShortURL(baseURL, shortID)
+
+	// Save to MongoDB reactively
+	saveObservable := URLServiceInstance.SaveURL(url)
+	saveResult := <-saveObservable.Observe()
+	if saveResult.E == nil {
+		// A link that had this ID before, e.g. one the storage expired on its own, may have
+		// left its click counters and statistics behind
+		if err := cache.DeleteKeys(urlCacheKeys(url.Tenant, shortID)...); err != nil {
+			log.Printf("Failed to clear the Redis keys of new URL %s: %v", shortID, err)
+		}
+		return url, nil
+	}
+	if !errors.Is(saveResult.E, repository.ErrDuplicateURL) {
+		return domain.URL{}, saveResult.E
+	}
+
+	// A concurrent request may have stored the same original URL in the meantime
+	existsResult := <-URLServiceInstance.FindURLByOriginal(url.OriginalURL, url.Tenant, url.Owner).Observe()
+	if existsResult.E == nil {
+		return domain.URL{}, &models2.APIError{
+			Code:    http.StatusConflict,
+			Message: "URL already exists",
+		}
+	}
+	return domain.URL{}, saveResult.E
 }
@@ -17,32 +17,30 @@ func NewURLStatService() *URLStatService {
 	return &URLStatService{}
 }
 
-func (s *URLStatService) GetURLStats(shortID string) rxgo.Observable {
+// GetURLStats reads the statistics of the link shortID of tenant
+func (s *URLStatService) GetURLStats(tenant, shortID string) rxgo.Observable {
 	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
 		// Gets the access counter
-		countObservable := cache.GetURL(shortID + ":access_count")
-		countResult := <-countObservable.Observe()
-		if countResult.E != nil {
-			ch <- rxgo.Error(countResult.E)
+		count, err := readCounter(linkKey(tenant, shortID, ":access_count"))
+		if err != nil {
+			ch <- rxgo.Error(err)
 			return
 		}
-		countStr := countResult.V.(string)
 
-		// Converts the counter to an integer
-		var count int
-		if countStr == "" {
-			count = 0
-		} else {
-			var err error
-			count, err = strconv.Atoi(countStr)
-			if err != nil {
-				ch <- rxgo.Error(err)
-				return
-			}
+		// Gets the warning page counters; redirects through it are part of the access counter
+		views, err := readCounter(linkKey(tenant, shortID, ":interstitial_views"))
+		if err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+		continues, err := readCounter(linkKey(tenant, shortID, ":interstitial_continues"))
+		if err != nil {
+			ch <- rxgo.Error(err)
+			return
 		}
 
 		// Gets the last access timestamp
-		lastAccessObservable := cache.GetURL(shortID + ":last_access")
+		lastAccessObservable := cache.GetURL(linkKey(tenant, shortID, ":last_access"))
 		lastAccessResult := <-lastAccessObservable.Observe()
 		if lastAccessResult.E != nil {
 			ch <- rxgo.Error(lastAccessResult.E)
@@ -54,17 +52,21 @@ func (s *URLStatService) GetURLStats(shortID string) rxgo.Observable {
 		}
 
 		stats := map[string]interface{}{
-			"access_count": count,
-			"last_access":  lastAccess,
+			"access_count":           count,
+			"direct_redirects":       max(count-continues, 0),
+			"interstitial_views":     views,
+			"interstitial_continues": continues,
+			"last_access":            lastAccess,
 		}
 		ch <- rxgo.Of(stats)
 	}})
 }
 
-func (s *URLStatService) RecordAccess(shortID string) rxgo.Observable {
+// RecordAccess counts a redirect through the link shortID of tenant
+func (s *URLStatService) RecordAccess(tenant, shortID string) rxgo.Observable {
 	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
 		// Increments the access counter
-		err := cache.IncrementURLCounter(shortID + ":access_count")
+		err := cache.IncrementURLCounter(linkKey(tenant, shortID, ":access_count"))
 		if err != nil {
 			ch <- rxgo.Error(err)
 			return
@@ -72,12 +74,48 @@ func (s *URLStatService) RecordAccess(shortID string) rxgo.Observable {
 
 		// Updates the last access timestamp
 		timestamp := time.Now().Format(time.RFC3339)
-		err = cache.SetLastAccess(shortID+":last_access", timestamp)
+		err = cache.SetLastAccess(linkKey(tenant, shortID, ":last_access"), timestamp)
 		if err != nil {
 			ch <- rxgo.Error(err)
 			return
 		}
 
+		// Queues the counter to be copied into the repository for listings
+		err = cache.AddToSet(pendingClickCountsKey, pendingClickCount(tenant, shortID))
+		if err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+
+		ch <- rxgo.Of(true)
+	}})
+}
+
+// RecordInterstitial counts a warning page shown for the link shortID of tenant or, when
+// continued, a click through it. The redirect that follows a click through is recorded by
+// RecordAccess too.
+func (s *URLStatService) RecordInterstitial(tenant, shortID string, continued bool) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		key := linkKey(tenant, shortID, ":interstitial_views")
+		if continued {
+			key = linkKey(tenant, shortID, ":interstitial_continues")
+		}
+		if err := cache.IncrementURLCounter(key); err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
 		ch <- rxgo.Of(true)
 	}})
 }
+
+// readCounter reads a Redis counter, which is 0 until first incremented
+func readCounter(key string) (int, error) {
+	countResult := <-cache.GetURL(key).Observe()
+	if countResult.E != nil {
+		return 0, countResult.E
+	}
+	if countResult.V.(string) == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(countResult.V.(string))
+}
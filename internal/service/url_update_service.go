@@ -0,0 +1,134 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"urlshortener/internal/cache"
+	"urlshortener/internal/domain"
+	models2 "urlshortener/internal/models"
+	"urlshortener/internal/repository"
+	"urlshortener/internal/request"
+)
+
+// errURLNotFound is returned by management operations on unknown short IDs
+var errURLNotFound = &models2.APIError{
+	Code:    http.StatusNotFound,
+	Message: "URL not found",
+}
+
+// UpdateShortURL changes the destination, expiration, click limit, tags, warning or password
+// of a URL of tenant and refreshes its cached entry. actor is recorded as the author of the
+// change. It returns the updated URL.
+func UpdateShortURL(shortID string, req request.UpdateURLRequest, tenant, actor string) (domain.URL, error) {
+	// Retrieve the URL from the database reactively
+	url, err := getLiveURL(tenant, shortID)
+	if err != nil {
+		return domain.URL{}, err
+	}
+
+	if req.OriginalURL != nil {
+		originalURL, err := normalizeDestination(*req.OriginalURL)
+		if err != nil {
+			return domain.URL{}, err
+		}
+		originalURL, err = resolveShortLink(originalURL, url.ID, "")
+		if err != nil {
+			return domain.URL{}, err
+		}
+
+		if originalURL != url.OriginalURL {
+			if err := checkDestination(originalURL); err != nil {
+				return domain.URL{}, err
+			}
+
+			// The destination must stay unique per owner so FindURLByOriginal keeps deduplicating
+			existsResult := <-URLServiceInstance.FindURLByOriginal(originalURL, url.Tenant, url.Owner).Observe()
+			if existsResult.E == nil && existsResult.V.(domain.URL).ID != url.ID {
+				return domain.URL{}, &models2.APIError{
+					Code:    http.StatusConflict,
+					Message: "URL already exists",
+				}
+			}
+			url.OriginalURL = originalURL
+			url.Domain = domain.DestinationHost(url.OriginalURL)
+		}
+	}
+
+	if req.ExpiresAt != nil || req.TTL != nil {
+		if req.ExpiresAt != nil && req.TTL != nil {
+			return domain.URL{}, &models2.APIError{
+				Code:    http.StatusBadRequest,
+				Message: "Use either expires_at or ttl, not both",
+			}
+		}
+		var ttl int64
+		if req.TTL != nil {
+			ttl = *req.TTL
+		}
+		// A ttl of 0 without expires_at resolves to nil and removes the expiration
+		expiresAt, err := resolveExpiry(req.ExpiresAt, ttl, time.Now())
+		if err != nil {
+			return domain.URL{}, err
+		}
+		url.ExpiresAt = expiresAt
+	}
+
+	if req.MaxClicks != nil {
+		if err := validateMaxClicks(*req.MaxClicks); err != nil {
+			return domain.URL{}, err
+		}
+		url.MaxClicks = *req.MaxClicks
+	}
+
+	if req.Tags != nil {
+		tags, err := normalizeTags(*req.Tags)
+		if err != nil {
+			return domain.URL{}, err
+		}
+		url.Tags = tags
+	}
+
+	if req.Warn != nil {
+		warning, err := ownerWarning(url.Warning, *req.Warn)
+		if err != nil {
+			return domain.URL{}, err
+		}
+		url.Warning = warning
+	}
+
+	if req.Password != nil {
+		url.PasswordHash = ""
+		if *req.Password != "" {
+			hash, err := hashPassword(*req.Password)
+			if err != nil {
+				return domain.URL{}, err
+			}
+			url.PasswordHash = hash
+		}
+	}
+
+	// Save to MongoDB reactively
+	touch(&url, actor)
+	updateResult := <-URLServiceInstance.UpdateURL(url).Observe()
+	if errors.Is(updateResult.E, repository.ErrDuplicateURL) {
+		return domain.URL{}, &models2.APIError{
+			Code:    http.StatusConflict,
+			Message: "URL already exists",
+		}
+	} else if updateResult.E != nil {
+		return domain.URL{}, updateResult.E
+	}
+
+	return url, refreshCachedURL(url)
+}
+
+// refreshCachedURL makes the Redis entry of a URL match its stored state: redirectable
+// links are cached for their remaining lifetime and everything else is evicted
+func refreshCachedURL(url domain.URL) error {
+	if url.Enabled && cacheTTL(url, time.Now()) > 0 {
+		return cacheURL(url)
+	}
+	cacheDeleteResult := <-cache.DeleteURL(linkKey(url.Tenant, url.ID, "")).Observe()
+	return cacheDeleteResult.E
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"urlshortener/internal/domain"
+	models2 "urlshortener/internal/models"
+)
+
+// Warnings set by hand; heuristic warnings are worked out on every redirect instead
+const (
+	WarningOwner     = "flagged by its owner"
+	WarningModerator = "flagged by a moderator"
+)
+
+// WarningPolicy decides which destinations get a warning page even though nobody flagged them
+type WarningPolicy struct {
+	RawIPs           bool     // Warn about destinations whose host is an IP address
+	TLDs             []string // Warn about destinations under these top-level domains, e.g. zip
+	GeneratedDomains bool     // Warn about domains that look generated rather than chosen, as fresh phishing domains often do
+}
+
+// warningPolicy is the policy applied by destinationWarning; no heuristic applies by default
+var warningPolicy WarningPolicy
+
+// SetWarningPolicy configures the heuristics that put a warning page in front of redirects
+func SetWarningPolicy(policy WarningPolicy) error {
+	tlds := make([]string, 0, len(policy.TLDs))
+	for _, tld := range policy.TLDs {
+		tld = strings.ToLower(strings.Trim(strings.TrimSpace(tld), "."))
+		if tld == "" {
+			continue
+		}
+		if strings.Contains(tld, ".") {
+			return fmt.Errorf("invalid top-level domain %q", tld)
+		}
+		tlds = append(tlds, tld)
+	}
+	policy.TLDs = tlds
+	warningPolicy = policy
+	return nil
+}
+
+// Redirect is where a link leads and, when set, why a warning page is shown before following it
+type Redirect struct {
+	OriginalURL string
+	Warning     string
+	Tenant      string // Tenant of the link, whose namespace holds its statistics
+}
+
+// redirectWarning returns the warning shown before redirecting to a link: the one set by
+// hand or, failing that, the one the heuristics give for its destination
+func redirectWarning(url domain.URL) string {
+	if url.Warning != "" {
+		return url.Warning
+	}
+	return destinationWarning(url.OriginalURL)
+}
+
+// destinationWarning returns why the heuristics distrust a destination, or an empty string
+func destinationWarning(rawURL string) string {
+	policy := warningPolicy
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "" {
+		return ""
+	}
+	if net.ParseIP(host) != nil {
+		if policy.RawIPs {
+			return "destination is a raw IP address"
+		}
+		return ""
+	}
+
+	labels := strings.Split(host, ".")
+	tld := labels[len(labels)-1]
+	if slices.Contains(policy.TLDs, tld) {
+		return fmt.Sprintf("destination uses the .%s top-level domain", tld)
+	}
+	if policy.GeneratedDomains && len(labels) >= 2 && looksGenerated(labels[len(labels)-2]) {
+		return "destination domain looks newly registered"
+	}
+	return ""
+}
+
+// looksGenerated reports whether a domain label looks machine-made or stuffed with words,
+// like secure-login-verify-account or x7k2m9q4: many hyphens, digits mixed into letters or
+// a long run of letters with hardly any vowels. Numbers before or after the letters, as in
+// web2024, are common in chosen names and do not count.
+func looksGenerated(label string) bool {
+	if strings.HasPrefix(label, "xn--") {
+		return false
+	}
+	var letters, digits, vowels int
+	for _, r := range label {
+		switch {
+		case r >= '0' && r <= '9':
+		case r >= 'a' && r <= 'z':
+			letters++
+			if strings.ContainsRune("aeiouy", r) {
+				vowels++
+			}
+		}
+	}
+	for _, r := range strings.Trim(label, "0123456789") {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	switch {
+	case strings.Count(label, "-") >= 3:
+		return true
+	case digits >= 3:
+		return true
+	case letters >= 12 && vowels*5 < letters:
+		return true
+	}
+	return false
+}
+
+// errModeratorWarning is returned when an owner tries to lift a warning set by a moderator
+var errModeratorWarning = &models2.APIError{
+	Code:    http.StatusForbidden,
+	Message: "Only a moderator can lift this warning",
+}
+
+// ownerWarning returns the warning of a link after its owner asks to set or lift it
+func ownerWarning(current string, warn bool) (string, error) {
+	switch {
+	case current == WarningModerator && !warn:
+		return current, errModeratorWarning
+	case current == WarningModerator:
+		return current, nil
+	case warn:
+		return WarningOwner, nil
+	}
+	return "", nil
+}
+
+// setURLWarning sets or lifts the warning of a URL of tenant on behalf of actor and updates
+// the cache accordingly, like SetURLState does for its state
+func setURLWarning(tenant, shortID, warning, actor string) error {
+	url, err := getLiveURL(tenant, shortID)
+	if err != nil {
+		return err
+	}
+	if url.Warning == warning {
+		return nil
+	}
+	url.Warning = warning
+	touch(&url, actor)
+
+	updateResult := <-URLServiceInstance.UpdateURL(url).Observe()
+	if updateResult.E != nil {
+		return updateResult.E
+	}
+	return refreshCachedURL(url)
+}
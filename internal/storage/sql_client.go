@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/reactivex/rxgo/v2"
+	"log"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // Registers the "pgx" database/sql driver
+	_ "modernc.org/sqlite"             // Registers the "sqlite" database/sql driver
+)
+
+const (
+	// DriverSQLite selects SQLite; the DSN is a file path or ":memory:"
+	DriverSQLite = "sqlite"
+	// DriverPostgres selects PostgreSQL; the DSN is a postgres:// connection URL
+	DriverPostgres = "postgres"
+)
+
+// SQLClient is the interface that defines SQL database connection methods
+type SQLClient interface {
+	Connect(driver, dsn string) rxgo.Observable
+	Disconnect() rxgo.Observable
+	GetDB() *sql.DB
+}
+
+// SQLService implements the SQLClient interface
+type SQLService struct {
+	DB *sql.DB
+}
+
+// Connect opens and verifies a reactive connection to a SQL database
+func (s *SQLService) Connect(driver, dsn string) rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		var driverName string
+		switch driver {
+		case DriverSQLite:
+			driverName = "sqlite"
+		case DriverPostgres:
+			driverName = "pgx"
+		default:
+			ch <- rxgo.Error(fmt.Errorf("unsupported SQL driver %q", driver))
+			return
+		}
+
+		db, err := sql.Open(driverName, dsn)
+		if err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+
+		// SQLite serializes writers anyway, and every connection to ":memory:"
+		// would otherwise get its own empty database
+		if driver == DriverSQLite {
+			db.SetMaxOpenConns(1)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err = db.PingContext(ctx); err != nil {
+			_ = db.Close()
+			ch <- rxgo.Error(err)
+			return
+		}
+
+		s.DB = db
+		ch <- rxgo.Of(db)
+		log.Printf("Connected to %s reactively", driver)
+	}})
+}
+
+// Disconnect closes the SQL connection pool reactively
+func (s *SQLService) Disconnect() rxgo.Observable {
+	return rxgo.Defer([]rxgo.Producer{func(_ context.Context, ch chan<- rxgo.Item) {
+		if s.DB == nil {
+			ch <- rxgo.Of("No active SQL connection to disconnect")
+			log.Println("No active SQL connection to disconnect")
+			return
+		}
+
+		if err := s.DB.Close(); err != nil {
+			ch <- rxgo.Error(err)
+			return
+		}
+
+		s.DB = nil
+		ch <- rxgo.Of("Disconnected from SQL database")
+		log.Println("Disconnected from SQL database reactively")
+	}})
+}
+
+// GetDB provides access to the underlying SQL connection pool
+func (s *SQLService) GetDB() *sql.DB {
+	return s.DB
+}
@@ -0,0 +1,145 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"urlshortener/internal/auth"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/handler"
+	"urlshortener/internal/repository"
+	"urlshortener/internal/request"
+	"urlshortener/internal/service"
+)
+
+// issueTestKey issues an API key of tenant with scopes, as an admin of the default tenant,
+// and returns its ID and request headers
+func issueTestKey(t *testing.T, tenant, name string, scopes ...string) (string, map[string]string) {
+	key, token, err := service.IssueAPIKey(request.IssueAPIKeyRequest{Name: name, Scopes: scopes, Tenant: tenant}, domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	require.Equal(t, tenant, key.Tenant)
+	return key.ID, map[string]string{"X-API-Key": token}
+}
+
+// newAuthRouter registers the shortener and API key routes behind authentication, accepting
+// JWT bearer tokens too when verifier is not nil
+func newAuthRouter(t *testing.T, adminKey string, verifier *auth.JWTVerifier) *gin.Engine {
+	service.APIKeyServiceInstance = repository.NewMemoryAPIKeyService()
+	service.SetBootstrapAPIKey(adminKey)
+	t.Cleanup(func() { service.SetBootstrapAPIKey("") })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	authenticator := handler.NewAuthenticator(true)
+	authenticator.JWT = verifier
+	urlShortenerHandler := handler.NewURLShortenerHandler()
+	urlStatHandler := handler.NewURLStatHandler()
+	apiKeyHandler := handler.NewAPIKeyHandler()
+	router.POST("/shorten", authenticator.Require(domain.ScopeCreate), urlShortenerHandler.ShortenURLHandler)
+	router.GET("/:id", urlShortenerHandler.RedirectURLHandler)
+	router.POST("/:id", urlShortenerHandler.ContinueRedirectHandler)
+	router.PATCH("/:id", authenticator.Require(domain.ScopeManage), urlShortenerHandler.SetURLStateHandler)
+	router.GET("/urls", authenticator.Require(domain.ScopeManage), urlShortenerHandler.ListURLsHandler)
+	router.GET("/urls/:id", authenticator.Require(domain.ScopeManage), urlShortenerHandler.GetURLHandler)
+	router.DELETE("/urls/:id", authenticator.Require(domain.ScopeManage), urlShortenerHandler.DeleteURLHandler)
+	router.GET("/stats/:id", authenticator.Require(domain.ScopeReadStats), urlStatHandler.GetURLStats)
+	router.POST("/admin/api-keys", authenticator.Require(domain.ScopeAdmin), apiKeyHandler.IssueAPIKeyHandler)
+	router.GET("/admin/api-keys", authenticator.Require(domain.ScopeAdmin), apiKeyHandler.ListAPIKeysHandler)
+	router.DELETE("/admin/api-keys/:id", authenticator.Require(domain.ScopeAdmin), apiKeyHandler.RevokeAPIKeyHandler)
+	return router
+}
+
+// Test issuing, using and revoking API keys through the authenticated routes
+func TestAPIKeyAuthentication(t *testing.T) {
+	urlService, _ := setupShortenerService(t)
+	router := newAuthRouter(t, "bootstrap-secret", nil)
+	admin := map[string]string{"X-API-Key": "bootstrap-secret"}
+
+	// Everything but redirects needs a key
+	recorder := performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.com","alias":"home"}`, nil)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.NotEmpty(t, recorder.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, http.StatusUnauthorized, performRequest(router, http.MethodGet, "/admin/api-keys", "", map[string]string{"X-API-Key": "wrong"}).Code)
+
+	// The bootstrap key issues a key that can only create links
+	recorder = performRequest(router, http.MethodPost, "/admin/api-keys", `{"name":"ci","scopes":["create","Create"]}`, admin)
+	require.Equal(t, http.StatusCreated, recorder.Code)
+	var issued struct {
+		APIKey domain.APIKey `json:"api_key"`
+		Token  string        `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &issued))
+	assert.True(t, strings.HasPrefix(issued.Token, service.APIKeyPrefix))
+	assert.True(t, strings.HasPrefix(issued.Token, issued.APIKey.Prefix))
+	assert.Equal(t, []string{domain.ScopeCreate}, issued.APIKey.Scopes)
+	assert.NotContains(t, recorder.Body.String(), "key_hash")
+	bearer := map[string]string{"Authorization": "Bearer " + issued.Token}
+
+	recorder = performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.com","alias":"home"}`, bearer)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	item := <-urlService.GetURL(domain.DefaultTenant, "home").Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, "api-key:"+issued.APIKey.ID, item.V.(domain.URL).CreatedBy)
+
+	assert.Equal(t, http.StatusFound, performRequest(router, http.MethodGet, "/home", "", nil).Code)
+	assert.Equal(t, http.StatusForbidden, performRequest(router, http.MethodPatch, "/home", `{"enabled":false}`, bearer).Code)
+	assert.Equal(t, http.StatusForbidden, performRequest(router, http.MethodGet, "/admin/api-keys", "", bearer).Code)
+
+	// Revoked keys stop working right away
+	recorder = performRequest(router, http.MethodDelete, "/admin/api-keys/"+issued.APIKey.ID, "", admin)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "revoked_at")
+	assert.Equal(t, http.StatusUnauthorized, performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.com/2"}`, bearer).Code)
+
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodDelete, "/admin/api-keys/missing", "", admin).Code)
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, http.MethodPost, "/admin/api-keys", `{"name":"x","scopes":["root"]}`, admin).Code)
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, http.MethodPost, "/admin/api-keys", `{"name":"x","scopes":[]}`, admin).Code)
+
+	recorder = performRequest(router, http.MethodGet, "/admin/api-keys", "", admin)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), issued.APIKey.ID)
+}
+
+// Test the SQL API key repository
+func TestSQLAPIKeyRepository(t *testing.T) {
+	urlService := newSQLiteURLService(t)
+	keyService := &repository.SQLAPIKeyServiceImpl{DB: urlService.DB, Dialect: urlService.Dialect}
+	key := domain.APIKey{
+		ID:        "k1",
+		Name:      "ci",
+		Prefix:    "usk_abcd",
+		Hash:      "deadbeef",
+		Scopes:    []string{domain.ScopeCreate, domain.ScopeReadStats},
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+		CreatedBy: "bootstrap",
+	}
+	item := <-keyService.SaveAPIKey(key).Observe()
+	require.NoError(t, item.E)
+
+	item = <-keyService.FindAPIKeyByHash("deadbeef").Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, key, item.V.(domain.APIKey))
+	item = <-keyService.FindAPIKeyByHash("other").Observe()
+	assert.ErrorIs(t, item.E, repository.ErrAPIKeyNotFound)
+
+	revokedAt := time.Now().UTC().Truncate(time.Millisecond)
+	item = <-keyService.RevokeAPIKey("k1", revokedAt).Observe()
+	require.NoError(t, item.E)
+	item = <-keyService.RevokeAPIKey("k1", revokedAt.Add(time.Hour)).Observe()
+	require.NoError(t, item.E)
+	item = <-keyService.GetAPIKey("k1").Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, revokedAt, *item.V.(domain.APIKey).RevokedAt)
+
+	item = <-keyService.RevokeAPIKey("missing", revokedAt).Observe()
+	assert.ErrorIs(t, item.E, repository.ErrAPIKeyNotFound)
+
+	item = <-keyService.ListAPIKeys("").Observe()
+	require.NoError(t, item.E)
+	assert.Len(t, item.V.([]domain.APIKey), 1)
+}
@@ -0,0 +1,30 @@
+package test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"urlshortener/internal/cache"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/repository"
+	"urlshortener/internal/request"
+	"urlshortener/internal/service"
+)
+
+// Test that the in-process Redis serves links and expires keys on its own
+func TestEmbeddedRedis(t *testing.T) {
+	require.NoError(t, cache.InitEmbeddedRedis())
+	service.URLServiceInstance = repository.NewMemoryURLService()
+
+	_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/", Alias: "promo"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	originalURL, err := service.ResolveURL("promo")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/", originalURL)
+
+	require.NoError(t, (<-cache.SetURL("short-lived", "value", time.Second).Observe()).E)
+	assert.Eventually(t, func() bool {
+		return (<-cache.GetURL("short-lived").Observe()).V == ""
+	}, 5*time.Second, 100*time.Millisecond)
+}
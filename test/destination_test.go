@@ -0,0 +1,140 @@
+package test
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strings"
+	"testing"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/models"
+	"urlshortener/internal/request"
+	"urlshortener/internal/service"
+)
+
+// setDestinationPolicy applies policy for the duration of the test
+func setDestinationPolicy(t *testing.T, policy service.DestinationPolicy) {
+	require.NoError(t, service.SetDestinationPolicy(policy))
+	t.Cleanup(func() { _ = service.SetDestinationPolicy(service.DefaultDestinationPolicy) })
+}
+
+// Test that malformed, oversized and non-web destinations are rejected
+func TestCreateShortURLRejectsInvalidDestinations(t *testing.T) {
+	setupShortenerService(t)
+
+	for _, originalURL := range []string{
+		"javascript:alert(1)",
+		"ftp://example.com/file",
+		"data:text/html,hello",
+		"mailto:someone@example.com",
+		"example",
+		"/relative/path",
+		"https://",
+		"https:///path",
+		"https://exa mple.com",
+		"https://-bad.example.com",
+		"https://a..b.com",
+		"https://example.com/" + strings.Repeat("a", 2048),
+	} {
+		_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: originalURL}, "", domain.DefaultTenant, "tester")
+		var apiErr *models.APIError
+		require.True(t, errors.As(err, &apiErr), originalURL)
+		assert.Equal(t, http.StatusBadRequest, apiErr.Code, originalURL)
+	}
+}
+
+// Test that equivalent destinations are canonicalized into a single link
+func TestCreateShortURLCanonicalizesDestination(t *testing.T) {
+	urlService, _ := setupShortenerService(t)
+
+	shortURL, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: " HTTPS://Bücher.Example.COM:443/Path?b=2&a=1#top "}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	item := <-urlService.GetURL(domain.DefaultTenant, shortIDOf(shortURL)).Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, "https://xn--bcher-kva.example.com/Path?b=2&a=1#top", item.V.(domain.URL).OriginalURL)
+	assert.Equal(t, "xn--bcher-kva.example.com", item.V.(domain.URL).Domain)
+
+	duplicate, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://xn--bcher-kva.example.com./Path?b=2&a=1#top"}, "", domain.DefaultTenant, "tester")
+	var apiErr *models.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusConflict, apiErr.Code)
+	assert.Equal(t, shortURL, duplicate)
+
+	// Ports other than the default one and IP addresses are kept
+	for rawURL, canonical := range map[string]string{
+		"http://Example.com:8080":        "http://example.com:8080",
+		"http://example.com:80/":         "http://example.com/",
+		"http://[2001:DB8::1]:80/x":      "http://[2001:db8::1]/x",
+		"https://[2001:db8::1]:8443/x":   "https://[2001:db8::1]:8443/x",
+		"https://192.0.2.10/x?q=a%20b":   "https://192.0.2.10/x?q=a%20b",
+		"https://例え.テスト/パス":              "https://xn--r8jz45g.xn--zckzah/%E3%83%91%E3%82%B9",
+		"https://my_host.example.com/ok": "https://my_host.example.com/ok",
+	} {
+		shortURL, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: rawURL}, "", domain.DefaultTenant, "tester")
+		require.NoError(t, err, rawURL)
+		originalURL, err := service.ResolveURL(shortIDOf(shortURL))
+		require.NoError(t, err)
+		assert.Equal(t, canonical, originalURL, rawURL)
+	}
+}
+
+// Test the configurable parts of the destination policy
+func TestDestinationPolicy(t *testing.T) {
+	setupShortenerService(t)
+	setDestinationPolicy(t, service.DestinationPolicy{
+		AllowedSchemes: []string{"HTTPS", "ftp"},
+		MaxLength:      60,
+		SortQuery:      true,
+		StripFragment:  true,
+	})
+
+	shortURL, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/?b=2&a=1&b=1#section"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	originalURL, err := service.ResolveURL(shortIDOf(shortURL))
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/?a=1&b=2&b=1", originalURL)
+
+	_, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/?a=1&b=2&b=1"}, "", domain.DefaultTenant, "tester")
+	var apiErr *models.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusConflict, apiErr.Code)
+
+	_, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: "ftp://files.example.com:21/report.pdf"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+
+	for _, rejected := range []string{"http://example.com", "https://example.com/" + strings.Repeat("a", 41)} {
+		_, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: rejected}, "", domain.DefaultTenant, "tester")
+		require.True(t, errors.As(err, &apiErr), rejected)
+		assert.Equal(t, http.StatusBadRequest, apiErr.Code, rejected)
+	}
+
+	assert.Error(t, service.SetDestinationPolicy(service.DestinationPolicy{MaxLength: 10}))
+	assert.Error(t, service.SetDestinationPolicy(service.DestinationPolicy{AllowedSchemes: []string{"https://"}, MaxLength: 10}))
+	assert.Error(t, service.SetDestinationPolicy(service.DestinationPolicy{AllowedSchemes: []string{"https"}}))
+}
+
+// Test that updated destinations are validated and canonicalized too
+func TestUpdateShortURLCanonicalizesDestination(t *testing.T) {
+	setupShortenerService(t)
+	_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/taken"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	_, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/old", Alias: "docs"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+
+	taken := "HTTPS://EXAMPLE.com:443/taken"
+	_, err = service.UpdateShortURL("docs", request.UpdateURLRequest{OriginalURL: &taken}, domain.DefaultTenant, "tester")
+	var apiErr *models.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusConflict, apiErr.Code)
+
+	invalid := "ftp://example.com"
+	_, err = service.UpdateShortURL("docs", request.UpdateURLRequest{OriginalURL: &invalid}, domain.DefaultTenant, "tester")
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+
+	updated := "https://Example.com/New"
+	url, err := service.UpdateShortURL("docs", request.UpdateURLRequest{OriginalURL: &updated}, domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/New", url.OriginalURL)
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"urlshortener/internal/cache"
+	"urlshortener/internal/idgen"
+)
+
+// Test base62 and base58 encoding of numbers
+func TestEncode(t *testing.T) {
+	assert.Equal(t, "0", idgen.Encode(0, idgen.Base62Alphabet))
+	assert.Equal(t, "z", idgen.Encode(61, idgen.Base62Alphabet))
+	assert.Equal(t, "10", idgen.Encode(62, idgen.Base62Alphabet))
+	assert.Equal(t, "21", idgen.Encode(58, idgen.Base58Alphabet))
+}
+
+// Test that the hash strategy is deterministic and lengthens the ID on retries
+func TestHashGenerator(t *testing.T) {
+	generator := idgen.NewHashGenerator(6)
+
+	first, err := generator.Generate("https://example.com", 0)
+	require.NoError(t, err)
+	again, _ := generator.Generate("https://example.com", 0)
+	retry, _ := generator.Generate("https://example.com", 1)
+
+	assert.Equal(t, first, again)
+	assert.Len(t, first, 6)
+	assert.Len(t, retry, 7)
+	assert.NotEqual(t, first, retry[:6])
+}
+
+// Test that random IDs have the configured length and alphabet
+func TestRandomGenerator(t *testing.T) {
+	generator := idgen.NewRandomGenerator(8, idgen.Base58Alphabet)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id, err := generator.Generate("https://example.com", 0)
+		require.NoError(t, err)
+		assert.Len(t, id, 8)
+		for _, r := range id {
+			assert.True(t, strings.ContainsRune(idgen.Base58Alphabet, r))
+		}
+		seen[id] = true
+	}
+	assert.Len(t, seen, 100)
+}
+
+// Test that the counter strategy hands out consecutive base62 values
+func TestCounterGenerator(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	cache.InitRedis(redisServer.Addr(), "", 0)
+	require.NoError(t, redisServer.Set("short_id:sequence", "61"))
+
+	generator, err := idgen.New(idgen.StrategyCounter, 6, "base62")
+	require.NoError(t, err)
+
+	first, err := generator.Generate("https://example.com", 0)
+	require.NoError(t, err)
+	second, err := generator.Generate("https://example.org", 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, "10", first)
+	assert.Equal(t, "11", second)
+}
+
+// Test that unknown strategies and alphabets are rejected
+func TestNewRejectsUnknownSettings(t *testing.T) {
+	_, err := idgen.New("sequential", 6, "base62")
+	assert.Error(t, err)
+
+	_, err = idgen.New(idgen.StrategyRandom, 6, "hex")
+	assert.Error(t, err)
+}
@@ -0,0 +1,229 @@
+package test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+	"urlshortener/internal/auth"
+	"urlshortener/internal/domain"
+)
+
+const (
+	testIssuer   = "https://id.example.com"
+	testAudience = "urlshortener"
+)
+
+// testSigningKey is a locally generated key published in the test JWKS under kid
+type testSigningKey struct {
+	kid    string
+	method jwt.SigningMethod
+	key    crypto.Signer
+}
+
+func newRSASigningKey(t *testing.T, kid string) testSigningKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return testSigningKey{kid: kid, method: jwt.SigningMethodRS256, key: key}
+}
+
+func newECSigningKey(t *testing.T, kid string) testSigningKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return testSigningKey{kid: kid, method: jwt.SigningMethodES256, key: key}
+}
+
+// jwk returns the public half of the key in JWK form
+func (k testSigningKey) jwk() map[string]string {
+	encode := base64.RawURLEncoding.EncodeToString
+	switch public := k.key.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kid": k.kid, "kty": "RSA", "use": "sig", "n": encode(public.N.Bytes()), "e": encode(big.NewInt(int64(public.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		size := (public.Curve.Params().BitSize + 7) / 8
+		return map[string]string{"kid": k.kid, "kty": "EC", "crv": "P-256", "x": encode(public.X.FillBytes(make([]byte, size))), "y": encode(public.Y.FillBytes(make([]byte, size)))}
+	}
+	return nil
+}
+
+// sign issues a token with the given claims, filling in valid defaults for the registered ones
+func (k testSigningKey) sign(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(k.method, withDefaultClaims(claims))
+	token.Header["kid"] = k.kid
+	signed, err := token.SignedString(k.key)
+	require.NoError(t, err)
+	return signed
+}
+
+func withDefaultClaims(claims jwt.MapClaims) jwt.MapClaims {
+	defaults := jwt.MapClaims{"iss": testIssuer, "aud": testAudience, "sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+	for name, value := range claims {
+		defaults[name] = value
+	}
+	return defaults
+}
+
+func marshalJWKS(t *testing.T, keys ...testSigningKey) []byte {
+	jwks := struct {
+		Keys []map[string]string `json:"keys"`
+	}{}
+	for _, key := range keys {
+		jwks.Keys = append(jwks.Keys, key.jwk())
+	}
+	raw, err := json.Marshal(jwks)
+	require.NoError(t, err)
+	return raw
+}
+
+// writeJWKS stores a JWKS with the public keys in a temporary file and returns its path
+func writeJWKS(t *testing.T, keys ...testSigningKey) string {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, marshalJWKS(t, keys...), 0o600))
+	return path
+}
+
+func newTestVerifier(t *testing.T, source string) *auth.JWTVerifier {
+	verifier, err := auth.NewJWTVerifier(auth.JWTConfig{JWKSSource: source, Issuer: testIssuer, Audience: testAudience})
+	require.NoError(t, err)
+	return verifier
+}
+
+// Test that tokens signed by the JWKS keys are accepted and everything else is rejected
+func TestJWTVerifier(t *testing.T) {
+	rsaKey, ecKey := newRSASigningKey(t, "rsa-1"), newECSigningKey(t, "ec-1")
+	verifier := newTestVerifier(t, writeJWKS(t, rsaKey, ecKey))
+
+	identity, err := verifier.Verify(rsaKey.sign(t, jwt.MapClaims{"roles": []string{"create", "read-stats"}}))
+	require.NoError(t, err)
+	assert.Equal(t, auth.Identity{Subject: "alice", Roles: []string{"create", "read-stats"}}, identity)
+
+	identity, err = verifier.Verify(ecKey.sign(t, jwt.MapClaims{"sub": "bob", "aud": []string{"other", testAudience}, "roles": "manage create", "tenant": "sales"}))
+	require.NoError(t, err)
+	assert.Equal(t, auth.Identity{Subject: "bob", Roles: []string{"manage", "create"}, Tenant: "sales"}, identity)
+
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, withDefaultClaims(nil))
+	hmac.Header["kid"] = "rsa-1"
+	hmacToken, err := hmac.SignedString([]byte("secret"))
+	require.NoError(t, err)
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, withDefaultClaims(nil)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+
+	rejected := map[string]string{
+		"wrong issuer":   rsaKey.sign(t, jwt.MapClaims{"iss": "https://evil.example.com"}),
+		"wrong audience": rsaKey.sign(t, jwt.MapClaims{"aud": "other"}),
+		"expired":        rsaKey.sign(t, jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}),
+		"no expiry":      rsaKey.sign(t, jwt.MapClaims{"exp": nil}),
+		"not yet valid":  rsaKey.sign(t, jwt.MapClaims{"nbf": time.Now().Add(time.Hour).Unix()}),
+		"no subject":     rsaKey.sign(t, jwt.MapClaims{"sub": ""}),
+		"bad tenant":     rsaKey.sign(t, jwt.MapClaims{"tenant": "Sales:Team"}),
+		"unknown key":    newRSASigningKey(t, "rsa-1").sign(t, nil),
+		"unknown kid":    newRSASigningKey(t, "rsa-2").sign(t, nil),
+		"hmac":           hmacToken,
+		"none":           unsigned,
+		"garbage":        "not-a-token",
+	}
+	for name, token := range rejected {
+		_, err := verifier.Verify(token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken, name)
+	}
+}
+
+// Test that nested role claims are read and bad configuration fails at startup
+func TestJWTVerifierConfiguration(t *testing.T) {
+	key := newRSASigningKey(t, "rsa-1")
+	path := writeJWKS(t, key)
+
+	verifier, err := auth.NewJWTVerifier(auth.JWTConfig{JWKSSource: path, Issuer: testIssuer, Audience: testAudience, RolesClaim: "realm_access.roles"})
+	require.NoError(t, err)
+	identity, err := verifier.Verify(key.sign(t, jwt.MapClaims{"realm_access": map[string]interface{}{"roles": []string{"admin"}}}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admin"}, identity.Roles)
+
+	_, err = auth.NewJWTVerifier(auth.JWTConfig{JWKSSource: path, Issuer: testIssuer})
+	assert.Error(t, err)
+	_, err = auth.NewJWTVerifier(auth.JWTConfig{JWKSSource: filepath.Join(t.TempDir(), "missing.json"), Issuer: testIssuer, Audience: testAudience})
+	assert.Error(t, err)
+	empty := filepath.Join(t.TempDir(), "empty.json")
+	require.NoError(t, os.WriteFile(empty, []byte(`{"keys":[{"kid":"enc","kty":"RSA","use":"enc","n":"AQAB","e":"AQAB"}]}`), 0o600))
+	_, err = auth.NewJWTVerifier(auth.JWTConfig{JWKSSource: empty, Issuer: testIssuer, Audience: testAudience})
+	assert.Error(t, err)
+}
+
+// Test that a JWKS served over HTTP picks up rotated keys on refresh and keeps the old
+// keys when the endpoint fails
+func TestJWTVerifierRefresh(t *testing.T) {
+	oldKey, newKey := newRSASigningKey(t, "old"), newECSigningKey(t, "new")
+	var jwks atomic.Value
+	jwks.Store(marshalJWKS(t, oldKey))
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(jwks.Load().([]byte))
+	}))
+	t.Cleanup(server.Close)
+
+	verifier := newTestVerifier(t, server.URL)
+	_, err := verifier.Verify(oldKey.sign(t, nil))
+	require.NoError(t, err)
+
+	// The rotated key is unknown until the next refresh, which an unknown kid only
+	// triggers once a minute has passed since the last one
+	jwks.Store(marshalJWKS(t, newKey))
+	_, err = verifier.Verify(newKey.sign(t, nil))
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	require.NoError(t, verifier.Refresh())
+	_, err = verifier.Verify(newKey.sign(t, nil))
+	require.NoError(t, err)
+	_, err = verifier.Verify(oldKey.sign(t, nil))
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	failing.Store(true)
+	assert.Error(t, verifier.Refresh())
+	_, err = verifier.Verify(newKey.sign(t, nil))
+	assert.NoError(t, err)
+}
+
+// Test that JWT roles gate routes like API key scopes and the subject is recorded as actor
+func TestJWTAuthentication(t *testing.T) {
+	urlService, _ := setupShortenerService(t)
+	key := newRSASigningKey(t, "rsa-1")
+	router := newAuthRouter(t, "bootstrap-secret", newTestVerifier(t, writeJWKS(t, key)))
+	creator := map[string]string{"Authorization": "Bearer " + key.sign(t, jwt.MapClaims{"roles": []string{domain.ScopeCreate}})}
+	manager := map[string]string{"Authorization": "Bearer " + key.sign(t, jwt.MapClaims{"roles": []string{domain.ScopeManage}})}
+
+	recorder := performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.com","alias":"home"}`, creator)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	item := <-urlService.GetURL(domain.DefaultTenant, "home").Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, "user:alice", item.V.(domain.URL).CreatedBy)
+
+	assert.Equal(t, http.StatusForbidden, performRequest(router, http.MethodPatch, "/home", `{"enabled":false}`, creator).Code)
+	require.Equal(t, http.StatusOK, performRequest(router, http.MethodPatch, "/home", `{"enabled":false}`, manager).Code)
+	item = <-urlService.GetURL(domain.DefaultTenant, "home").Observe()
+	assert.Equal(t, "user:alice", item.V.(domain.URL).UpdatedBy)
+
+	expired := map[string]string{"Authorization": "Bearer " + key.sign(t, jwt.MapClaims{"roles": []string{domain.ScopeAdmin}, "exp": time.Now().Add(-time.Hour).Unix()})}
+	recorder = performRequest(router, http.MethodGet, "/admin/api-keys", "", expired)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("WWW-Authenticate"), "invalid_token")
+
+	// API keys keep working next to JWTs
+	assert.Equal(t, http.StatusOK, performRequest(router, http.MethodGet, "/admin/api-keys", "", map[string]string{"X-API-Key": "bootstrap-secret"}).Code)
+}
@@ -0,0 +1,130 @@
+package test
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/repository"
+)
+
+// Test that a saved URL can be read back by ID and by original URL
+func TestMemorySaveAndGetURL(t *testing.T) {
+	urlService := repository.NewMemoryURLService()
+	testURL := domain.URL{ID: "testID", OriginalURL: "https://example.com", Enabled: true}
+
+	item := <-urlService.SaveURL(testURL).Observe()
+	assert.NoError(t, item.E)
+
+	item = <-urlService.GetURL("", "testID").Observe()
+	assert.NoError(t, item.E)
+	assert.Equal(t, testURL, item.V.(domain.URL))
+
+	item = <-urlService.FindURLByOriginal("https://example.com", "", "").Observe()
+	assert.NoError(t, item.E)
+	assert.Equal(t, testURL, item.V.(domain.URL))
+}
+
+// Test the not found and duplicate error semantics
+func TestMemoryURLErrors(t *testing.T) {
+	urlService := repository.NewMemoryURLService()
+
+	item := <-urlService.GetURL("", "missing").Observe()
+	assert.ErrorIs(t, item.E, repository.ErrURLNotFound)
+
+	item = <-urlService.FindURLByOriginal("https://missing.example.com", "", "").Observe()
+	assert.ErrorIs(t, item.E, repository.ErrURLNotFound)
+
+	testURL := domain.URL{ID: "testID", OriginalURL: "https://example.com"}
+	<-urlService.SaveURL(testURL).Observe()
+	item = <-urlService.SaveURL(domain.URL{ID: "testID", OriginalURL: "https://other.example.com"}).Observe()
+	assert.ErrorIs(t, item.E, repository.ErrDuplicateURL)
+}
+
+// Test that UpdateURL moves the original URL mapping along with the record
+func TestMemoryUpdateURL(t *testing.T) {
+	urlService := repository.NewMemoryURLService()
+	<-urlService.SaveURL(domain.URL{ID: "testID", OriginalURL: "https://example.com", Enabled: true}).Observe()
+
+	updated := domain.URL{ID: "testID", OriginalURL: "https://example.org", Enabled: false}
+	item := <-urlService.UpdateURL(updated).Observe()
+	assert.NoError(t, item.E)
+
+	item = <-urlService.GetURL("", "testID").Observe()
+	assert.NoError(t, item.E)
+	assert.False(t, item.V.(domain.URL).Enabled)
+
+	item = <-urlService.FindURLByOriginal("https://example.com", "", "").Observe()
+	assert.ErrorIs(t, item.E, repository.ErrURLNotFound)
+
+	item = <-urlService.FindURLByOriginal("https://example.org", "", "").Observe()
+	assert.NoError(t, item.E)
+	assert.Equal(t, "testID", item.V.(domain.URL).ID)
+}
+
+// Test that every lookup and change is scoped to the tenant of the URL
+func TestMemoryTenantScoping(t *testing.T) {
+	urlService := repository.NewMemoryURLService()
+	<-urlService.SaveURL(domain.URL{ID: "launch", OriginalURL: "https://example.com", Tenant: "marketing", Enabled: true}).Observe()
+
+	item := <-urlService.GetURLTenant("launch").Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, "marketing", item.V.(string))
+
+	item = <-urlService.GetURL("sales", "launch").Observe()
+	assert.ErrorIs(t, item.E, repository.ErrURLNotFound)
+	<-urlService.UpdateURL(domain.URL{ID: "launch", OriginalURL: "https://evil.example.com", Tenant: "sales"}).Observe()
+	<-urlService.UpdateClickCount("sales", "launch", 10).Observe()
+	item = <-urlService.DeleteURL("sales", "launch").Observe()
+	assert.ErrorIs(t, item.E, repository.ErrURLNotFound)
+
+	item = <-urlService.GetURL("marketing", "launch").Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, "https://example.com", item.V.(domain.URL).OriginalURL)
+	assert.Zero(t, item.V.(domain.URL).ClickCount)
+}
+
+// Test that concurrent writers never store the same ID twice
+func TestMemoryConcurrentSave(t *testing.T) {
+	urlService := repository.NewMemoryURLService()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	saved := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			url := domain.URL{ID: "sameID", OriginalURL: fmt.Sprintf("https://example.com/%d", i)}
+			if item := <-urlService.SaveURL(url).Observe(); item.E == nil {
+				mu.Lock()
+				saved++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, saved)
+}
+
+// Test that expired URLs are swept along with their original URL mapping
+func TestMemoryDeleteExpiredURLs(t *testing.T) {
+	urlService := repository.NewMemoryURLService()
+	past := time.Now().Add(-time.Minute)
+	<-urlService.SaveURL(domain.URL{ID: "expired", OriginalURL: "https://example.com/old", ExpiresAt: &past}).Observe()
+	<-urlService.SaveURL(domain.URL{ID: "forever", OriginalURL: "https://example.com/forever"}).Observe()
+
+	item := <-urlService.DeleteExpiredURLs(time.Now()).Observe()
+	require.NoError(t, item.E)
+	require.Len(t, item.V.([]domain.URL), 1)
+	assert.Equal(t, "expired", item.V.([]domain.URL)[0].ID)
+
+	item = <-urlService.FindURLByOriginal("https://example.com/old", "", "").Observe()
+	assert.ErrorIs(t, item.E, repository.ErrURLNotFound)
+	item = <-urlService.GetURL("", "forever").Observe()
+	assert.NoError(t, item.E)
+}
@@ -0,0 +1,177 @@
+package test
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/handler"
+	"urlshortener/internal/models"
+	"urlshortener/internal/request"
+	"urlshortener/internal/service"
+)
+
+// usePasswordThrottle installs throttle for the duration of the test
+func usePasswordThrottle(t *testing.T, throttle service.PasswordThrottle) {
+	service.SetPasswordThrottle(throttle)
+	t.Cleanup(func() {
+		service.SetPasswordThrottle(service.PasswordThrottle{
+			PerLink: models.RateLimit{Limit: 20, Period: 15 * time.Minute},
+			PerIP:   models.RateLimit{Limit: 10, Period: 15 * time.Minute},
+		})
+	})
+}
+
+// Test the password form, the header used by API clients and that passwords are hashed
+func TestPasswordProtectedRedirect(t *testing.T) {
+	memURLService, redisServer := setupShortenerService(t)
+	handler.URLStatService = *service.NewURLStatService()
+	router := newShortenerRouter(handler.NewURLShortenerHandler())
+
+	recorder := performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.com/secret","alias":"locked","password":"short"}`, nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	recorder = performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.com/secret","alias":"locked","password":"open sesame"}`, nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.False(t, redisServer.Exists(defaultLinkKey("locked")), "protected links are not cached")
+
+	url := (<-memURLService.GetURL(domain.DefaultTenant, "locked").Observe()).V.(domain.URL)
+	assert.True(t, url.HasPassword())
+	assert.NotContains(t, url.PasswordHash, "open sesame")
+
+	// Browsers get the form, without the destination
+	recorder = performRequest(router, http.MethodGet, "/locked", "", nil)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("Content-Type"), "text/html")
+	assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+	assert.Contains(t, recorder.Body.String(), `name="password"`)
+	assert.NotContains(t, recorder.Body.String(), "example.com")
+
+	form := map[string]string{"Content-Type": "application/x-www-form-urlencoded"}
+	recorder = performRequest(router, http.MethodPost, "/locked", "password=guess", form)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "Wrong password")
+	recorder = performRequest(router, http.MethodPost, "/locked", "password=open+sesame", form)
+	require.Equal(t, http.StatusFound, recorder.Code)
+	assert.Equal(t, "https://example.com/secret", recorder.Header().Get("Location"))
+
+	// API clients send the password in a header and get JSON errors
+	recorder = performRequest(router, http.MethodGet, "/locked", "", map[string]string{"Accept": "application/json"})
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.JSONEq(t, `{"error":"This link is protected by a password"}`, recorder.Body.String())
+	recorder = performRequest(router, http.MethodGet, "/locked", "", map[string]string{"X-Link-Password": "guess"})
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.JSONEq(t, `{"error":"Wrong password"}`, recorder.Body.String())
+	recorder = performRequest(router, http.MethodGet, "/locked", "", map[string]string{"X-Link-Password": "open sesame"})
+	require.Equal(t, http.StatusFound, recorder.Code)
+	assert.Equal(t, "https://example.com/secret", recorder.Header().Get("Location"))
+	assert.False(t, redisServer.Exists(defaultLinkKey("locked")))
+
+	// Previews would give the destination away
+	assert.Equal(t, http.StatusUnauthorized, performRequest(router, http.MethodGet, "/locked+", "", nil).Code)
+	details, err := service.GetURLDetails("locked", domain.DefaultTenant, false)
+	require.NoError(t, err)
+	assert.True(t, details.PasswordProtected)
+	_, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: "http://localhost:8080/locked"}, "", domain.DefaultTenant, "tester")
+	var apiErr *models.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnprocessableEntity, apiErr.Code)
+
+	// Owners can change or remove the password
+	empty := ""
+	_, err = service.UpdateShortURL("locked", request.UpdateURLRequest{Password: &empty}, domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusFound, performRequest(router, http.MethodGet, "/locked", "", nil).Code)
+}
+
+// Test that wrong passwords are throttled per link and per client
+func TestPasswordThrottle(t *testing.T) {
+	_, redisServer := setupShortenerService(t)
+	usePasswordThrottle(t, service.PasswordThrottle{
+		PerLink: models.RateLimit{Limit: 3, Period: time.Minute},
+		PerIP:   models.RateLimit{Limit: 2, Period: time.Minute},
+	})
+	for _, alias := range []string{"first", "second"} {
+		_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/" + alias, Alias: alias, Password: "open sesame"}, "", domain.DefaultTenant, "tester")
+		require.NoError(t, err)
+	}
+	guess := func(shortID, password, ip string) error {
+		_, err := service.ResolveRedirect(shortID, service.RedirectAccess{Password: password, ClientIP: ip})
+		return err
+	}
+
+	// Two failures from one client lock that client out of every link
+	assert.ErrorIs(t, guess("first", "guess", "192.0.2.1"), service.ErrWrongPassword)
+	assert.ErrorIs(t, guess("second", "guess", "192.0.2.1"), service.ErrWrongPassword)
+	var throttled *service.ThrottledError
+	require.ErrorAs(t, guess("first", "open sesame", "192.0.2.1"), &throttled)
+	assert.Equal(t, http.StatusTooManyRequests, throttled.Code)
+	assert.Greater(t, throttled.RetryAfter, time.Duration(0))
+	assert.NoError(t, guess("second", "open sesame", "192.0.2.2"))
+
+	// Failures from many clients lock the link itself
+	assert.ErrorIs(t, guess("second", "guess", "192.0.2.3"), service.ErrWrongPassword)
+	assert.ErrorIs(t, guess("second", "guess", "192.0.2.4"), service.ErrWrongPassword)
+	assert.ErrorAs(t, guess("second", "open sesame", "192.0.2.6"), &throttled)
+
+	// The handler tells clients when to retry
+	router := newShortenerRouter(handler.NewURLShortenerHandler())
+	recorder := performRequest(router, http.MethodGet, "/second", "", map[string]string{"X-Link-Password": "open sesame"})
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, "60", recorder.Header().Get("Retry-After"))
+
+	redisServer.FastForward(time.Minute)
+	assert.NoError(t, guess("second", "open sesame", "192.0.2.1"))
+}
+
+// Test that clients cannot escape the per-IP throttle by forging X-Forwarded-For
+func TestPasswordThrottleIgnoresSpoofedForwardedFor(t *testing.T) {
+	setupShortenerService(t)
+	usePasswordThrottle(t, service.PasswordThrottle{
+		PerLink: models.RateLimit{Limit: 100, Period: time.Minute},
+		PerIP:   models.RateLimit{Limit: 2, Period: time.Minute},
+	})
+	router := newShortenerRouter(handler.NewURLShortenerHandler())
+	require.NoError(t, handler.TrustProxies(router, ""))
+	_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/secret", Alias: "locked", Password: "open sesame"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+
+	for _, forged := range []string{"203.0.113.7", "198.51.100.2"} {
+		recorder := performRequest(router, http.MethodGet, "/locked", "", map[string]string{"X-Link-Password": "guess", "X-Forwarded-For": forged})
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code, forged)
+	}
+	recorder := performRequest(router, http.MethodGet, "/locked", "", map[string]string{"X-Link-Password": "guess", "X-Forwarded-For": "192.0.2.200"})
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+}
+
+// Test that clients behind a trusted proxy are throttled by their own address, and that
+// requests only known by the proxy address fall back to the per-link throttle
+func TestPasswordThrottleBehindTrustedProxy(t *testing.T) {
+	setupShortenerService(t)
+	usePasswordThrottle(t, service.PasswordThrottle{
+		PerLink: models.RateLimit{Limit: 4, Period: time.Minute},
+		PerIP:   models.RateLimit{Limit: 1, Period: time.Minute},
+	})
+	router := newShortenerRouter(handler.NewURLShortenerHandler())
+	require.NoError(t, handler.TrustProxies(router, testProxy))
+	_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/secret", Alias: "locked", Password: "open sesame"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	attempt := func(password string, headers map[string]string) int {
+		headers["X-Link-Password"] = password
+		return performRequest(router, http.MethodGet, "/locked", "", headers).Code
+	}
+
+	// One client spending its attempts does not lock out the others behind the proxy
+	assert.Equal(t, http.StatusUnauthorized, attempt("guess", map[string]string{"X-Forwarded-For": "203.0.113.7"}))
+	assert.Equal(t, http.StatusTooManyRequests, attempt("open sesame", map[string]string{"X-Forwarded-For": "203.0.113.7"}))
+	assert.Equal(t, http.StatusFound, attempt("open sesame", map[string]string{"X-Forwarded-For": "198.51.100.2"}))
+
+	// Without a forwarded address the proxy's own is not throttled, only the link is
+	assert.Equal(t, http.StatusUnauthorized, attempt("guess", map[string]string{}))
+	assert.Equal(t, http.StatusUnauthorized, attempt("guess", map[string]string{}))
+	assert.Equal(t, http.StatusFound, attempt("open sesame", map[string]string{}))
+	assert.Equal(t, http.StatusUnauthorized, attempt("guess", map[string]string{}))
+	assert.Equal(t, http.StatusTooManyRequests, attempt("open sesame", map[string]string{}))
+}
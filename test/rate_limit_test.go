@@ -0,0 +1,149 @@
+package test
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+	"urlshortener/internal/handler"
+	"urlshortener/internal/models"
+)
+
+// testProxy is the remote address of every request sent by performRequest
+const testProxy = "192.0.2.1"
+
+// newRateLimitedRouter registers rate limited test routes that always answer 200. Requests
+// come through testProxy, which is trusted.
+func newRateLimitedRouter(t *testing.T, limiter *handler.RateLimiter, shorten, redirect models.RateLimit) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	require.NoError(t, handler.TrustProxies(router, testProxy))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.POST("/shorten", limiter.Limit("shorten", shorten), ok)
+	router.GET("/:id", limiter.Limit("redirect", redirect), ok)
+	router.GET("/stats/:id", func(c *gin.Context) { c.Set(handler.ActorKey, "api-key:"+c.Query("key")) }, limiter.Limit("stats", shorten), ok)
+	return router
+}
+
+// Test that a client spending its budget gets 429 until the bucket refills
+func TestRateLimit(t *testing.T) {
+	_, redisServer := setupShortenerService(t)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	redisServer.SetTime(start)
+	router := newRateLimitedRouter(t, handler.NewRateLimiter(true), models.RateLimit{Limit: 3, Period: time.Minute}, models.RateLimit{Limit: 1, Period: time.Second})
+	client := map[string]string{"X-Forwarded-For": "203.0.113.7"}
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		recorder := performRequest(router, http.MethodPost, "/shorten", "", client)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "3", recorder.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "3;w=60", recorder.Header().Get("RateLimit-Policy"))
+		assert.Equal(t, strconv.Itoa(remaining), recorder.Header().Get("RateLimit-Remaining"))
+	}
+
+	recorder := performRequest(router, http.MethodPost, "/shorten", "", client)
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, "20", recorder.Header().Get("Retry-After"))
+	assert.Equal(t, "0", recorder.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", recorder.Header().Get("RateLimit-Reset"))
+
+	// Other clients and other routes have their own buckets
+	assert.Equal(t, http.StatusOK, performRequest(router, http.MethodPost, "/shorten", "", map[string]string{"X-Forwarded-For": "198.51.100.2"}).Code)
+	assert.Equal(t, http.StatusOK, performRequest(router, http.MethodGet, "/promo", "", client).Code)
+	assert.Equal(t, http.StatusTooManyRequests, performRequest(router, http.MethodGet, "/promo", "", client).Code)
+
+	// One request is refilled every period / limit
+	redisServer.SetTime(start.Add(20 * time.Second))
+	assert.Equal(t, http.StatusOK, performRequest(router, http.MethodPost, "/shorten", "", client).Code)
+	assert.Equal(t, http.StatusTooManyRequests, performRequest(router, http.MethodPost, "/shorten", "", client).Code)
+}
+
+// Test that clients cannot reset their budget by forging X-Forwarded-For unless the proxy is trusted
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	setupShortenerService(t)
+	router := newRateLimitedRouter(t, handler.NewRateLimiter(true), models.RateLimit{}, models.RateLimit{Limit: 1, Period: time.Minute})
+	require.NoError(t, handler.TrustProxies(router, ""))
+
+	assert.Equal(t, http.StatusOK, performRequest(router, http.MethodGet, "/promo", "", nil).Code)
+	for _, forged := range []string{"203.0.113.7", "198.51.100.2", "203.0.113.7, 198.51.100.2"} {
+		recorder := performRequest(router, http.MethodGet, "/promo", "", map[string]string{"X-Forwarded-For": forged})
+		assert.Equal(t, http.StatusTooManyRequests, recorder.Code, forged)
+	}
+}
+
+// Test that clients behind a trusted proxy network are limited by their own address, not the proxy's
+func TestRateLimitBehindTrustedProxy(t *testing.T) {
+	setupShortenerService(t)
+	router := newRateLimitedRouter(t, handler.NewRateLimiter(true), models.RateLimit{}, models.RateLimit{Limit: 1, Period: time.Minute})
+	require.NoError(t, handler.TrustProxies(router, "192.0.2.0/24"))
+
+	// The proxy forwards each client's address in X-Forwarded-For
+	first := map[string]string{"X-Forwarded-For": "203.0.113.7"}
+	second := map[string]string{"X-Forwarded-For": "198.51.100.2"}
+	assert.Equal(t, http.StatusOK, performRequest(router, http.MethodGet, "/promo", "", first).Code)
+	assert.Equal(t, http.StatusOK, performRequest(router, http.MethodGet, "/promo", "", second).Code)
+	assert.Equal(t, http.StatusTooManyRequests, performRequest(router, http.MethodGet, "/promo", "", first).Code)
+	assert.Equal(t, http.StatusTooManyRequests, performRequest(router, http.MethodGet, "/promo", "", second).Code)
+
+	// A forged entry before the real client does not open a new bucket
+	forged := map[string]string{"X-Forwarded-For": "10.0.0.1, 203.0.113.7"}
+	assert.Equal(t, http.StatusTooManyRequests, performRequest(router, http.MethodGet, "/promo", "", forged).Code)
+}
+
+// Test that authenticated callers are limited by identity rather than IP address
+func TestRateLimitByActor(t *testing.T) {
+	setupShortenerService(t)
+	router := newRateLimitedRouter(t, handler.NewRateLimiter(true), models.RateLimit{Limit: 1, Period: time.Minute}, models.RateLimit{})
+
+	assert.Equal(t, http.StatusOK, performRequest(router, http.MethodGet, "/stats/promo?key=a", "", nil).Code)
+	assert.Equal(t, http.StatusTooManyRequests, performRequest(router, http.MethodGet, "/stats/promo?key=a", "", nil).Code)
+	assert.Equal(t, http.StatusOK, performRequest(router, http.MethodGet, "/stats/promo?key=b", "", nil).Code)
+
+	// A disabled budget never limits
+	for i := 0; i < 5; i++ {
+		recorder := performRequest(router, http.MethodGet, "/promo", "", nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Empty(t, recorder.Header().Get("RateLimit-Limit"))
+	}
+}
+
+// Test that requests are let through when rate limiting is disabled or Redis is down
+func TestRateLimitFailOpen(t *testing.T) {
+	_, redisServer := setupShortenerService(t)
+	budget := models.RateLimit{Limit: 1, Period: time.Minute}
+
+	disabled := newRateLimitedRouter(t, handler.NewRateLimiter(false), budget, budget)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, performRequest(disabled, http.MethodPost, "/shorten", "", nil).Code)
+	}
+
+	router := newRateLimitedRouter(t, handler.NewRateLimiter(true), budget, budget)
+	redisServer.Close()
+	for i := 0; i < 3; i++ {
+		recorder := performRequest(router, http.MethodPost, "/shorten", "", nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Empty(t, recorder.Header().Get("RateLimit-Limit"))
+	}
+}
+
+// Test parsing rate limits from configuration
+func TestParseRateLimit(t *testing.T) {
+	rate, err := models.ParseRateLimit("60/1m")
+	require.NoError(t, err)
+	assert.Equal(t, models.RateLimit{Limit: 60, Period: time.Minute}, rate)
+	assert.Equal(t, "60/1m0s", rate.String())
+
+	for _, disabled := range []string{"", "0", " "} {
+		rate, err = models.ParseRateLimit(disabled)
+		require.NoError(t, err)
+		assert.False(t, rate.Enabled())
+	}
+
+	for _, invalid := range []string{"60", "-1/1m", "x/1m", "60/100ms", "60/soon"} {
+		_, err = models.ParseRateLimit(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
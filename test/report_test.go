@@ -0,0 +1,289 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/handler"
+	"urlshortener/internal/repository"
+	"urlshortener/internal/request"
+	"urlshortener/internal/service"
+)
+
+// useMemoryReports keeps reports and bans in memory, forgetting the bans after the test
+func useMemoryReports(t *testing.T) {
+	service.ReportServiceInstance = repository.NewMemoryReportService()
+	require.NoError(t, service.LoadBannedDomains())
+	t.Cleanup(func() {
+		service.ReportServiceInstance = repository.NewMemoryReportService()
+		require.NoError(t, service.LoadBannedDomains())
+	})
+}
+
+// newReportRouter adds the report and moderation routes to the authenticated router, with
+// reports and bans kept in memory
+func newReportRouter(t *testing.T, adminKey string) *gin.Engine {
+	useMemoryReports(t)
+	router := newAuthRouter(t, adminKey, nil)
+	authenticator := handler.NewAuthenticator(true)
+	reportHandler := handler.NewReportHandler()
+	router.POST("/report/:id", reportHandler.ReportURLHandler)
+	router.GET("/admin/reports", authenticator.Require(domain.ScopeModerate), reportHandler.ListReportedURLsHandler)
+	router.POST("/admin/reports/:id", authenticator.Require(domain.ScopeModerate), reportHandler.ModerateURLHandler)
+	router.GET("/admin/banned-domains", authenticator.Require(domain.ScopeModerate), reportHandler.ListBannedDomainsHandler)
+	router.DELETE("/admin/banned-domains/:domain", authenticator.Require(domain.ScopeModerate), reportHandler.UnbanDomainHandler)
+	return router
+}
+
+// Test validating reports and listing the moderation queue by report count
+func TestReportURL(t *testing.T) {
+	setupShortenerService(t)
+	router := newReportRouter(t, "bootstrap-secret")
+	admin := map[string]string{"X-API-Key": "bootstrap-secret"}
+	for _, alias := range []string{"one", "two"} {
+		body := `{"original_url":"https://example.com/` + alias + `","alias":"` + alias + `"}`
+		require.Equal(t, http.StatusOK, performRequest(router, http.MethodPost, "/shorten", body, admin).Code)
+	}
+
+	// Reports need no credentials but must be valid
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, http.MethodPost, "/report/one", `{}`, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, http.MethodPost, "/report/one", `{"reason":"boring"}`, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, http.MethodPost, "/report/one", `{"reason":"spam","email":"not an email"}`, nil).Code)
+	longDetails := `{"reason":"spam","details":"` + strings.Repeat("x", 1001) + `"}`
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, http.MethodPost, "/report/one", longDetails, nil).Code)
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodPost, "/report/missing", `{"reason":"spam"}`, nil).Code)
+
+	recorder := performRequest(router, http.MethodPost, "/report/one", `{"reason":"Phishing","email":"alice@example.org"}`, map[string]string{"User-Agent": "browser"})
+	require.Equal(t, http.StatusCreated, recorder.Code)
+	assert.NotContains(t, recorder.Body.String(), "alice@example.org", "reporter metadata is not echoed")
+	require.Equal(t, http.StatusCreated, performRequest(router, http.MethodPost, "/report/one", `{"reason":"spam"}`, nil).Code)
+	require.Equal(t, http.StatusCreated, performRequest(router, http.MethodPost, "/report/two", `{"reason":"malware"}`, nil).Code)
+
+	// Only moderators see the queue
+	assert.Equal(t, http.StatusUnauthorized, performRequest(router, http.MethodGet, "/admin/reports", "", nil).Code)
+	_, creator := issueTestKey(t, domain.DefaultTenant, "ci", domain.ScopeCreate)
+	assert.Equal(t, http.StatusForbidden, performRequest(router, http.MethodGet, "/admin/reports", "", creator).Code)
+	_, moderator := issueTestKey(t, domain.DefaultTenant, "mod", domain.ScopeModerate)
+
+	recorder = performRequest(router, http.MethodGet, "/admin/reports", "", moderator)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var queue struct {
+		ReportedURLs []domain.ReportedURL `json:"reported_urls"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &queue))
+	require.Len(t, queue.ReportedURLs, 2)
+	assert.Equal(t, "one", queue.ReportedURLs[0].ShortID)
+	assert.Equal(t, int64(2), queue.ReportedURLs[0].ReportCount)
+	assert.Equal(t, []string{"phishing", "spam"}, queue.ReportedURLs[0].Reasons)
+	require.NotNil(t, queue.ReportedURLs[0].URL)
+	assert.Equal(t, "https://example.com/one", queue.ReportedURLs[0].URL.OriginalURL)
+	assert.Equal(t, "two", queue.ReportedURLs[1].ShortID)
+
+	recorder = performRequest(router, http.MethodGet, "/admin/reports?limit=1", "", moderator)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &queue))
+	assert.Len(t, queue.ReportedURLs, 1)
+
+	// Moderators of other tenants see neither the queue nor the links of this one
+	_, otherModerator := issueTestKey(t, "acme", "mod", domain.ScopeModerate)
+	assert.Equal(t, http.StatusForbidden, performRequest(router, http.MethodGet, "/admin/reports?tenant=default", "", otherModerator).Code)
+	recorder = performRequest(router, http.MethodGet, "/admin/reports", "", otherModerator)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &queue))
+	assert.Empty(t, queue.ReportedURLs)
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodPost, "/admin/reports/one", `{"action":"dismiss"}`, otherModerator).Code)
+}
+
+// Test dismissing reports and disabling a reported link
+func TestModerateURL(t *testing.T) {
+	_, redisServer := setupShortenerService(t)
+	router := newReportRouter(t, "bootstrap-secret")
+	admin := map[string]string{"X-API-Key": "bootstrap-secret"}
+	require.Equal(t, http.StatusOK, performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.com/","alias":"promo"}`, admin).Code)
+	require.Equal(t, http.StatusCreated, performRequest(router, http.MethodPost, "/report/promo", `{"reason":"spam"}`, nil).Code)
+
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, http.MethodPost, "/admin/reports/promo", `{"action":"delete"}`, admin).Code)
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodPost, "/admin/reports/missing", `{"action":"dismiss"}`, admin).Code)
+
+	recorder := performRequest(router, http.MethodPost, "/admin/reports/promo", `{"action":"dismiss"}`, admin)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var result service.ModerationResult
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+	assert.Equal(t, int64(1), result.ClosedReports)
+	reportedURLs, err := service.ListReportedURLs(domain.DefaultTenant, "", 0)
+	require.NoError(t, err)
+	assert.Empty(t, reportedURLs, "dismissed reports leave the queue")
+
+	// Disabling evicts the cached link, like the state endpoint
+	assert.Equal(t, http.StatusFound, performRequest(router, http.MethodGet, "/promo", "", nil).Code)
+	require.True(t, redisServer.Exists(defaultLinkKey("promo")))
+	require.Equal(t, http.StatusCreated, performRequest(router, http.MethodPost, "/report/promo", `{"reason":"spam"}`, nil).Code)
+	recorder = performRequest(router, http.MethodPost, "/admin/reports/promo", `{"action":"disable"}`, admin)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+	assert.Equal(t, int64(1), result.ClosedReports)
+	assert.False(t, redisServer.Exists(defaultLinkKey("promo")))
+	assert.NotEqual(t, http.StatusFound, performRequest(router, http.MethodGet, "/promo", "", nil).Code)
+
+	// Only a moderator can enable the link again
+	assert.Equal(t, http.StatusForbidden, performRequest(router, http.MethodPatch, "/promo", `{"enabled":true}`, admin).Code)
+	_, err = service.SetURLState("promo", true, domain.DefaultTenant, "tester")
+	assert.Error(t, err)
+	assert.NotEqual(t, http.StatusFound, performRequest(router, http.MethodGet, "/promo", "", nil).Code)
+	require.Equal(t, http.StatusOK, performRequest(router, http.MethodPost, "/admin/reports/promo", `{"action":"enable"}`, admin).Code)
+	assert.Equal(t, http.StatusFound, performRequest(router, http.MethodGet, "/promo", "", nil).Code)
+
+	// Links their owner already disabled are locked too
+	require.Equal(t, http.StatusOK, performRequest(router, http.MethodPatch, "/promo", `{"enabled":false}`, admin).Code)
+	require.Equal(t, http.StatusOK, performRequest(router, http.MethodPost, "/admin/reports/promo", `{"action":"disable"}`, admin).Code)
+	assert.Equal(t, http.StatusForbidden, performRequest(router, http.MethodPatch, "/promo", `{"enabled":true}`, admin).Code)
+}
+
+// Test banning the destination domain of a reported link and lifting the ban
+func TestBanDomain(t *testing.T) {
+	setupShortenerService(t)
+	router := newReportRouter(t, "bootstrap-secret")
+	admin := map[string]string{"X-API-Key": "bootstrap-secret"}
+	for alias, destination := range map[string]string{"bad": "https://evil.example/login", "sibling": "https://www.evil.example/"} {
+		body := `{"original_url":"` + destination + `","alias":"` + alias + `"}`
+		require.Equal(t, http.StatusOK, performRequest(router, http.MethodPost, "/shorten", body, admin).Code)
+	}
+	require.Equal(t, http.StatusCreated, performRequest(router, http.MethodPost, "/report/bad", `{"reason":"phishing"}`, nil).Code)
+
+	// Only the default tenant bans domains, since bans apply to every tenant
+	_, acmeModerator := issueTestKey(t, "acme", "mod", domain.ScopeModerate, domain.ScopeCreate)
+	require.Equal(t, http.StatusOK, performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://evil.example/acme","alias":"acme-bad"}`, acmeModerator).Code)
+	assert.Equal(t, http.StatusForbidden, performRequest(router, http.MethodPost, "/admin/reports/acme-bad", `{"action":"ban_domain"}`, acmeModerator).Code)
+
+	recorder := performRequest(router, http.MethodPost, "/admin/reports/bad", `{"action":"ban_domain"}`, admin)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	var result service.ModerationResult
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+	require.NotNil(t, result.BannedDomain)
+	assert.Equal(t, "evil.example", result.BannedDomain.Domain)
+
+	// The banned domain and its subdomains can no longer be shortened or visited
+	assert.Equal(t, http.StatusUnprocessableEntity, performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://cdn.evil.example/x"}`, admin).Code)
+	assert.Equal(t, http.StatusForbidden, performRequest(router, http.MethodGet, "/sibling", "", nil).Code)
+	_, err := service.SetURLState("bad", true, domain.DefaultTenant, "tester")
+	assert.Error(t, err, "owners cannot enable links to banned domains")
+
+	recorder = performRequest(router, http.MethodGet, "/admin/banned-domains", "", admin)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"domain":"evil.example"`)
+
+	assert.Equal(t, http.StatusForbidden, performRequest(router, http.MethodDelete, "/admin/banned-domains/evil.example", "", acmeModerator).Code)
+	assert.Equal(t, http.StatusNoContent, performRequest(router, http.MethodDelete, "/admin/banned-domains/Evil.Example", "", admin).Code)
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodDelete, "/admin/banned-domains/evil.example", "", admin).Code)
+
+	// Links quarantined on a visit go back to their owner; the reported one stays with the moderators
+	_, err = service.SetURLState("sibling", true, domain.DefaultTenant, "tester")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusFound, performRequest(router, http.MethodGet, "/sibling", "", nil).Code)
+	_, err = service.SetURLState("bad", true, domain.DefaultTenant, "tester")
+	assert.Error(t, err)
+	require.Equal(t, http.StatusOK, performRequest(router, http.MethodPost, "/admin/reports/bad", `{"action":"enable"}`, admin).Code)
+	assert.Equal(t, http.StatusFound, performRequest(router, http.MethodGet, "/bad", "", nil).Code)
+}
+
+// Test that report IDs and tenants come from the reported link
+func TestReportURLRecordsLink(t *testing.T) {
+	setupShortenerService(t)
+	reportService := repository.NewMemoryReportService()
+	service.ReportServiceInstance = reportService
+	_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/", Alias: "promo"}, "", "acme", "tester")
+	require.NoError(t, err)
+
+	report, err := service.ReportURL("promo", request.ReportRequest{Reason: "other", Details: " see screenshot "}, "192.0.2.1", "browser")
+	require.NoError(t, err)
+	assert.Len(t, report.ID, 16)
+	assert.Equal(t, "acme", report.Tenant)
+	assert.Equal(t, "see screenshot", report.Details)
+	assert.Equal(t, "192.0.2.1", report.ReporterIP)
+	assert.Equal(t, domain.ReportOpen, report.Status)
+
+	item := <-reportService.ListReportedURLs(domain.DefaultTenant, 10).Observe()
+	require.NoError(t, item.E)
+	assert.Empty(t, item.V.([]domain.ReportedURL))
+}
+
+// Test the SQL report repository
+func TestSQLReportRepository(t *testing.T) {
+	urlService := newSQLiteURLService(t)
+	reportService := &repository.SQLReportServiceImpl{DB: urlService.DB, Dialect: urlService.Dialect}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	for i, report := range []domain.Report{
+		{ShortID: "a", Reason: domain.ReportSpam},
+		{ShortID: "b", Reason: domain.ReportPhishing},
+		{ShortID: "b", Reason: domain.ReportSpam},
+		{ShortID: "b", Reason: domain.ReportSpam},
+		{ShortID: "c", Reason: domain.ReportSpam, Tenant: "acme"},
+	} {
+		report.ID = string(rune('0' + i))
+		if report.Tenant == "" {
+			report.Tenant = domain.DefaultTenant
+		}
+		report.CreatedAt = now.Add(time.Duration(i) * time.Minute)
+		report.Status = domain.ReportOpen
+		item := <-reportService.SaveReport(report).Observe()
+		require.NoError(t, item.E)
+	}
+
+	item := <-reportService.ListReportedURLs(domain.DefaultTenant, 10).Observe()
+	require.NoError(t, item.E)
+	reportedURLs := item.V.([]domain.ReportedURL)
+	require.Len(t, reportedURLs, 2)
+	assert.Equal(t, domain.ReportedURL{ShortID: "b", ReportCount: 3, Reasons: []string{"phishing", "spam"}, LastReportedAt: now.Add(3 * time.Minute)}, reportedURLs[0])
+	assert.Equal(t, "a", reportedURLs[1].ShortID)
+
+	item = <-reportService.CloseReports("b", domain.ReportDismissed, "mod", now).Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, int64(3), item.V.(int64))
+	item = <-reportService.ListReportedURLs(domain.DefaultTenant, 10).Observe()
+	require.NoError(t, item.E)
+	assert.Len(t, item.V.([]domain.ReportedURL), 1)
+
+	ban := domain.BannedDomain{Domain: "evil.example", BannedAt: now, BannedBy: "mod"}
+	item = <-reportService.SaveBannedDomain(ban).Observe()
+	require.NoError(t, item.E)
+	item = <-reportService.SaveBannedDomain(domain.BannedDomain{Domain: "evil.example", BannedAt: now.Add(time.Hour), BannedBy: "other"}).Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, ban, item.V.(domain.BannedDomain), "banning again keeps the original ban")
+
+	item = <-reportService.ListBannedDomains().Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, []domain.BannedDomain{ban}, item.V.([]domain.BannedDomain))
+	item = <-reportService.DeleteBannedDomain("evil.example").Observe()
+	require.NoError(t, item.E)
+	item = <-reportService.DeleteBannedDomain("evil.example").Observe()
+	assert.ErrorIs(t, item.E, repository.ErrBannedDomainNotFound)
+}
+
+// Test that purging a link closes its reports, so a new link with the same ID starts clean
+func TestPurgeURLClosesReports(t *testing.T) {
+	setupShortenerService(t)
+	router := newReportRouter(t, "bootstrap-secret")
+	admin := map[string]string{"X-API-Key": "bootstrap-secret"}
+	require.Equal(t, http.StatusOK, performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.com/","alias":"promo"}`, admin).Code)
+	require.Equal(t, http.StatusCreated, performRequest(router, http.MethodPost, "/report/promo", `{"reason":"spam"}`, nil).Code)
+
+	require.Equal(t, http.StatusNoContent, performRequest(router, http.MethodDelete, "/urls/promo?permanent=true", "", admin).Code)
+	reportedURLs, err := service.ListReportedURLs(domain.DefaultTenant, "", 0)
+	require.NoError(t, err)
+	assert.Empty(t, reportedURLs)
+
+	require.Equal(t, http.StatusOK, performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.org/","alias":"promo"}`, admin).Code)
+	reportedURLs, err = service.ListReportedURLs(domain.DefaultTenant, "", 0)
+	require.NoError(t, err)
+	assert.Empty(t, reportedURLs, "the new link does not inherit the reports")
+	recorder := performRequest(router, http.MethodPost, "/admin/reports/promo", `{"action":"dismiss"}`, admin)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var result service.ModerationResult
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+	assert.Zero(t, result.ClosedReports)
+}
@@ -42,6 +42,21 @@ func (m *MockCollection) UpdateOne(ctx context.Context, filter interface{}, upda
 	return nil, args.Error(1)
 }
 
+func (m *MockCollection) DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*mongo.DeleteResult), args.Error(1)
+}
+
+func (m *MockCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*mongo.Cursor), args.Error(1)
+}
+
+func (m *MockCollection) CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 // Test for SaveURL method
 func TestSaveURL(t *testing.T) {
 	mockCollection := new(MockCollection)
@@ -75,7 +90,7 @@ func TestGetURL(t *testing.T) {
 	// Simulate FindOne returning the single result
 	mockCollection.On("FindOne", mock.Anything, mock.Anything).Return(singleResult)
 
-	observable := urlService.GetURL("testID")
+	observable := urlService.GetURL(domain.DefaultTenant, "testID")
 	item := <-observable.Observe()
 
 	assert.NoError(t, item.E)
@@ -99,3 +114,20 @@ func TestUpdateURL(t *testing.T) {
 	assert.Equal(t, testURL, item.V.(domain.URL))
 	mockCollection.AssertExpectations(t)
 }
+
+// Test for DeleteURL method
+func TestDeleteURL(t *testing.T) {
+	mockCollection := new(MockCollection)
+	urlService := &repository.URLServiceImpl{UrlCollection: mockCollection}
+
+	// Simulate DeleteOne removing one document, then none
+	mockCollection.On("DeleteOne", mock.Anything, bson.M{"id": "testID", "tenant": domain.DefaultTenant}).Return(&mongo.DeleteResult{DeletedCount: 1}, nil)
+	mockCollection.On("DeleteOne", mock.Anything, bson.M{"id": "missing", "tenant": domain.DefaultTenant}).Return(&mongo.DeleteResult{DeletedCount: 0}, nil)
+
+	item := <-urlService.DeleteURL(domain.DefaultTenant, "testID").Observe()
+	assert.NoError(t, item.E)
+
+	item = <-urlService.DeleteURL(domain.DefaultTenant, "missing").Observe()
+	assert.ErrorIs(t, item.E, repository.ErrURLNotFound)
+	mockCollection.AssertExpectations(t)
+}
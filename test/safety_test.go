@@ -0,0 +1,200 @@
+package test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/models"
+	"urlshortener/internal/request"
+	"urlshortener/internal/safety"
+	"urlshortener/internal/service"
+)
+
+// threatPrefix returns the hex hash prefix of size bytes listing expression
+func threatPrefix(expression string, size int) string {
+	digest := sha256.Sum256([]byte(expression))
+	return hex.EncodeToString(digest[:size])
+}
+
+// writeThreatList writes a threat list file listing the given expressions
+func writeThreatList(t *testing.T, path string, expressions ...string) {
+	lines := []string{"# test threat list", ""}
+	for _, expression := range expressions {
+		lines = append(lines, threatPrefix(expression, 4))
+	}
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600))
+}
+
+// useDestinationChecker installs checker for the duration of the test
+func useDestinationChecker(t *testing.T, checker *safety.Checker) {
+	service.DestinationCheckerInstance = checker
+	t.Cleanup(func() { service.DestinationCheckerInstance = nil })
+}
+
+// Test the host suffix and path prefix expressions looked up in the threat list
+func TestThreatListExpressions(t *testing.T) {
+	parsed, err := url.Parse("http://a.b.c.d.e.f.g/1/2/3/4/5.html?param=1")
+	require.NoError(t, err)
+	expressions := safety.Expressions(parsed)
+	assert.Len(t, expressions, 5*6)
+	assert.Equal(t, "a.b.c.d.e.f.g/1/2/3/4/5.html?param=1", expressions[0])
+	assert.Contains(t, expressions, "c.d.e.f.g/1/2/3/")
+	assert.Contains(t, expressions, "f.g/")
+	assert.NotContains(t, expressions, "g/")
+	assert.NotContains(t, expressions, "b.c.d.e.f.g/")
+
+	parsed, err = url.Parse("https://192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"192.0.2.1/"}, safety.Expressions(parsed))
+}
+
+// Test blocklist, allowlist and threat list checks and reloading the threat list
+func TestDestinationChecker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "threats.txt")
+	writeThreatList(t, path, "evil.example.net/", "example.org/login/")
+
+	checker, err := safety.NewChecker(safety.Config{
+		Blocklist:      []string{"*.Spam.example", " ", "bücher.example"},
+		ThreatListPath: path,
+	})
+	require.NoError(t, err)
+
+	for rawURL, blocked := range map[string]bool{
+		"https://example.com/":                 false,
+		"https://spam.example/":                true,
+		"https://www.spam.example/x":           true,
+		"https://notspam.example/":             false,
+		"https://xn--bcher-kva.example/":       true,
+		"https://evil.example.net/any/path":    true,
+		"https://login.evil.example.net/":      true,
+		"https://example.net/":                 false,
+		"https://example.org/login/reset?x=1":  true,
+		"https://www.example.org/login/":       true,
+		"https://example.org/account/login/":   false,
+		"https://example.org/":                 false,
+		"https://evil.example.net:8443/secure": true,
+	} {
+		reason, isBlocked := checker.Check(rawURL)
+		assert.Equal(t, blocked, isBlocked, rawURL)
+		assert.Equal(t, blocked, reason != "", rawURL)
+	}
+
+	// The new list replaces the old one; a broken file keeps the previous list
+	writeThreatList(t, path, "example.com/")
+	require.NoError(t, checker.Reload())
+	_, blocked := checker.Check("https://example.com/")
+	assert.True(t, blocked)
+	_, blocked = checker.Check("https://evil.example.net/")
+	assert.False(t, blocked)
+
+	require.NoError(t, os.WriteFile(path, []byte("not-hex\n"), 0o600))
+	assert.Error(t, checker.Reload())
+	_, blocked = checker.Check("https://example.com/")
+	assert.True(t, blocked)
+
+	// Changes are picked up in the background
+	writeThreatList(t, path)
+	checker.StartReload(10 * time.Millisecond)
+	assert.Eventually(t, func() bool {
+		_, blocked := checker.Check("https://example.com/")
+		return !blocked
+	}, time.Second, 10*time.Millisecond)
+
+	allowlisted, err := safety.NewChecker(safety.Config{Allowlist: []string{"example.com"}})
+	require.NoError(t, err)
+	_, blocked = allowlisted.Check("https://docs.example.com/")
+	assert.False(t, blocked)
+	reason, blocked := allowlisted.Check("https://example.org/")
+	assert.True(t, blocked)
+	assert.Contains(t, reason, "example.org")
+
+	_, err = safety.NewChecker(safety.Config{ThreatListPath: filepath.Join(t.TempDir(), "missing.txt")})
+	assert.Error(t, err)
+	require.NoError(t, os.WriteFile(path, []byte("abcd\n"), 0o600))
+	_, err = safety.NewChecker(safety.Config{ThreatListPath: path})
+	assert.Error(t, err, "prefixes shorter than 4 bytes are rejected")
+}
+
+// Test that unsafe destinations are rejected on creation and update with the reason
+func TestCreateShortURLRejectsUnsafeDestination(t *testing.T) {
+	setupShortenerService(t)
+	checker, err := safety.NewChecker(safety.Config{Blocklist: []string{"spam.example"}})
+	require.NoError(t, err)
+	useDestinationChecker(t, checker)
+
+	_, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://WWW.Spam.example/offer"}, "", domain.DefaultTenant, "tester")
+	var apiErr *models.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnprocessableEntity, apiErr.Code)
+	assert.Contains(t, apiErr.Message, "spam.example is blocked")
+
+	_, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/", Alias: "safe"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	unsafe := "https://spam.example/"
+	_, err = service.UpdateShortURL("safe", request.UpdateURLRequest{OriginalURL: &unsafe}, domain.DefaultTenant, "tester")
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnprocessableEntity, apiErr.Code)
+}
+
+// Test that links whose destination becomes unsafe are quarantined on redirect
+func TestQuarantineOnRedirect(t *testing.T) {
+	urlService, redisServer := setupShortenerService(t)
+	path := filepath.Join(t.TempDir(), "threats.txt")
+	writeThreatList(t, path)
+	checker, err := safety.NewChecker(safety.Config{ThreatListPath: path})
+	require.NoError(t, err)
+	useDestinationChecker(t, checker)
+
+	_, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/login", Alias: "promo"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	require.True(t, redisServer.Exists(defaultLinkKey("promo")))
+	_, err = service.ResolveURL("promo")
+	require.NoError(t, err)
+
+	// The destination is listed after the link was cached
+	writeThreatList(t, path, "example.com/")
+	require.NoError(t, checker.Reload())
+	_, err = service.ResolveURL("promo")
+	var apiErr *models.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusForbidden, apiErr.Code)
+	assert.False(t, redisServer.Exists(defaultLinkKey("promo")))
+
+	item := <-urlService.GetURL(domain.DefaultTenant, "promo").Observe()
+	require.NoError(t, item.E)
+	stored := item.V.(domain.URL)
+	assert.False(t, stored.Enabled)
+	assert.Equal(t, "destination is listed as phishing or malware", stored.Quarantine)
+	assert.Equal(t, service.SystemActor, stored.UpdatedBy)
+
+	// Later redirects are refused without touching the cache
+	_, err = service.ResolveURL("promo")
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusForbidden, apiErr.Code)
+
+	// The owner cannot enable it while the destination is still listed
+	_, err = service.SetURLState("promo", true, domain.DefaultTenant, "tester")
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnprocessableEntity, apiErr.Code)
+
+	writeThreatList(t, path)
+	require.NoError(t, checker.Reload())
+	_, err = service.SetURLState("promo", true, domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	originalURL, err := service.ResolveURL("promo")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/login", originalURL)
+	item = <-urlService.GetURL(domain.DefaultTenant, "promo").Observe()
+	require.NoError(t, item.E)
+	assert.Empty(t, item.V.(domain.URL).Quarantine)
+}
@@ -0,0 +1,125 @@
+package test
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/models"
+	"urlshortener/internal/request"
+	"urlshortener/internal/service"
+)
+
+// setShortLinkPolicy applies policy for the duration of the test
+func setShortLinkPolicy(t *testing.T, policy service.ShortLinkPolicy) {
+	require.NoError(t, service.SetShortLinkPolicy(policy))
+	t.Cleanup(func() { _ = service.SetShortLinkPolicy(service.ShortLinkPolicy{ResolveOwnLinks: true}) })
+}
+
+// requireUnprocessable asserts that err is a 422 API error
+func requireUnprocessable(t *testing.T, err error, context string) {
+	var apiErr *models.APIError
+	require.True(t, errors.As(err, &apiErr), context)
+	assert.Equal(t, http.StatusUnprocessableEntity, apiErr.Code, context)
+}
+
+// Test that shortening one of our own links points the new link at the final destination
+func TestCreateShortURLResolvesOwnLinks(t *testing.T) {
+	urlService, _ := setupShortenerService(t)
+	setShortLinkPolicy(t, service.ShortLinkPolicy{Domains: []string{"Sho.rt", "go.example.com:8443"}, ResolveOwnLinks: true})
+
+	_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/final", Alias: "final"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+
+	// Each caller gets its own link, since every one of them ends up at the same destination
+	for i, own := range []string{
+		"http://localhost:8080/final",
+		"https://sho.rt/final+",
+		"https://go.example.com:8443/l/final",
+		"https://forwarded.example/final",
+	} {
+		alias := []string{"chain-a", "chain-b", "chain-c", "chain-d"}[i]
+		_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: own, Alias: alias}, "https://forwarded.example", domain.DefaultTenant, alias)
+		require.NoError(t, err, own)
+		item := <-urlService.GetURL(domain.DefaultTenant, alias).Observe()
+		require.NoError(t, item.E)
+		assert.Equal(t, "https://example.com/final", item.V.(domain.URL).OriginalURL, own)
+	}
+
+	// Other ports of the same host and other hosts are left alone
+	shortURL, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "http://localhost:3000/final"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	originalURL, err := service.ResolveURL(shortIDOf(shortURL))
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:3000/final", originalURL)
+
+	// Links whose limits or warning the copy would drop are rejected
+	expiresAt := time.Now().Add(time.Hour)
+	for _, req := range []request.ShortenRequest{
+		{OriginalURL: "https://example.com/once", Alias: "once", MaxClicks: 1},
+		{OriginalURL: "https://example.com/soon", Alias: "soon", ExpiresAt: &expiresAt},
+		{OriginalURL: "https://example.com/flagged", Alias: "flagged", Warn: true},
+	} {
+		_, err := service.CreateShortURL(req, "", domain.DefaultTenant, "tester")
+		require.NoError(t, err)
+		_, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://sho.rt/" + req.Alias}, "", domain.DefaultTenant, "other")
+		requireUnprocessable(t, err, req.Alias)
+	}
+
+	// Our own paths that are not live links are rejected
+	_, err = service.SetURLState("final", false, domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	for _, invalid := range []string{"https://sho.rt/final", "https://sho.rt/missing", "https://sho.rt/", "https://sho.rt/self"} {
+		_, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: invalid, Alias: "self"}, "", domain.DefaultTenant, "other")
+		requireUnprocessable(t, err, invalid)
+	}
+}
+
+// Test that updates cannot close a redirect loop, including loops left by older links
+func TestUpdateShortURLRefusesLoops(t *testing.T) {
+	urlService, _ := setupShortenerService(t)
+
+	// Links stored before loops were refused may already chain
+	require.NoError(t, (<-urlService.SaveURL(domain.URL{ID: "loop-a", OriginalURL: "http://localhost:8080/loop-b", Enabled: true, Tenant: domain.DefaultTenant}).Observe()).E)
+	require.NoError(t, (<-urlService.SaveURL(domain.URL{ID: "loop-b", OriginalURL: "http://localhost:8080/loop-a", Enabled: true, Tenant: domain.DefaultTenant, Owner: "other"}).Observe()).E)
+	_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "http://localhost:8080/loop-a"}, "", domain.DefaultTenant, "tester")
+	requireUnprocessable(t, err, "existing loop")
+
+	_, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/", Alias: "docs"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	self := "http://localhost:8080/docs"
+	_, err = service.UpdateShortURL("docs", request.UpdateURLRequest{OriginalURL: &self}, domain.DefaultTenant, "tester")
+	requireUnprocessable(t, err, "self reference")
+
+	// Fixing one link of the loop through the other resolves to the final destination
+	viaDocs := "http://localhost:8080/docs"
+	url, err := service.UpdateShortURL("loop-a", request.UpdateURLRequest{OriginalURL: &viaDocs}, domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/", url.OriginalURL)
+	backToA := "http://localhost:8080/loop-a"
+	_, err = service.UpdateShortURL("loop-b", request.UpdateURLRequest{OriginalURL: &backToA}, domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+}
+
+// Test the options rejecting our own links and third-party shorteners
+func TestShortLinkPolicyRejections(t *testing.T) {
+	setupShortenerService(t)
+	_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/final", Alias: "final"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+
+	_, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://bit.ly/abc"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err, "third-party shorteners are accepted by default")
+
+	setShortLinkPolicy(t, service.ShortLinkPolicy{RejectShorteners: true})
+	for _, rejected := range []string{"http://localhost:8080/final", "https://BIT.ly/xyz", "https://www.tinyurl.com/abc"} {
+		_, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: rejected}, "", domain.DefaultTenant, "tester")
+		requireUnprocessable(t, err, rejected)
+	}
+	_, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://notbit.ly/abc"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+
+	assert.Error(t, service.SetShortLinkPolicy(service.ShortLinkPolicy{Domains: []string{"bad host"}}))
+}
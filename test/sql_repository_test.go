@@ -0,0 +1,192 @@
+package test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/repository"
+	"urlshortener/internal/storage"
+)
+
+// newSQLiteURLService connects to a fresh in-memory SQLite database and migrates it
+func newSQLiteURLService(t *testing.T) *repository.SQLURLServiceImpl {
+	sqlClient := &storage.SQLService{}
+	item := <-sqlClient.Connect(storage.DriverSQLite, ":memory:").Observe()
+	require.NoError(t, item.E)
+	t.Cleanup(func() { <-sqlClient.Disconnect().Observe() })
+
+	urlService := &repository.SQLURLServiceImpl{}
+	require.NoError(t, urlService.InitDatabase(sqlClient.GetDB(), storage.DriverSQLite))
+	return urlService
+}
+
+// Test that a saved URL can be read back by ID and by original URL
+func TestSQLSaveAndGetURL(t *testing.T) {
+	urlService := newSQLiteURLService(t)
+	testURL := domain.URL{ID: "testID", OriginalURL: "https://example.com", ShortURL: "http://localhost/testID", Enabled: true}
+
+	item := <-urlService.SaveURL(testURL).Observe()
+	assert.NoError(t, item.E)
+
+	item = <-urlService.GetURL("", "testID").Observe()
+	assert.NoError(t, item.E)
+	assert.Equal(t, testURL, item.V.(domain.URL))
+
+	item = <-urlService.FindURLByOriginal("https://example.com", "", "").Observe()
+	assert.NoError(t, item.E)
+	assert.Equal(t, testURL, item.V.(domain.URL))
+
+	item = <-urlService.GetURL("", "missing").Observe()
+	assert.ErrorIs(t, item.E, repository.ErrURLNotFound)
+}
+
+// Test that every lookup and change is scoped to the tenant of the URL
+func TestSQLTenantScoping(t *testing.T) {
+	urlService := newSQLiteURLService(t)
+	<-urlService.SaveURL(domain.URL{ID: "launch", OriginalURL: "https://example.com", Tenant: "marketing", Enabled: true}).Observe()
+
+	item := <-urlService.GetURLTenant("launch").Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, "marketing", item.V.(string))
+	item = <-urlService.GetURLTenant("missing").Observe()
+	assert.ErrorIs(t, item.E, repository.ErrURLNotFound)
+
+	item = <-urlService.GetURL("sales", "launch").Observe()
+	assert.ErrorIs(t, item.E, repository.ErrURLNotFound)
+	<-urlService.UpdateURL(domain.URL{ID: "launch", OriginalURL: "https://evil.example.com", Tenant: "sales"}).Observe()
+	<-urlService.UpdateClickCount("sales", "launch", 10).Observe()
+	item = <-urlService.DeleteURL("sales", "launch").Observe()
+	assert.ErrorIs(t, item.E, repository.ErrURLNotFound)
+
+	item = <-urlService.GetURL("marketing", "launch").Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, "https://example.com", item.V.(domain.URL).OriginalURL)
+	assert.True(t, item.V.(domain.URL).Enabled)
+	assert.Zero(t, item.V.(domain.URL).ClickCount)
+}
+
+// Test the unique constraints on the short ID and on the original URL of each owner
+func TestSQLUniqueConstraints(t *testing.T) {
+	urlService := newSQLiteURLService(t)
+	<-urlService.SaveURL(domain.URL{ID: "testID", OriginalURL: "https://example.com"}).Observe()
+
+	item := <-urlService.SaveURL(domain.URL{ID: "testID", OriginalURL: "https://other.example.com"}).Observe()
+	assert.ErrorIs(t, item.E, repository.ErrDuplicateURL)
+
+	item = <-urlService.SaveURL(domain.URL{ID: "otherID", OriginalURL: "https://example.com"}).Observe()
+	assert.ErrorIs(t, item.E, repository.ErrDuplicateURL)
+
+	item = <-urlService.SaveURL(domain.URL{ID: "aliceID", OriginalURL: "https://example.com", Owner: "alice"}).Observe()
+	require.NoError(t, item.E)
+	item = <-urlService.FindURLByOriginal("https://example.com", "", "alice").Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, "aliceID", item.V.(domain.URL).ID)
+	item = <-urlService.FindURLByOriginal("https://example.com", "", "").Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, "testID", item.V.(domain.URL).ID)
+}
+
+// Test that UpdateURL persists the new state and destination
+func TestSQLUpdateURL(t *testing.T) {
+	urlService := newSQLiteURLService(t)
+	<-urlService.SaveURL(domain.URL{ID: "testID", OriginalURL: "https://example.com", Enabled: true}).Observe()
+
+	item := <-urlService.UpdateURL(domain.URL{ID: "testID", OriginalURL: "https://example.org", Enabled: false, Quarantine: "blocked", Warning: "flagged", PasswordHash: "hash"}).Observe()
+	assert.NoError(t, item.E)
+
+	item = <-urlService.GetURL("", "testID").Observe()
+	assert.NoError(t, item.E)
+	assert.Equal(t, "https://example.org", item.V.(domain.URL).OriginalURL)
+	assert.False(t, item.V.(domain.URL).Enabled)
+	assert.Equal(t, "blocked", item.V.(domain.URL).Quarantine)
+	assert.Equal(t, "flagged", item.V.(domain.URL).Warning)
+	assert.Equal(t, "hash", item.V.(domain.URL).PasswordHash)
+}
+
+// Test that running the migrations twice is a no-op
+func TestSQLMigrationsAreIdempotent(t *testing.T) {
+	urlService := newSQLiteURLService(t)
+	assert.NoError(t, urlService.InitDatabase(urlService.DB, storage.DriverSQLite))
+}
+
+// Test that expiration times survive a round trip and expired rows are swept
+func TestSQLDeleteExpiredURLs(t *testing.T) {
+	urlService := newSQLiteURLService(t)
+	past := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	future := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	<-urlService.SaveURL(domain.URL{ID: "expired", OriginalURL: "https://example.com/old", ExpiresAt: &past}).Observe()
+	<-urlService.SaveURL(domain.URL{ID: "alive", OriginalURL: "https://example.com/new", ExpiresAt: &future}).Observe()
+	<-urlService.SaveURL(domain.URL{ID: "forever", OriginalURL: "https://example.com/forever"}).Observe()
+
+	item := <-urlService.GetURL("", "alive").Observe()
+	require.NoError(t, item.E)
+	assert.True(t, future.Equal(*item.V.(domain.URL).ExpiresAt))
+
+	item = <-urlService.DeleteExpiredURLs(time.Now()).Observe()
+	require.NoError(t, item.E)
+	require.Len(t, item.V.([]domain.URL), 1)
+	assert.Equal(t, "expired", item.V.([]domain.URL)[0].ID)
+
+	item = <-urlService.GetURL("", "expired").Observe()
+	assert.ErrorIs(t, item.E, repository.ErrURLNotFound)
+	item = <-urlService.GetURL("", "forever").Observe()
+	assert.NoError(t, item.E)
+}
+
+// Test soft delete bookkeeping and permanent deletion
+func TestSQLDeleteURL(t *testing.T) {
+	urlService := newSQLiteURLService(t)
+	deletedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	<-urlService.SaveURL(domain.URL{ID: "trashed", OriginalURL: "https://example.com/a"}).Observe()
+	<-urlService.SaveURL(domain.URL{ID: "live", OriginalURL: "https://example.com/b"}).Observe()
+	<-urlService.UpdateURL(domain.URL{ID: "trashed", OriginalURL: "https://example.com/a", DeletedAt: &deletedAt}).Observe()
+
+	item := <-urlService.FindDeletedURLs(time.Now()).Observe()
+	require.NoError(t, item.E)
+	require.Len(t, item.V.([]domain.URL), 1)
+	assert.Equal(t, "trashed", item.V.([]domain.URL)[0].ID)
+
+	item = <-urlService.DeleteURL("", "trashed").Observe()
+	assert.NoError(t, item.E)
+	item = <-urlService.DeleteURL("", "trashed").Observe()
+	assert.ErrorIs(t, item.E, repository.ErrURLNotFound)
+}
+
+// Test that rows stored before the listing and audit columns existed are backfilled
+func TestSQLMigrationBackfill(t *testing.T) {
+	sqlClient := &storage.SQLService{}
+	item := <-sqlClient.Connect(storage.DriverSQLite, ":memory:").Observe()
+	require.NoError(t, item.E)
+	t.Cleanup(func() { <-sqlClient.Disconnect().Observe() })
+
+	// Recreate the schema as it was at version 4 with one legacy row
+	db := sqlClient.GetDB()
+	for _, stmt := range []string{
+		`CREATE TABLE urls (id TEXT NOT NULL PRIMARY KEY, original_url TEXT NOT NULL, short_url TEXT NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT TRUE, expires_at TIMESTAMP NULL, max_clicks BIGINT NOT NULL DEFAULT 0,
+			deleted_at TIMESTAMP NULL)`,
+		`CREATE UNIQUE INDEX urls_original_url_idx ON urls (original_url)`,
+		`CREATE INDEX urls_expires_at_idx ON urls (expires_at)`,
+		`CREATE INDEX urls_deleted_at_idx ON urls (deleted_at)`,
+		`CREATE TABLE schema_migrations (version INTEGER NOT NULL PRIMARY KEY, applied_at TIMESTAMP NOT NULL)`,
+		`INSERT INTO schema_migrations (version, applied_at) VALUES (1, '2024-01-01'), (2, '2024-01-01'), (3, '2024-01-01'), (4, '2024-01-01')`,
+		`INSERT INTO urls (id, original_url, short_url) VALUES ('legacy', 'https://Old.Example.com:8443/page', 'http://localhost/legacy')`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err)
+	}
+
+	urlService := &repository.SQLURLServiceImpl{}
+	require.NoError(t, urlService.InitDatabase(db, storage.DriverSQLite))
+
+	item = <-urlService.GetURL(domain.DefaultTenant, "legacy").Observe()
+	require.NoError(t, item.E)
+	url := item.V.(domain.URL)
+	assert.Equal(t, "old.example.com", url.Domain)
+	assert.WithinDuration(t, time.Now(), url.CreatedAt, time.Minute)
+	assert.Equal(t, url.CreatedAt, url.UpdatedAt)
+	assert.Empty(t, url.CreatedBy)
+	assert.Equal(t, domain.DefaultTenant, url.Tenant)
+}
@@ -0,0 +1,200 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/models"
+	"urlshortener/internal/request"
+	"urlshortener/internal/service"
+)
+
+// Test that tenants neither see nor change each other's links and API keys
+func TestTenantIsolation(t *testing.T) {
+	urlService, redisServer := setupShortenerService(t)
+	router := newAuthRouter(t, "bootstrap-secret", nil)
+	_, marketing := issueTestKey(t, "marketing", "marketing", domain.ScopeAdmin)
+	_, sales := issueTestKey(t, "sales", "sales", domain.ScopeAdmin)
+	bootstrap := map[string]string{"X-API-Key": "bootstrap-secret"}
+
+	require.Equal(t, http.StatusOK, performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.com","alias":"launch"}`, marketing).Code)
+	item := <-urlService.GetURL("marketing", "launch").Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, "marketing", item.V.(domain.URL).Tenant)
+
+	// Even admins of another tenant get 404, as if the link did not exist
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodGet, "/urls/launch", "", sales).Code)
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodPatch, "/launch", `{"enabled":false}`, sales).Code)
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodGet, "/stats/launch", "", sales).Code)
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodGet, "/urls/launch", "", bootstrap).Code)
+	assert.Equal(t, http.StatusOK, performRequest(router, http.MethodGet, "/urls/launch", "", marketing).Code)
+
+	// Redirects are public and shared by every tenant
+	assert.Equal(t, http.StatusFound, performRequest(router, http.MethodGet, "/launch", "", nil).Code)
+
+	// The cache and statistics of the link live in the namespace of its tenant
+	assert.True(t, redisServer.Exists("tenant:marketing:url:launch"))
+	assert.True(t, redisServer.Exists("tenant:marketing:url:launch:access_count"))
+	assert.False(t, redisServer.Exists("launch:access_count"))
+	route, err := redisServer.Get("link:launch")
+	require.NoError(t, err)
+	assert.Equal(t, "marketing", route)
+
+	// The same destination gets an independent link in each tenant
+	require.Equal(t, http.StatusOK, performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.com"}`, sales).Code)
+	var listing struct {
+		URLs  []domain.URL `json:"urls"`
+		Total int64        `json:"total"`
+	}
+	recorder := performRequest(router, http.MethodGet, "/urls", "", sales)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &listing))
+	require.Len(t, listing.URLs, 1)
+	assert.Equal(t, "sales", listing.URLs[0].Tenant)
+	assert.NotEqual(t, "launch", listing.URLs[0].ID)
+
+	// API keys are managed per tenant; only the default tenant reaches into others
+	var keys struct {
+		APIKeys []domain.APIKey `json:"api_keys"`
+	}
+	recorder = performRequest(router, http.MethodGet, "/admin/api-keys", "", sales)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &keys))
+	require.Len(t, keys.APIKeys, 1)
+	assert.Equal(t, "sales", keys.APIKeys[0].Tenant)
+	salesKeyID := keys.APIKeys[0].ID
+
+	assert.Equal(t, http.StatusForbidden, performRequest(router, http.MethodGet, "/admin/api-keys?tenant=marketing", "", sales).Code)
+	assert.Equal(t, http.StatusForbidden, performRequest(router, http.MethodPost, "/admin/api-keys", `{"name":"x","scopes":["admin"],"tenant":"marketing"}`, sales).Code)
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodDelete, "/admin/api-keys/"+salesKeyID, "", marketing).Code)
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, http.MethodPost, "/admin/api-keys", `{"name":"x","scopes":["admin"],"tenant":"Bad Tenant"}`, bootstrap).Code)
+
+	recorder = performRequest(router, http.MethodGet, "/admin/api-keys?tenant=marketing", "", bootstrap)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &keys))
+	require.Len(t, keys.APIKeys, 1)
+	assert.Equal(t, http.StatusOK, performRequest(router, http.MethodDelete, "/admin/api-keys/"+salesKeyID, "", bootstrap).Code)
+}
+
+// Test that statistics kept before keys were namespaced move into the tenant of their link
+func TestMigrateLegacyLinkKeys(t *testing.T) {
+	urlService, redisServer := setupShortenerService(t)
+	<-urlService.SaveURL(domain.URL{ID: "launch", OriginalURL: "https://example.com", Tenant: "marketing", Enabled: true}).Observe()
+	require.NoError(t, redisServer.Set("launch:access_count", "7"))
+	require.NoError(t, redisServer.Set("launch:clicks_used", "3"))
+	require.NoError(t, redisServer.Set("gone:access_count", "2"))
+	require.NoError(t, redisServer.Set("tenant:marketing:url:launch:access_count", "9"))
+
+	require.NoError(t, service.MigrateLegacyLinkKeys())
+	used, err := redisServer.Get("tenant:marketing:url:launch:clicks_used")
+	require.NoError(t, err)
+	assert.Equal(t, "3", used)
+	count, err := redisServer.Get("tenant:marketing:url:launch:access_count")
+	require.NoError(t, err)
+	assert.Equal(t, "9", count, "counters already in the namespace win")
+	for _, legacy := range []string{"launch:access_count", "launch:clicks_used", "gone:access_count"} {
+		assert.False(t, redisServer.Exists(legacy), legacy)
+	}
+
+	// The migration runs once
+	require.NoError(t, redisServer.Set("launch:clicks_used", "5"))
+	require.NoError(t, service.MigrateLegacyLinkKeys())
+	assert.True(t, redisServer.Exists("launch:clicks_used"))
+}
+
+// Test the link and daily creation quotas of a tenant
+func TestTenantQuotas(t *testing.T) {
+	_, redisServer := setupShortenerService(t)
+	require.NoError(t, service.SetTenantQuotas(domain.TenantQuota{}, `{"marketing":{"max_links":2,"max_daily_creations":3}}`))
+	t.Cleanup(func() { _ = service.SetTenantQuotas(domain.TenantQuota{}, "") })
+
+	create := func(tenant, alias string) error {
+		_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/" + alias, Alias: alias}, "", tenant, "tester")
+		return err
+	}
+	assertTooManyRequests := func(err error) {
+		var apiErr *models.APIError
+		require.True(t, errors.As(err, &apiErr), err)
+		assert.Equal(t, http.StatusTooManyRequests, apiErr.Code)
+	}
+
+	require.NoError(t, create("marketing", "one"))
+	require.NoError(t, create("marketing", "two"))
+	assertTooManyRequests(create("marketing", "three"))
+
+	// Other tenants keep the unlimited default quota
+	for _, alias := range []string{"s-a", "s-b", "s-c", "s-d"} {
+		require.NoError(t, create("sales", alias))
+	}
+
+	// Trashing a link frees room, but not the day's creations
+	_, err := service.TrashURL("one", "marketing", "tester")
+	require.NoError(t, err)
+	require.NoError(t, create("marketing", "three"))
+	_, err = service.TrashURL("two", "marketing", "tester")
+	require.NoError(t, err)
+	assertTooManyRequests(create("marketing", "four"))
+
+	// Restoring a link counts against the quota like creating one
+	_, err = service.RestoreURL("two", "marketing", "tester")
+	require.NoError(t, err)
+	_, err = service.RestoreURL("one", "marketing", "tester")
+	assertTooManyRequests(err)
+
+	// Failed creations do not count against the day
+	dailyKey := "tenant:marketing:creations:" + time.Now().UTC().Format(time.DateOnly)
+	count, err := redisServer.Get(dailyKey)
+	require.NoError(t, err)
+	assert.Equal(t, "3", count)
+	assert.True(t, redisServer.TTL(dailyKey) > 24*time.Hour)
+}
+
+// Test that concurrent creations cannot take a tenant past its link quota
+func TestTenantLinkQuotaConcurrent(t *testing.T) {
+	_, redisServer := setupShortenerService(t)
+	require.NoError(t, service.SetTenantQuotas(domain.TenantQuota{}, `{"marketing":{"max_links":3}}`))
+	t.Cleanup(func() { _ = service.SetTenantQuotas(domain.TenantQuota{}, "") })
+
+	var wg sync.WaitGroup
+	var created atomic.Int64
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			alias := "burst-" + strconv.Itoa(i)
+			if _, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/" + alias, Alias: alias}, "", "marketing", "tester"); err == nil {
+				created.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	page, _, err := service.ListURLs(request.ListURLsRequest{}, "marketing")
+	require.NoError(t, err)
+	assert.LessOrEqual(t, page.Total, int64(3))
+	assert.Equal(t, created.Load(), page.Total)
+
+	// Every reservation was given back
+	pending, err := redisServer.Get("tenant:marketing:pending_links")
+	require.NoError(t, err)
+	assert.Equal(t, "0", pending)
+}
+
+// Test that malformed quota configuration is rejected
+func TestSetTenantQuotasValidation(t *testing.T) {
+	t.Cleanup(func() { _ = service.SetTenantQuotas(domain.TenantQuota{}, "") })
+
+	assert.Error(t, service.SetTenantQuotas(domain.TenantQuota{}, `[1, 2]`))
+	assert.Error(t, service.SetTenantQuotas(domain.TenantQuota{}, `{"Marketing Team":{"max_links":1}}`))
+	assert.Error(t, service.SetTenantQuotas(domain.TenantQuota{}, `{"marketing":{"max_links":-1}}`))
+	assert.Error(t, service.SetTenantQuotas(domain.TenantQuota{MaxDailyCreations: -1}, ""))
+
+	require.NoError(t, service.SetTenantQuotas(domain.TenantQuota{MaxLinks: 10}, `{"marketing":{"max_daily_creations":5}}`))
+	assert.Equal(t, domain.TenantQuota{MaxDailyCreations: 5}, service.QuotaOf("marketing"))
+	assert.Equal(t, domain.TenantQuota{MaxLinks: 10}, service.QuotaOf("sales"))
+}
@@ -0,0 +1,162 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/handler"
+	"urlshortener/internal/interfaces"
+	"urlshortener/internal/repository"
+	"urlshortener/internal/request"
+	"urlshortener/internal/service"
+)
+
+// seedListingURLs stores five URLs created one minute apart, the last one trashed
+func seedListingURLs(t *testing.T, urlService interfaces.URLServiceInterface) time.Time {
+	base := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	seeds := []domain.URL{
+		{ID: "a1", OriginalURL: "https://Docs.example.com/Guide", Domain: "docs.example.com", Enabled: true, Tags: []string{"docs"}, Owner: "alice", ClickCount: 5},
+		{ID: "b2", OriginalURL: "https://example.com/blog/100%_real", Domain: "example.com", Enabled: false, Tags: []string{"blog", "docs"}, ClickCount: 50},
+		{ID: "c3", OriginalURL: "https://shop.example.org/cart", Domain: "shop.example.org", Enabled: true, Owner: "alice", ClickCount: 5},
+		{ID: "d4", OriginalURL: "https://example.com/guide/intro", Domain: "example.com", Enabled: true, Tags: []string{"doc_s"}},
+		{ID: "e5", OriginalURL: "https://example.com/trashed", Domain: "example.com", Enabled: true},
+	}
+	for i, url := range seeds {
+		url.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		if url.ID == "e5" {
+			deletedAt := base
+			url.DeletedAt = &deletedAt
+		}
+		item := <-urlService.SaveURL(url).Observe()
+		require.NoError(t, item.E)
+	}
+	return base
+}
+
+// listIDs runs a listing query and returns the IDs of the page
+func listIDs(t *testing.T, urlService interfaces.URLServiceInterface, query domain.URLQuery) ([]string, domain.URLPage) {
+	if query.Limit == 0 {
+		query.Limit = 10
+	}
+	item := <-urlService.ListURLs(query).Observe()
+	require.NoError(t, item.E)
+	page := item.V.(domain.URLPage)
+	ids := []string{}
+	for _, url := range page.URLs {
+		ids = append(ids, url.ID)
+	}
+	return ids, page
+}
+
+// assertURLListing checks filters, sorting and keyset pagination of a repository
+func assertURLListing(t *testing.T, urlService interfaces.URLServiceInterface) {
+	base := seedListingURLs(t, urlService)
+	enabled := true
+	from, to := base.Add(time.Minute), base.Add(3*time.Minute)
+
+	ids, page := listIDs(t, urlService, domain.URLQuery{})
+	assert.Equal(t, []string{"a1", "b2", "c3", "d4"}, ids)
+	assert.Equal(t, int64(4), page.Total)
+	assert.False(t, page.HasMore)
+
+	ids, _ = listIDs(t, urlService, domain.URLQuery{Descending: true})
+	assert.Equal(t, []string{"d4", "c3", "b2", "a1"}, ids)
+	ids, _ = listIDs(t, urlService, domain.URLQuery{Enabled: &enabled})
+	assert.Equal(t, []string{"a1", "c3", "d4"}, ids)
+	ids, _ = listIDs(t, urlService, domain.URLQuery{CreatedFrom: &from, CreatedTo: &to})
+	assert.Equal(t, []string{"b2", "c3"}, ids)
+	ids, _ = listIDs(t, urlService, domain.URLQuery{Domain: "example.com"})
+	assert.Equal(t, []string{"b2", "d4"}, ids)
+	ids, _ = listIDs(t, urlService, domain.URLQuery{Owner: "alice"})
+	assert.Equal(t, []string{"a1", "c3"}, ids)
+	ids, _ = listIDs(t, urlService, domain.URLQuery{Tag: "docs"})
+	assert.Equal(t, []string{"a1", "b2"}, ids)
+	ids, _ = listIDs(t, urlService, domain.URLQuery{Search: "GUIDE"})
+	assert.Equal(t, []string{"a1", "d4"}, ids)
+	ids, _ = listIDs(t, urlService, domain.URLQuery{Search: "%_"})
+	assert.Equal(t, []string{"b2"}, ids)
+
+	// Walk the click count order two URLs at a time; ties are broken by ID
+	query := domain.URLQuery{SortBy: domain.SortByClickCount, Descending: true, Limit: 2}
+	ids, page = listIDs(t, urlService, query)
+	assert.Equal(t, []string{"b2", "c3"}, ids)
+	assert.True(t, page.HasMore)
+	assert.Equal(t, int64(4), page.Total)
+	after := domain.CursorOf(page.URLs[1])
+	query.After = &after
+	ids, page = listIDs(t, urlService, query)
+	assert.Equal(t, []string{"a1", "d4"}, ids)
+	assert.False(t, page.HasMore)
+
+	// The stored click count only ever grows
+	<-urlService.UpdateClickCount("", "d4", 7).Observe()
+	<-urlService.UpdateClickCount("", "d4", 3).Observe()
+	item := <-urlService.GetURL("", "d4").Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, int64(7), item.V.(domain.URL).ClickCount)
+}
+
+// Test listing URLs stored in memory
+func TestMemoryListURLs(t *testing.T) {
+	assertURLListing(t, repository.NewMemoryURLService())
+}
+
+// Test listing URLs stored in SQLite
+func TestSQLListURLs(t *testing.T) {
+	assertURLListing(t, newSQLiteURLService(t))
+}
+
+// Test the GET /urls endpoint, its cursors and the click count sync
+func TestListURLsHandler(t *testing.T) {
+	setupShortenerService(t)
+	router := newShortenerRouter(handler.NewURLShortenerHandler())
+	for _, alias := range []string{"first", "second", "third"} {
+		_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/" + alias, Alias: alias, Tags: []string{"Promo"}}, "", domain.DefaultTenant, "tester")
+		require.NoError(t, err)
+		time.Sleep(2 * time.Millisecond)
+	}
+	performRequest(router, http.MethodGet, "/second", "", nil)
+	performRequest(router, http.MethodGet, "/second", "", nil)
+	performRequest(router, http.MethodGet, "/first", "", nil)
+	require.NoError(t, service.SyncClickCounts())
+
+	var body struct {
+		URLs       []domain.URL `json:"urls"`
+		Total      int64        `json:"total"`
+		NextCursor string       `json:"next_cursor"`
+	}
+	recorder := performRequest(router, http.MethodGet, "/urls?sort=-click_count&limit=2&tag=promo", "", nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	require.Len(t, body.URLs, 2)
+	assert.Equal(t, "second", body.URLs[0].ID)
+	assert.Equal(t, int64(2), body.URLs[0].ClickCount)
+	assert.Equal(t, "first", body.URLs[1].ID)
+	assert.Equal(t, int64(3), body.Total)
+	require.NotEmpty(t, body.NextCursor)
+
+	cursor := body.NextCursor
+	body.NextCursor = ""
+	recorder = performRequest(router, http.MethodGet, "/urls?sort=-click_count&limit=2&tag=promo&cursor="+cursor, "", nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	require.Len(t, body.URLs, 1)
+	assert.Equal(t, "third", body.URLs[0].ID)
+	assert.Empty(t, body.NextCursor)
+
+	// Newest first by default
+	recorder = performRequest(router, http.MethodGet, "/urls?q=EXAMPLE.COM/T", "", nil)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	require.Len(t, body.URLs, 1)
+	assert.Equal(t, "third", body.URLs[0].ID)
+
+	// Cursors are bound to their sort order
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, http.MethodGet, "/urls?sort=created_at&cursor="+cursor, "", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, http.MethodGet, "/urls?sort=clicks", "", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, http.MethodGet, "/urls?limit=1000", "", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, http.MethodGet, "/urls?created_from=yesterday", "", nil).Code)
+}
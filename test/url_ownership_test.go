@@ -0,0 +1,77 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"urlshortener/internal/domain"
+)
+
+// Test that only the owner of a link, or an admin, can manage it and read its stats
+func TestURLOwnership(t *testing.T) {
+	urlService, _ := setupShortenerService(t)
+	router := newAuthRouter(t, "bootstrap-secret", nil)
+	aliceID, alice := issueTestKey(t, domain.DefaultTenant, "alice", domain.ScopeCreate, domain.ScopeManage, domain.ScopeReadStats)
+	_, bob := issueTestKey(t, domain.DefaultTenant, "bob", domain.ScopeCreate, domain.ScopeManage, domain.ScopeReadStats)
+	admin := map[string]string{"X-API-Key": "bootstrap-secret"}
+
+	require.Equal(t, http.StatusOK, performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.com","alias":"alice-home"}`, alice).Code)
+	item := <-urlService.GetURL(domain.DefaultTenant, "alice-home").Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, "api-key:"+aliceID, item.V.(domain.URL).Owner)
+
+	for _, req := range []struct{ method, path, body string }{
+		{http.MethodPatch, "/alice-home", `{"enabled":false}`},
+		{http.MethodGet, "/urls/alice-home", ""},
+		{http.MethodGet, "/stats/alice-home", ""},
+		{http.MethodDelete, "/urls/alice-home", ""},
+	} {
+		assert.Equal(t, http.StatusForbidden, performRequest(router, req.method, req.path, req.body, bob).Code, req.path)
+		assert.Equal(t, http.StatusOK, performRequest(router, req.method, req.path, req.body, alice).Code, req.path)
+	}
+	assert.Equal(t, http.StatusOK, performRequest(router, http.MethodGet, "/urls/alice-home", "", admin).Code)
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodGet, "/urls/missing", "", bob).Code)
+
+	// Links stored before owners were recorded are left to admins
+	<-urlService.SaveURL(domain.URL{ID: "legacy", OriginalURL: "https://example.com/legacy", Enabled: true, Tenant: domain.DefaultTenant}).Observe()
+	assert.Equal(t, http.StatusForbidden, performRequest(router, http.MethodPatch, "/legacy", `{"enabled":false}`, alice).Code)
+	assert.Equal(t, http.StatusOK, performRequest(router, http.MethodPatch, "/legacy", `{"enabled":false}`, admin).Code)
+}
+
+// Test that shortening the same URL gives each owner their own link and listings their own links
+func TestURLOwnershipDedupe(t *testing.T) {
+	setupShortenerService(t)
+	router := newAuthRouter(t, "bootstrap-secret", nil)
+	_, alice := issueTestKey(t, domain.DefaultTenant, "alice", domain.ScopeCreate, domain.ScopeManage)
+	bobID, bob := issueTestKey(t, domain.DefaultTenant, "bob", domain.ScopeCreate, domain.ScopeManage)
+
+	var first, second map[string]string
+	recorder := performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.com/shared"}`, alice)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &first))
+	recorder = performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.com/shared"}`, bob)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &second))
+	assert.NotEqual(t, first["short_url"], second["short_url"])
+
+	// The same owner still gets a conflict
+	recorder = performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.com/shared"}`, bob)
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+
+	var listing struct {
+		URLs  []domain.URL `json:"urls"`
+		Total int64        `json:"total"`
+	}
+	recorder = performRequest(router, http.MethodGet, "/urls", "", bob)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &listing))
+	require.Len(t, listing.URLs, 1)
+	assert.Equal(t, second["short_url"], listing.URLs[0].ShortURL)
+	assert.Equal(t, http.StatusForbidden, performRequest(router, http.MethodGet, "/urls?owner=api-key:"+bobID+"x", "", bob).Code)
+
+	recorder = performRequest(router, http.MethodGet, "/urls", "", map[string]string{"X-API-Key": "bootstrap-secret"})
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &listing))
+	assert.Equal(t, int64(2), listing.Total)
+}
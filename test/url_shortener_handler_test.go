@@ -0,0 +1,281 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/handler"
+	"urlshortener/internal/models"
+	"urlshortener/internal/request"
+	"urlshortener/internal/service"
+)
+
+// newShortenerRouter registers the shortener routes on a test router
+func newShortenerRouter(urlShortenerHandler *handler.URLShortenerHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/shorten", urlShortenerHandler.ShortenURLHandler)
+	router.GET("/:id", urlShortenerHandler.RedirectURLHandler)
+	router.POST("/:id", urlShortenerHandler.ContinueRedirectHandler)
+	router.PATCH("/:id", urlShortenerHandler.SetURLStateHandler)
+	router.GET("/urls", urlShortenerHandler.ListURLsHandler)
+	router.GET("/urls/:id", urlShortenerHandler.GetURLHandler)
+	router.PATCH("/urls/:id", urlShortenerHandler.UpdateURLHandler)
+	router.DELETE("/urls/:id", urlShortenerHandler.DeleteURLHandler)
+	router.POST("/urls/:id/restore", urlShortenerHandler.RestoreURLHandler)
+	return router
+}
+
+// performRequest sends a request with an optional JSON body and headers to the router
+func performRequest(router *gin.Engine, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// Test that short links follow the X-Forwarded-* headers of trusted proxies when enabled
+func TestShortenURLHandlerBaseURLFromRequest(t *testing.T) {
+	setupShortenerService(t)
+	urlShortenerHandler := handler.NewURLShortenerHandler()
+	urlShortenerHandler.BaseURLFromRequest = true
+	router := newShortenerRouter(urlShortenerHandler)
+	require.NoError(t, handler.TrustProxies(router, testProxy))
+
+	recorder := performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.com","alias":"promo"}`, map[string]string{
+		"X-Forwarded-Proto":  "https",
+		"X-Forwarded-Host":   "sho.rt",
+		"X-Forwarded-Prefix": "/l",
+	})
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Equal(t, "https://sho.rt/l/promo", body["short_url"])
+
+	// A malformed host falls back to the configured base URL
+	recorder = performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.org","alias":"promo2"}`, map[string]string{
+		"X-Forwarded-Host": "evil.example.com/phish?",
+	})
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Equal(t, "http://localhost:8080/promo2", body["short_url"])
+
+	// Callers that are not a trusted proxy cannot pick the host of the short link
+	require.NoError(t, handler.TrustProxies(router, ""))
+	recorder = performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.net","alias":"promo3"}`, map[string]string{
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "sho.rt",
+	})
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Equal(t, "http://localhost:8080/promo3", body["short_url"])
+}
+
+// Test that editing the destination refreshes the redirect and the dedupe mapping
+func TestUpdateURLHandler(t *testing.T) {
+	urlService, redisServer := setupShortenerService(t)
+	router := newShortenerRouter(handler.NewURLShortenerHandler())
+	_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/old", Alias: "docs"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	_, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/taken", Alias: "other"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+
+	recorder := performRequest(router, http.MethodPatch, "/urls/docs", `{"original_url":"https://example.com/new","max_clicks":5}`, nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var updated domain.URL
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &updated))
+	assert.Equal(t, "https://example.com/new", updated.OriginalURL)
+	assert.Equal(t, int64(5), updated.MaxClicks)
+
+	// Limited links are not served from cache, so the old entry must be gone
+	assert.False(t, redisServer.Exists(defaultLinkKey("docs")))
+	item := <-urlService.FindURLByOriginal("https://example.com/new", domain.DefaultTenant, "tester").Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, "docs", item.V.(domain.URL).ID)
+
+	recorder = performRequest(router, http.MethodGet, "/docs", "", nil)
+	assert.Equal(t, http.StatusFound, recorder.Code)
+	assert.Equal(t, "https://example.com/new", recorder.Header().Get("Location"))
+
+	cases := []struct {
+		path, body string
+		code       int
+	}{
+		{"/urls/docs", `{"original_url":"javascript:alert(1)"}`, http.StatusBadRequest},
+		{"/urls/docs", `{"original_url":"https://example.com/taken"}`, http.StatusConflict},
+		{"/urls/missing", `{"original_url":"https://example.com/x"}`, http.StatusNotFound},
+		{"/urls/docs", `{"ttl":60,"expires_at":"2099-01-01T00:00:00Z"}`, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		recorder = performRequest(router, http.MethodPatch, tc.path, tc.body, nil)
+		assert.Equal(t, tc.code, recorder.Code, tc.body)
+	}
+}
+
+// Test that setting the state explicitly is idempotent and reports the previous state
+func TestSetURLStateHandler(t *testing.T) {
+	setupShortenerService(t)
+	router := newShortenerRouter(handler.NewURLShortenerHandler())
+	_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com", Alias: "promo"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+
+	var body map[string]interface{}
+	for i, previous := range []bool{true, false} {
+		recorder := performRequest(router, http.MethodPatch, "/promo", `{"enabled":false}`, nil)
+		require.Equal(t, http.StatusOK, recorder.Code, i)
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+		assert.Equal(t, false, body["enabled"])
+		assert.Equal(t, previous, body["previous_enabled"])
+	}
+
+	recorder := performRequest(router, http.MethodGet, "/promo", "", nil)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = performRequest(router, http.MethodPatch, "/promo", `{"enabled":true}`, nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	recorder = performRequest(router, http.MethodGet, "/promo", "", nil)
+	assert.Equal(t, http.StatusFound, recorder.Code)
+
+	recorder = performRequest(router, http.MethodPatch, "/missing", `{"enabled":true}`, nil)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = performRequest(router, http.MethodPatch, "/promo", "", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+// Test the trash, restore and permanent delete flow
+func TestDeleteAndRestoreURLHandler(t *testing.T) {
+	urlService, redisServer := setupShortenerService(t)
+	router := newShortenerRouter(handler.NewURLShortenerHandler())
+	_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com", Alias: "promo"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	performRequest(router, http.MethodGet, "/promo", "", nil)
+	require.True(t, redisServer.Exists(defaultLinkKey("promo", ":access_count")))
+
+	// Soft delete hides the link from redirects and management
+	recorder := performRequest(router, http.MethodDelete, "/urls/promo", "", nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.False(t, redisServer.Exists(defaultLinkKey("promo")))
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodGet, "/promo", "", nil).Code)
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodPatch, "/promo", `{"enabled":true}`, nil).Code)
+
+	// Restore brings it back
+	recorder = performRequest(router, http.MethodPost, "/urls/promo/restore", "", nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, http.StatusFound, performRequest(router, http.MethodGet, "/promo", "", nil).Code)
+
+	// Permanent delete purges the document, the cache and the stats keys
+	recorder = performRequest(router, http.MethodDelete, "/urls/promo?permanent=true", "", nil)
+	require.Equal(t, http.StatusNoContent, recorder.Code)
+	item := <-urlService.GetURL(domain.DefaultTenant, "promo").Observe()
+	assert.Error(t, item.E)
+	assert.False(t, redisServer.Exists(defaultLinkKey("promo")))
+	assert.False(t, redisServer.Exists("link:promo"))
+	assert.False(t, redisServer.Exists(defaultLinkKey("promo", ":access_count")))
+	assert.False(t, redisServer.Exists(defaultLinkKey("promo", ":last_access")))
+
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodDelete, "/urls/promo?permanent=true", "", nil).Code)
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodPost, "/urls/promo/restore", "", nil).Code)
+}
+
+// Test that a URL cannot be restored once its retention window has passed
+func TestRestoreURLAfterRetention(t *testing.T) {
+	urlService, _ := setupShortenerService(t)
+	deletedAt := time.Now().Add(-service.TrashRetention - time.Minute)
+	<-urlService.SaveURL(domain.URL{ID: "old", OriginalURL: "https://example.com", Tenant: domain.DefaultTenant, Enabled: true, DeletedAt: &deletedAt}).Observe()
+
+	_, err := service.RestoreURL("old", domain.DefaultTenant, "tester")
+	var apiErr *models.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusGone, apiErr.Code)
+}
+
+// Test that the metadata endpoint and the '+' preview do not count as clicks
+func TestGetURLHandler(t *testing.T) {
+	setupShortenerService(t)
+	router := newShortenerRouter(handler.NewURLShortenerHandler())
+	_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/doc", Alias: "doc"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	performRequest(router, http.MethodGet, "/doc", "", nil)
+
+	var details service.URLDetails
+	for _, path := range []string{"/urls/doc", "/urls/doc"} {
+		recorder := performRequest(router, http.MethodGet, path, "", nil)
+		require.Equal(t, http.StatusOK, recorder.Code, path)
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &details))
+		assert.Equal(t, "https://example.com/doc", details.OriginalURL)
+		assert.True(t, details.Enabled)
+		assert.False(t, details.CreatedAt.IsZero())
+		assert.Equal(t, "tester", details.Owner)
+		assert.Equal(t, int64(1), details.ClickCount)
+		assert.NotNil(t, details.LastAccess)
+	}
+
+	// The public preview leaves out who owns and manages the link
+	for i := 0; i < 2; i++ {
+		recorder := performRequest(router, http.MethodGet, "/doc+", "", nil)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"original_url":"https://example.com/doc","enabled":true,"click_count":1}`, recorder.Body.String())
+	}
+
+	// Trashed links stay visible to management but not to the public preview
+	performRequest(router, http.MethodDelete, "/urls/doc", "", nil)
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodGet, "/doc+", "", nil).Code)
+	recorder := performRequest(router, http.MethodGet, "/urls/doc", "", nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &details))
+	assert.NotNil(t, details.DeletedAt)
+
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodGet, "/urls/missing", "", nil).Code)
+}
+
+// Test that creations and changes record who made them and when
+func TestURLAuditFields(t *testing.T) {
+	urlService, _ := setupShortenerService(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	urlShortenerHandler := handler.NewURLShortenerHandler()
+	router.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-Test-User"); user != "" {
+			c.Set(handler.ActorKey, user)
+		}
+	})
+	router.POST("/shorten", urlShortenerHandler.ShortenURLHandler)
+	router.PATCH("/:id", urlShortenerHandler.SetURLStateHandler)
+
+	recorder := performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.com","alias":"audit"}`, nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	item := <-urlService.GetURL(domain.DefaultTenant, "audit").Observe()
+	require.NoError(t, item.E)
+	created := item.V.(domain.URL)
+	assert.Equal(t, handler.AnonymousActor, created.CreatedBy)
+	assert.Equal(t, handler.AnonymousActor, created.UpdatedBy)
+	assert.Equal(t, created.CreatedAt, created.UpdatedAt)
+
+	time.Sleep(2 * time.Millisecond)
+	recorder = performRequest(router, http.MethodPatch, "/audit", `{"enabled":false}`, map[string]string{"X-Test-User": "alice"})
+	require.Equal(t, http.StatusOK, recorder.Code)
+	item = <-urlService.GetURL(domain.DefaultTenant, "audit").Observe()
+	require.NoError(t, item.E)
+	updated := item.V.(domain.URL)
+	assert.Equal(t, handler.AnonymousActor, updated.CreatedBy)
+	assert.Equal(t, "alice", updated.UpdatedBy)
+	assert.Equal(t, created.CreatedAt, updated.CreatedAt)
+	assert.True(t, updated.UpdatedAt.After(created.UpdatedAt))
+}
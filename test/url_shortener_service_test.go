@@ -0,0 +1,237 @@
+package test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"urlshortener/internal/cache"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/models"
+	"urlshortener/internal/repository"
+	"urlshortener/internal/request"
+	"urlshortener/internal/service"
+)
+
+// setupShortenerService wires the service to in-memory repositories and a fake Redis
+func setupShortenerService(t *testing.T) (*repository.MemoryURLServiceImpl, *miniredis.Miniredis) {
+	redisServer := miniredis.RunT(t)
+	cache.InitRedis(redisServer.Addr(), "", 0)
+
+	urlService := repository.NewMemoryURLService()
+	service.URLServiceInstance = urlService
+	service.ReportServiceInstance = repository.NewMemoryReportService()
+	return urlService, redisServer
+}
+
+// shortIDOf returns the last path segment of a short URL
+func shortIDOf(shortURL string) string {
+	return shortURL[strings.LastIndex(shortURL, "/")+1:]
+}
+
+// defaultLinkKey returns the Redis key of a link of the default tenant, optionally with a suffix
+func defaultLinkKey(shortID string, suffix ...string) string {
+	return "tenant:" + domain.DefaultTenant + ":url:" + shortID + strings.Join(suffix, "")
+}
+
+// Test that creating the same URL twice reports a conflict
+func TestCreateShortURLConflict(t *testing.T) {
+	setupShortenerService(t)
+
+	_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+
+	_, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com"}, "", domain.DefaultTenant, "tester")
+	var apiErr *models.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusConflict, apiErr.Code)
+}
+
+// Test that a hash prefix collision with a different URL is retried with a longer ID
+func TestCreateShortURLRetriesOnCollision(t *testing.T) {
+	urlService, _ := setupShortenerService(t)
+
+	digest := md5.Sum([]byte("https://example.com/collides"))
+	takenID := hex.EncodeToString(digest[:])[:6]
+	<-urlService.SaveURL(domain.URL{ID: takenID, OriginalURL: "https://example.com/other", Enabled: true}).Observe()
+
+	shortURL, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/collides"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	assert.NotEqual(t, takenID, shortIDOf(shortURL))
+	assert.Len(t, shortIDOf(shortURL), 7)
+
+	originalURL, err := service.ResolveURL(shortIDOf(shortURL))
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/collides", originalURL)
+}
+
+// Test that a custom alias is stored as the short ID and resolves like a generated one
+func TestCreateShortURLWithAlias(t *testing.T) {
+	setupShortenerService(t)
+
+	shortURL, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/sale", Alias: "spring-sale"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	assert.Equal(t, "spring-sale", shortIDOf(shortURL))
+
+	originalURL, err := service.ResolveURL("spring-sale")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/sale", originalURL)
+}
+
+// Test alias validation and the conflict on an alias that is already taken
+func TestCreateShortURLAliasErrors(t *testing.T) {
+	setupShortenerService(t)
+	_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/sale", Alias: "spring-sale"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+
+	cases := []struct {
+		req  request.ShortenRequest
+		code int
+	}{
+		{request.ShortenRequest{OriginalURL: "https://example.com/other", Alias: "spring-sale"}, http.StatusConflict},
+		{request.ShortenRequest{OriginalURL: "https://example.com/other", Alias: "Stats"}, http.StatusBadRequest},
+		{request.ShortenRequest{OriginalURL: "https://example.com/other", Alias: "report"}, http.StatusBadRequest},
+		{request.ShortenRequest{OriginalURL: "https://example.com/other", Alias: "a/b"}, http.StatusBadRequest},
+		{request.ShortenRequest{OriginalURL: "https://example.com/other", Alias: "ab"}, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		_, err := service.CreateShortURL(tc.req, "", domain.DefaultTenant, "tester")
+		var apiErr *models.APIError
+		require.True(t, errors.As(err, &apiErr), tc.req.Alias)
+		assert.Equal(t, tc.code, apiErr.Code, tc.req.Alias)
+	}
+}
+
+// Test that short links are built under the configured base URL or an explicit one
+func TestCreateShortURLBaseURL(t *testing.T) {
+	setupShortenerService(t)
+	require.NoError(t, service.SetBaseURL("https://sho.rt/links/"))
+	t.Cleanup(func() { service.BaseURL = "http://localhost:8080" })
+
+	shortURL, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/a", Alias: "first"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	assert.Equal(t, "https://sho.rt/links/first", shortURL)
+
+	shortURL, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/b", Alias: "second"}, "https://edge.example.com", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	assert.Equal(t, "https://edge.example.com/second", shortURL)
+
+	assert.Error(t, service.SetBaseURL("ftp://sho.rt"))
+	assert.Error(t, service.SetBaseURL("/relative"))
+}
+
+// Test that the cache entry of an expiring link never outlives the link
+func TestCreateShortURLWithTTL(t *testing.T) {
+	_, redisServer := setupShortenerService(t)
+
+	shortURL, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/ttl", TTL: 60}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+
+	ttl := redisServer.TTL(defaultLinkKey(shortIDOf(shortURL)))
+	assert.True(t, ttl > 0 && ttl <= 60*time.Second, ttl)
+
+	_, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/both", TTL: 60, ExpiresAt: &time.Time{}}, "", domain.DefaultTenant, "tester")
+	var apiErr *models.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+
+	past := time.Now().Add(-time.Minute)
+	_, err = service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/past", ExpiresAt: &past}, "", domain.DefaultTenant, "tester")
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+}
+
+// Test that an expired link is refused with 410 Gone
+func TestResolveExpiredURL(t *testing.T) {
+	urlService, _ := setupShortenerService(t)
+	expiresAt := time.Now().Add(-time.Second)
+	<-urlService.SaveURL(domain.URL{ID: "expired", OriginalURL: "https://example.com", Enabled: true, ExpiresAt: &expiresAt}).Observe()
+
+	_, err := service.ResolveURL("expired")
+	var apiErr *models.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusGone, apiErr.Code)
+}
+
+// Test that a one-time link redirects exactly once under concurrent requests
+func TestResolveOneTimeURLConcurrently(t *testing.T) {
+	setupShortenerService(t)
+	shortURL, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/reset", MaxClicks: 1}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	shortID := shortIDOf(shortURL)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	redirects, gone := 0, 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.ResolveURL(shortID)
+			var apiErr *models.APIError
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				redirects++
+			} else if errors.As(err, &apiErr) && apiErr.Code == http.StatusGone {
+				gone++
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, redirects)
+	assert.Equal(t, 19, gone)
+
+	// The exhausted link stays gone and is disabled in storage
+	_, err = service.ResolveURL(shortID)
+	var apiErr *models.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusGone, apiErr.Code)
+
+	item := <-service.URLServiceInstance.GetURL(domain.DefaultTenant, shortID).Observe()
+	require.NoError(t, item.E)
+	assert.False(t, item.V.(domain.URL).Enabled)
+}
+
+// Test that sweeping expired links also clears their cache entry and counters
+func TestSweepExpiredURLs(t *testing.T) {
+	urlService, redisServer := setupShortenerService(t)
+	past := time.Now().Add(-time.Minute)
+	<-urlService.SaveURL(domain.URL{ID: "expired", OriginalURL: "https://example.com/old", Tenant: domain.DefaultTenant, ExpiresAt: &past}).Observe()
+	<-urlService.SaveURL(domain.URL{ID: "forever", OriginalURL: "https://example.com/forever", Tenant: domain.DefaultTenant}).Observe()
+	for _, id := range []string{"expired", "forever"} {
+		require.NoError(t, redisServer.Set(defaultLinkKey(id, ":access_count"), "3"))
+		require.NoError(t, redisServer.Set(defaultLinkKey(id, ":clicks_used"), "1"))
+	}
+
+	deleted, err := service.SweepExpiredURLs()
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.False(t, redisServer.Exists(defaultLinkKey("expired", ":access_count")))
+	assert.False(t, redisServer.Exists(defaultLinkKey("expired", ":clicks_used")))
+	assert.True(t, redisServer.Exists(defaultLinkKey("forever", ":access_count")))
+}
+
+// Test that a new link does not inherit the counters left by an earlier link with its ID
+func TestCreateShortURLResetsLeftoverCounters(t *testing.T) {
+	_, redisServer := setupShortenerService(t)
+	for suffix, value := range map[string]string{":clicks_used": "1", ":access_count": "42", ":last_access": "2026-01-01T00:00:00Z"} {
+		require.NoError(t, redisServer.Set(defaultLinkKey("reused", suffix), value))
+	}
+
+	_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/new", Alias: "reused", MaxClicks: 1}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	assert.False(t, redisServer.Exists(defaultLinkKey("reused", ":access_count")))
+	assert.False(t, redisServer.Exists(defaultLinkKey("reused", ":last_access")))
+
+	_, err = service.ResolveURL("reused")
+	require.NoError(t, err, "the click of the earlier link is not counted")
+}
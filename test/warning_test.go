@@ -0,0 +1,147 @@
+package test
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"urlshortener/internal/domain"
+	"urlshortener/internal/handler"
+	"urlshortener/internal/models"
+	"urlshortener/internal/request"
+	"urlshortener/internal/service"
+)
+
+// useWarningPolicy installs policy for the duration of the test
+func useWarningPolicy(t *testing.T, policy service.WarningPolicy) {
+	require.NoError(t, service.SetWarningPolicy(policy))
+	t.Cleanup(func() { require.NoError(t, service.SetWarningPolicy(service.WarningPolicy{})) })
+}
+
+// Test the heuristics that put a warning page in front of unflagged links
+func TestWarningHeuristics(t *testing.T) {
+	setupShortenerService(t)
+	useWarningPolicy(t, service.WarningPolicy{RawIPs: true, TLDs: []string{".ZIP"}, GeneratedDomains: true})
+
+	for destination, warning := range map[string]string{
+		"https://example.com/":                     "",
+		"https://stackoverflow.com/questions":      "",
+		"https://www.wikipedia.org/":               "",
+		"https://web2024.com/":                     "",
+		"https://2024web.example/":                 "",
+		"http://192.0.2.1/login":                   "destination is a raw IP address",
+		"http://[2001:db8::1]/":                    "destination is a raw IP address",
+		"https://files.example.zip/":               "destination uses the .zip top-level domain",
+		"https://secure-login-verify-account.com/": "destination domain looks newly registered",
+		"https://pay4581ment.net/":                 "destination domain looks newly registered",
+		"https://xkqzrtvbnmplkw.com/":              "destination domain looks newly registered",
+	} {
+		shortURL, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: destination}, "", domain.DefaultTenant, "tester")
+		require.NoError(t, err, destination)
+		redirect, err := service.ResolveRedirect(shortIDOf(shortURL), service.RedirectAccess{})
+		require.NoError(t, err, destination)
+		assert.Equal(t, warning, redirect.Warning, destination)
+	}
+
+	assert.Error(t, service.SetWarningPolicy(service.WarningPolicy{TLDs: []string{"co.uk"}}))
+}
+
+// Test the warning page, clicking through it and the separate statistics
+func TestInterstitialRedirect(t *testing.T) {
+	_, redisServer := setupShortenerService(t)
+	handler.URLStatService = *service.NewURLStatService()
+	router := newShortenerRouter(handler.NewURLShortenerHandler())
+
+	recorder := performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.com/?q=<b>","alias":"flagged","warn":true}`, nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.False(t, redisServer.Exists(defaultLinkKey("flagged")), "warned links are not cached")
+
+	recorder = performRequest(router, http.MethodGet, "/flagged", "", nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("Content-Type"), "text/html")
+	assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+	assert.Contains(t, recorder.Body.String(), "https://example.com/?q=&lt;b&gt;", "the destination is escaped")
+	assert.Contains(t, recorder.Body.String(), service.WarningOwner)
+	assert.Contains(t, recorder.Body.String(), `<form method="post">`)
+	assert.False(t, redisServer.Exists(defaultLinkKey("flagged")))
+
+	recorder = performRequest(router, http.MethodPost, "/flagged", "", nil)
+	require.Equal(t, http.StatusSeeOther, recorder.Code)
+	assert.Equal(t, "https://example.com/?q=<b>", recorder.Header().Get("Location"))
+
+	// Direct redirects are counted apart from clicks through the warning page
+	require.Equal(t, http.StatusOK, performRequest(router, http.MethodPost, "/shorten", `{"original_url":"https://example.org/","alias":"plain"}`, nil).Code)
+	assert.Equal(t, http.StatusFound, performRequest(router, http.MethodGet, "/plain", "", nil).Code)
+	assert.Equal(t, http.StatusFound, performRequest(router, http.MethodPost, "/plain", "", nil).Code)
+
+	item := <-handler.URLStatService.GetURLStats(domain.DefaultTenant, "flagged").Observe()
+	require.NoError(t, item.E)
+	stats := item.V.(map[string]interface{})
+	assert.Equal(t, 1, stats["access_count"])
+	assert.Equal(t, 0, stats["direct_redirects"])
+	assert.Equal(t, 1, stats["interstitial_views"])
+	assert.Equal(t, 1, stats["interstitial_continues"])
+	item = <-handler.URLStatService.GetURLStats(domain.DefaultTenant, "plain").Observe()
+	require.NoError(t, item.E)
+	assert.Equal(t, 2, item.V.(map[string]interface{})["direct_redirects"])
+}
+
+// Test that viewing the warning page of a limited link does not use up a click
+func TestInterstitialKeepsClicks(t *testing.T) {
+	setupShortenerService(t)
+	_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/", Alias: "once", MaxClicks: 1, Warn: true}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		redirect, err := service.ResolveRedirect("once", service.RedirectAccess{})
+		require.NoError(t, err)
+		assert.Equal(t, service.WarningOwner, redirect.Warning)
+	}
+	_, err = service.ResolveRedirect("once", service.RedirectAccess{Confirmed: true})
+	require.NoError(t, err)
+	_, err = service.ResolveRedirect("once", service.RedirectAccess{Confirmed: true})
+	assert.Error(t, err)
+}
+
+// Test that owners set and lift their warnings but not the ones of moderators
+func TestWarningOwnership(t *testing.T) {
+	_, redisServer := setupShortenerService(t)
+	_, err := service.CreateShortURL(request.ShortenRequest{OriginalURL: "https://example.com/", Alias: "promo"}, "", domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	_, err = service.ResolveURL("promo")
+	require.NoError(t, err)
+	require.True(t, redisServer.Exists(defaultLinkKey("promo")))
+
+	warn, lift := true, false
+	url, err := service.UpdateShortURL("promo", request.UpdateURLRequest{Warn: &warn}, domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	assert.Equal(t, service.WarningOwner, url.Warning)
+	assert.False(t, redisServer.Exists(defaultLinkKey("promo")), "warning a link evicts it from the cache")
+	url, err = service.UpdateShortURL("promo", request.UpdateURLRequest{Warn: &lift}, domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	assert.Empty(t, url.Warning)
+
+	// Moderators warn through the moderation queue
+	useMemoryReports(t)
+	result, err := service.ModerateURL("promo", service.ModerationWarn, domain.DefaultTenant, "mod")
+	require.NoError(t, err)
+	assert.Equal(t, service.ModerationWarn, result.Action)
+	redirect, err := service.ResolveRedirect("promo", service.RedirectAccess{})
+	require.NoError(t, err)
+	assert.Equal(t, service.WarningModerator, redirect.Warning)
+
+	_, err = service.UpdateShortURL("promo", request.UpdateURLRequest{Warn: &lift}, domain.DefaultTenant, "tester")
+	var apiErr *models.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusForbidden, apiErr.Code)
+	url, err = service.UpdateShortURL("promo", request.UpdateURLRequest{Warn: &warn}, domain.DefaultTenant, "tester")
+	require.NoError(t, err)
+	assert.Equal(t, service.WarningModerator, url.Warning, "owners cannot downgrade a moderator warning")
+
+	_, err = service.ModerateURL("promo", service.ModerationUnwarn, domain.DefaultTenant, "mod")
+	require.NoError(t, err)
+	redirect, err = service.ResolveRedirect("promo", service.RedirectAccess{})
+	require.NoError(t, err)
+	assert.Empty(t, redirect.Warning)
+}
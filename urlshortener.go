@@ -5,10 +5,15 @@ import (
 	"github.com/gin-gonic/gin"
 	"log"
 	"os"
+	"strings"
+	jwtauth "urlshortener/internal/auth"
 	"urlshortener/internal/cache"
 	"urlshortener/internal/config"
+	"urlshortener/internal/domain"
 	"urlshortener/internal/handler"
+	"urlshortener/internal/idgen"
 	"urlshortener/internal/repository"
+	"urlshortener/internal/safety"
 	"urlshortener/internal/service"
 	"urlshortener/internal/storage"
 )
@@ -26,56 +31,267 @@ func main() {
 
 	// Load configuration
 	cfg := config.LoadConfig()
-	log.Printf("Configuration loaded: %s", cfg)
-
-	// Create an instance of MongoDBService
-	var dbClient storage.MongoDBClient = &storage.MongoDBService{}
-
-	// Connect to MongoDB using the provided URI from the config
-	connectObservable := dbClient.Connect(cfg.MongoURI)
-	connectObservable.ForEach(func(item interface{}) {
-		log.Println("Connected to MongoDB")
-
-		// Initialize the URL collection in MongoDB
-		urlService := &repository.URLServiceImpl{}
-		urlService.InitDatabase(dbClient.GetClient(), cfg.MongoDBName, cfg.MongoCollection)
-
-		// Set URLServiceInstance to the initialized URLService
-		service.URLServiceInstance = urlService
-	}, func(err error) {
-		log.Fatalf("MongoDB connection error: %v", err)
-	}, func() {
-		log.Println("MongoDB connection sequence completed")
-	})
+	log.Printf("Configuration loaded: %+v", cfg.Redacted())
+
+	switch cfg.StorageDriver {
+	case "memory":
+		// Keep URLs in process memory; nothing survives a restart
+		log.Println("Using in-memory URL storage")
+		service.URLServiceInstance = repository.NewMemoryURLService()
+		service.APIKeyServiceInstance = repository.NewMemoryAPIKeyService()
+		service.ReportServiceInstance = repository.NewMemoryReportService()
+	case storage.DriverSQLite, storage.DriverPostgres:
+		// Create an instance of SQLService
+		var sqlClient storage.SQLClient = &storage.SQLService{}
 
-	// Ensure MongoDB connection is closed when the server stops
-	defer func() {
-		disconnectObservable := dbClient.Disconnect()
-		disconnectObservable.ForEach(func(item interface{}) {
-			log.Println(item)
+		// Connect to the SQL database using the provided DSN from the config, waiting for the
+		// repositories to be set before anything else uses them
+		connectObservable := sqlClient.Connect(cfg.StorageDriver, cfg.SQLDSN)
+		<-connectObservable.ForEach(func(item interface{}) {
+			// Apply schema migrations before serving any request
+			urlService := &repository.SQLURLServiceImpl{}
+			if err := urlService.InitDatabase(sqlClient.GetDB(), cfg.StorageDriver); err != nil {
+				log.Fatalf("SQL migration error: %v", err)
+			}
+
+			service.URLServiceInstance = urlService
+			service.APIKeyServiceInstance = &repository.SQLAPIKeyServiceImpl{DB: sqlClient.GetDB(), Dialect: cfg.StorageDriver}
+			service.ReportServiceInstance = &repository.SQLReportServiceImpl{DB: sqlClient.GetDB(), Dialect: cfg.StorageDriver}
 		}, func(err error) {
-			log.Printf("Failed to disconnect from MongoDB: %v", err)
+			log.Fatalf("SQL connection error: %v", err)
 		}, func() {
-			log.Println("MongoDB disconnection sequence completed")
+			log.Println("SQL connection sequence completed")
 		})
-	}()
 
-	// Connect to Redis using configuration details
-	cache.InitRedis(cfg.RedisAddress, cfg.RedisPassword, cfg.RedisDB)
+		// Ensure the SQL connection pool is closed when the server stops
+		defer func() {
+			disconnectObservable := sqlClient.Disconnect()
+			disconnectObservable.ForEach(func(item interface{}) {
+				log.Println(item)
+			}, func(err error) {
+				log.Printf("Failed to disconnect from SQL database: %v", err)
+			}, func() {
+				log.Println("SQL disconnection sequence completed")
+			})
+		}()
+	default:
+		// Create an instance of MongoDBService
+		var dbClient storage.MongoDBClient = &storage.MongoDBService{}
+
+		// Connect to MongoDB using the provided URI from the config, waiting for the
+		// repositories to be set before anything else uses them
+		connectObservable := dbClient.Connect(cfg.MongoURI)
+		<-connectObservable.ForEach(func(item interface{}) {
+			log.Println("Connected to MongoDB")
+
+			// Initialize the URL collection in MongoDB
+			urlService := &repository.URLServiceImpl{}
+			if err := urlService.InitDatabase(dbClient.GetClient(), cfg.MongoDBName, cfg.MongoCollection); err != nil {
+				log.Fatalf("MongoDB index creation error: %v", err)
+			}
+
+			// Set URLServiceInstance to the initialized URLService
+			service.URLServiceInstance = urlService
+
+			// API keys live in their own collection
+			apiKeyService := &repository.APIKeyServiceImpl{}
+			if err := apiKeyService.InitDatabase(dbClient.GetClient(), cfg.MongoDBName, cfg.APIKeyCollection); err != nil {
+				log.Fatalf("MongoDB index creation error: %v", err)
+			}
+			service.APIKeyServiceInstance = apiKeyService
+
+			// Abuse reports and banned domains too
+			reportService := &repository.ReportServiceImpl{}
+			if err := reportService.InitDatabase(dbClient.GetClient(), cfg.MongoDBName, cfg.ReportCollection, cfg.BanCollection); err != nil {
+				log.Fatalf("MongoDB index creation error: %v", err)
+			}
+			service.ReportServiceInstance = reportService
+		}, func(err error) {
+			log.Fatalf("MongoDB connection error: %v", err)
+		}, func() {
+			log.Println("MongoDB connection sequence completed")
+		})
+
+		// Ensure MongoDB connection is closed when the server stops
+		defer func() {
+			disconnectObservable := dbClient.Disconnect()
+			disconnectObservable.ForEach(func(item interface{}) {
+				log.Println(item)
+			}, func(err error) {
+				log.Printf("Failed to disconnect from MongoDB: %v", err)
+			}, func() {
+				log.Println("MongoDB disconnection sequence completed")
+			})
+		}()
+	}
+
+	// Purge expired links from storage that has no TTL index of its own
+	service.StartExpirySweeper(cfg.ExpirySweepInterval)
+
+	// Purge trashed links once they can no longer be restored
+	service.TrashRetention = cfg.TrashRetention
+	service.StartTrashSweeper(cfg.ExpirySweepInterval)
+
+	// Copy click counters into storage so listings can sort by them
+	service.StartClickCountSync(cfg.ClickSyncInterval)
+
+	// Connect to Redis using configuration details. Without a database, a lone replica may
+	// also run without a Redis server.
+	if cfg.StorageDriver == "memory" {
+		if err := cache.ConnectRedis(cfg.RedisAddress, cfg.RedisPassword, cfg.RedisDB); err != nil {
+			log.Printf("Redis unavailable (%v); using an in-process cache that replicas do not share", err)
+			if err = cache.InitEmbeddedRedis(); err != nil {
+				log.Fatalf("Embedded Redis error: %v", err)
+			}
+		}
+	} else {
+		cache.InitRedis(cfg.RedisAddress, cfg.RedisPassword, cfg.RedisDB)
+	}
+
+	// Move statistics kept before Redis keys were namespaced per tenant
+	if err := service.MigrateLegacyLinkKeys(); err != nil {
+		log.Fatalf("Redis key migration error: %v", err)
+	}
+
+	// Choose how short IDs are minted
+	idGenerator, err := idgen.New(cfg.IDStrategy, cfg.IDLength, cfg.IDAlphabet)
+	if err != nil {
+		log.Fatalf("ID generator configuration error: %v", err)
+	}
+	service.IDGeneratorInstance = idGenerator
+
+	// Public base URL of the generated short links
+	if err := service.SetBaseURL(cfg.BaseURL); err != nil {
+		log.Fatalf("Base URL configuration error: %v", err)
+	}
+
+	// Destinations accepted by /shorten and how they are canonicalized
+	err = service.SetDestinationPolicy(service.DestinationPolicy{
+		AllowedSchemes: strings.Split(cfg.DestinationSchemes, ","),
+		MaxLength:      cfg.DestinationMaxLen,
+		SortQuery:      cfg.SortQuery,
+		StripFragment:  cfg.StripFragment,
+	})
+	if err != nil {
+		log.Fatalf("Destination configuration error: %v", err)
+	}
+
+	// Destinations that are short links themselves, ours or third-party ones
+	if cfg.SelfLinks != "resolve" && cfg.SelfLinks != "reject" {
+		log.Fatalf("SELF_LINKS must be resolve or reject, got %q", cfg.SelfLinks)
+	}
+	err = service.SetShortLinkPolicy(service.ShortLinkPolicy{
+		Domains:          strings.Split(cfg.ShortDomains, ","),
+		ResolveOwnLinks:  cfg.SelfLinks == "resolve",
+		RejectShorteners: cfg.RejectShorteners,
+	})
+	if err != nil {
+		log.Fatalf("Short domain configuration error: %v", err)
+	}
+
+	// Blocklist, allowlist and threat list screening destinations on creation and redirect
+	if cfg.DomainBlocklist != "" || cfg.DomainAllowlist != "" || cfg.ThreatListFile != "" {
+		checker, err := safety.NewChecker(safety.Config{
+			Blocklist:      strings.Split(cfg.DomainBlocklist, ","),
+			Allowlist:      strings.Split(cfg.DomainAllowlist, ","),
+			ThreatListPath: cfg.ThreatListFile,
+		})
+		if err != nil {
+			log.Fatalf("Safety configuration error: %v", err)
+		}
+		checker.StartReload(cfg.ThreatListReload)
+		service.DestinationCheckerInstance = checker
+	}
+
+	// Destinations that get a warning page before redirecting even though nobody flagged them
+	err = service.SetWarningPolicy(service.WarningPolicy{
+		RawIPs:           cfg.WarnRawIPs,
+		TLDs:             strings.Split(cfg.WarnTLDs, ","),
+		GeneratedDomains: cfg.WarnGenerated,
+	})
+	if err != nil {
+		log.Fatalf("Warning page configuration error: %v", err)
+	}
+
+	// Wrong passwords accepted for a protected link, and from one client, before refusing attempts
+	service.SetPasswordThrottle(service.PasswordThrottle{
+		PerLink: cfg.PasswordPerLink,
+		PerIP:   cfg.PasswordPerIP,
+	})
+
+	// Domains banned by moderators, kept in memory and refreshed from storage
+	if err := service.LoadBannedDomains(); err != nil {
+		log.Fatalf("Banned domain loading error: %v", err)
+	}
+	service.StartBannedDomainSync(cfg.BanSyncInterval)
+
+	// Quotas of the tenants sharing this deployment
+	defaultQuota := domain.TenantQuota{MaxLinks: int64(cfg.TenantMaxLinks), MaxDailyCreations: int64(cfg.TenantDailyLinks)}
+	if err := service.SetTenantQuotas(defaultQuota, cfg.TenantQuotas); err != nil {
+		log.Fatalf("Tenant quota configuration error: %v", err)
+	}
+
+	// Only redirects are public; everything else needs an API key with the right scope
+	service.SetBootstrapAPIKey(cfg.AdminAPIKey)
+	auth := handler.NewAuthenticator(cfg.AuthEnabled)
+	if cfg.JWKSSource != "" {
+		verifier, err := jwtauth.NewJWTVerifier(jwtauth.JWTConfig{
+			JWKSSource:  cfg.JWKSSource,
+			Issuer:      cfg.JWTIssuer,
+			Audience:    cfg.JWTAudience,
+			RolesClaim:  cfg.JWTRolesClaim,
+			TenantClaim: cfg.JWTTenantClaim,
+		})
+		if err != nil {
+			log.Fatalf("JWT configuration error: %v", err)
+		}
+		verifier.StartRefresh(cfg.JWKSRefreshInterval)
+		auth.JWT = verifier
+	}
+	if !cfg.AuthEnabled {
+		log.Println("WARNING: authentication is disabled; every route is public")
+	}
+
+	// Budgets are shared by every replica through Redis and keyed by caller, or by IP when anonymous
+	limiter := handler.NewRateLimiter(cfg.RateLimitEnabled)
+	if err := handler.TrustProxies(router, cfg.TrustedProxies); err != nil {
+		log.Fatalf("Trusted proxy configuration error: %v", err)
+	}
 
 	// Instantiate services
 	urlShortenerHandler := handler.NewURLShortenerHandler()
+	urlShortenerHandler.BaseURLFromRequest = cfg.BaseURLFromRequest
 	urlStatHandler := handler.NewURLStatHandler()
+	apiKeyHandler := handler.NewAPIKeyHandler()
+	reportHandler := handler.NewReportHandler()
 
 	// Define routes
-	router.POST("/shorten", urlShortenerHandler.ShortenURLHandler)
-	router.GET("/:id", urlShortenerHandler.RedirectURLHandler)
-	router.PATCH("/:id", urlShortenerHandler.ToggleURLStateHandler)
-	router.GET("/stats/:id", urlStatHandler.GetURLStats)
+	router.POST("/shorten", auth.Require(domain.ScopeCreate), limiter.Limit("shorten", cfg.RateLimitShorten), urlShortenerHandler.ShortenURLHandler)
+	router.GET("/:id", limiter.Limit("redirect", cfg.RateLimitRedirect), urlShortenerHandler.RedirectURLHandler)
+	router.POST("/:id", limiter.Limit("redirect", cfg.RateLimitRedirect), urlShortenerHandler.ContinueRedirectHandler)
+	router.PATCH("/:id", auth.Require(domain.ScopeManage), urlShortenerHandler.SetURLStateHandler)
+	router.PATCH("/urls/:id", auth.Require(domain.ScopeManage), urlShortenerHandler.UpdateURLHandler)
+	router.GET("/urls", auth.Require(domain.ScopeManage), urlShortenerHandler.ListURLsHandler)
+	router.GET("/urls/:id", auth.Require(domain.ScopeManage), urlShortenerHandler.GetURLHandler)
+	router.DELETE("/urls/:id", auth.Require(domain.ScopeManage), urlShortenerHandler.DeleteURLHandler)
+	router.POST("/urls/:id/restore", auth.Require(domain.ScopeManage), urlShortenerHandler.RestoreURLHandler)
+	router.GET("/stats/:id", auth.Require(domain.ScopeReadStats), limiter.Limit("stats", cfg.RateLimitStats), urlStatHandler.GetURLStats)
 
 	// system stats
 	systemStatsHandler := handler.NewSystemStatsHandler()
-	router.GET("/system/stats", systemStatsHandler.GetSystemStats)
+	router.GET("/system/stats", auth.Require(domain.ScopeReadStats), limiter.Limit("stats", cfg.RateLimitStats), systemStatsHandler.GetSystemStats)
+
+	// API key administration
+	router.POST("/admin/api-keys", auth.Require(domain.ScopeAdmin), apiKeyHandler.IssueAPIKeyHandler)
+	router.GET("/admin/api-keys", auth.Require(domain.ScopeAdmin), apiKeyHandler.ListAPIKeysHandler)
+	router.DELETE("/admin/api-keys/:id", auth.Require(domain.ScopeAdmin), apiKeyHandler.RevokeAPIKeyHandler)
+
+	// Abuse reports are public; acting on them needs the moderate scope
+	router.POST("/report/:id", limiter.Limit("report", cfg.RateLimitReport), reportHandler.ReportURLHandler)
+	router.GET("/admin/reports", auth.Require(domain.ScopeModerate), reportHandler.ListReportedURLsHandler)
+	router.POST("/admin/reports/:id", auth.Require(domain.ScopeModerate), reportHandler.ModerateURLHandler)
+	router.GET("/admin/banned-domains", auth.Require(domain.ScopeModerate), reportHandler.ListBannedDomainsHandler)
+	router.DELETE("/admin/banned-domains/:domain", auth.Require(domain.ScopeModerate), reportHandler.UnbanDomainHandler)
 
 	// Start the server
 	log.Printf("Listening on port %s", port)